@@ -0,0 +1,17 @@
+// Package main provides the gcp-ai-batch resource provider plugin, managing the Vertex AI jobs with settings
+// the google-native provider does not model. Install it on the PATH of the Pulumi CLI.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/davidmontoyago/pulumi-gcp-ai-batch/pkg/gcp"
+)
+
+func main() {
+	if err := gcp.ServeResourceProvider(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
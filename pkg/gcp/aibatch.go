@@ -3,12 +3,24 @@ package gcp
 
 import (
 	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
 
 	namer "github.com/davidmontoyago/commodity-namer"
 	vertexmodeldeployment "github.com/davidmontoyago/pulumi-gcp-vertex-model-deployment/sdk/go/pulumi-gcp-vertex-model-deployment/resources"
 	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/artifactregistry"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/bigquery"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/cloudscheduler"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/eventarc"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/kms"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/logging"
 	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/projects"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/pubsub"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/serviceaccount"
 	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/storage"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/workflows"
 	v1 "github.com/pulumi/pulumi-google-native/sdk/go/google/aiplatform/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
@@ -21,6 +33,9 @@ type AIBatch struct {
 	Project                           string
 	Region                            string
 	ModelImageURL                     pulumi.StringOutput
+	PredictRoute                      pulumi.StringOutput
+	HealthRoute                       pulumi.StringOutput
+	ContainerSpec                     *ContainerSpecArgs
 	ModelDir                          string
 	ModelName                         string
 	ModelPredictionInputSchemaPath    string
@@ -30,36 +45,98 @@ type AIBatch struct {
 	MachineType                       pulumi.StringOutput
 	JobDisplayName                    pulumi.StringOutput
 	ModelDisplayName                  pulumi.StringOutput
+	RunID                             string
 
 	// Batch prediction job specific fields
 	InputDataPath        pulumi.StringOutput
 	InputFormat          pulumi.StringOutput
+	InputBigQueryURI     string
+	InputURIs            []string
+	InstanceConfig       *InstanceConfigArgs
+	ModelParameters      map[string]interface{}
+	GenerateExplanation  bool
+	ExplanationSpec      *ExplanationSpecArgs
 	InputFileName        pulumi.StringOutput
 	OutputDataPath       pulumi.StringOutput
 	OutputFormat         pulumi.StringOutput
+	OutputBigQuery       *BigQueryOutputArgs
+	KmsKeyName           pulumi.StringOutput
 	StartingReplicaCount pulumi.IntOutput
 	MaxReplicaCount      pulumi.IntOutput
 	BatchSize            pulumi.IntOutput
 	AcceleratorType      pulumi.StringOutput
 	AcceleratorCount     pulumi.IntOutput
+	TpuTopology          pulumi.StringOutput
+	Spot                 bool
+	ReservationAffinity  *ReservationAffinityArgs
 	Labels               map[string]string
 
 	inputDataLocalDir  string
 	inputDataTargetDir string
 
 	retainJobOnDelete bool
+	batchJobClient    BatchJobClient
+	jobProvider       pulumi.ProviderResource
+	waitForCompletion *WaitForCompletionArgs
+	retryPolicy       *RetryPolicyArgs
+
+	versionedRunPrefixes bool
+	compareModels        bool
+	schedule             *ScheduleArgs
+	eventTrigger         *EventTriggerArgs
+	notifications        *NotificationsArgs
+	retentionPolicy      *RetentionPolicyArgs
+	costEstimate         *CostEstimate
+
+	customerManagedEncryption bool
+	unmodeledAcceleratorType  bool
 
 	// Core resources
 	modelServiceAccountEmail pulumi.StringOutput
+	jobSpecs                 []*batchJobSpec
+	inputShards              []inputShard
 	batchPredictionJob       *v1.BatchPredictionJob
 	artifactsBucket          *storage.Bucket
+	outputBigQueryDataset    *bigquery.Dataset
+	outputBigQueryURI        pulumi.StringOutput
+	modelArtifactsURI        pulumi.StringOutput
+	kmsCryptoKey             *kms.CryptoKey
 	modelDeployment          *vertexmodeldeployment.VertexModelDeployment
+	managedModel             *managedModel
 	uploadedModelFiles       pulumi.StringArrayOutput
 	jobState                 pulumi.StringOutput
+	jobWaiter                *BatchJobWaiter
+	jobAttempt               int
+	jobAttempts              []JobAttempt
+	runInputURIs             pulumi.StringArrayOutput
+	runOutputURI             pulumi.StringOutput
+	runIndex                 *storage.BucketObject
+
+	// Workflows launching jobs outside of deployments
+	workflowServiceAccount *serviceaccount.Account
+	workflowRoles          map[string]bool
+	workflowDependencies   []pulumi.Resource
+	scheduleWorkflow       *workflows.Workflow
+	scheduler              *cloudscheduler.Job
+	eventWorkflow          *workflows.Workflow
+	eventarcTrigger        *eventarc.Trigger
+	retentionWorkflow      *workflows.Workflow
+	retentionScheduler     *cloudscheduler.Job
+
+	// Notifications of the job state changes and prediction writes
+	notificationsTopic   *pubsub.Topic
+	notificationsSink    *logging.ProjectSink
+	webhookSubscriptions []*pubsub.Subscription
+
+	// Vertex AI service agent, running the models from the garden
+	vertexAgent *projects.ServiceIdentity
 
 	// IAM bindings for the model service account
-	iamMembers    []*projects.IAMMember
-	repoIamMember *artifactregistry.RepositoryIamMember
+	iamMembers         []*projects.IAMMember
+	repoIamMember      *artifactregistry.RepositoryIamMember
+	bigQueryIamMembers []*bigquery.DatasetIamMember
+	inputBucketMembers []*storage.BucketIAMMember
+	kmsKeyIamMembers   []*kms.CryptoKeyIAMMember
 }
 
 // NewAIBatch creates a new AIBatch instance with the provided configuration.
@@ -70,7 +147,11 @@ func NewAIBatch(ctx *pulumi.Context, name string, args *AIBatchArgs, opts ...pul
 	if args.Region == "" {
 		return nil, fmt.Errorf("region is required")
 	}
-	if args.ModelDir == "" && args.ModelName == "" {
+	if len(args.CompareModels) > 0 {
+		if err := validateComparison(args); err != nil {
+			return nil, fmt.Errorf("invalid model comparison: %w", err)
+		}
+	} else if args.ModelDir == "" && args.ModelName == "" {
 		return nil, fmt.Errorf("one of model directory or model name is required")
 	}
 
@@ -82,12 +163,122 @@ func NewAIBatch(ctx *pulumi.Context, name string, args *AIBatchArgs, opts ...pul
 			return nil, fmt.Errorf("model prediction output schema path is required")
 		}
 	}
+	if args.ContainerSpec != nil {
+		if args.ModelDir == "" && len(args.CompareModels) == 0 {
+			return nil, fmt.Errorf("container spec is only supported for custom models")
+		}
+		if err := validateContainerSpec(args.ContainerSpec); err != nil {
+			return nil, fmt.Errorf("invalid container spec: %w", err)
+		}
+	}
+
+	var modelParameters map[string]interface{}
+	if args.ModelParameters != nil {
+		var err error
+		modelParameters, err = toModelParameters(args.ModelParameters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid model parameters: %w", err)
+		}
+		if args.ModelDir != "" && args.ModelPredictionBehaviorSchemaPath != "" {
+			err = validateModelParameters(filepath.Join(args.ModelDir, args.ModelPredictionBehaviorSchemaPath), modelParameters)
+			if err != nil {
+				return nil, fmt.Errorf("invalid model parameters: %w", err)
+			}
+		}
+	}
+
+	if args.GenerateExplanation {
+		if args.ModelDir == "" {
+			return nil, fmt.Errorf("explanations are only supported for custom models")
+		}
+		if args.ExplanationSpec == nil {
+			return nil, fmt.Errorf("explanation spec is required when generate explanation is set")
+		}
+		if err := validateExplanationSpec(args.ExplanationSpec); err != nil {
+			return nil, fmt.Errorf("invalid explanation spec: %w", err)
+		}
+	} else if args.ExplanationSpec != nil {
+		return nil, fmt.Errorf("generate explanation must be set when explanation spec is set")
+	}
+
+	for _, warning := range applyGardenModelProfile(args) {
+		_ = ctx.Log.Warn(warning, nil)
+	}
+	if err := resolveTPUMachineSpec(args); err != nil {
+		return nil, fmt.Errorf("invalid TPU machine spec: %w", err)
+	}
+	if err := resolveMachineCatalogSpec(args); err != nil {
+		return nil, fmt.Errorf("invalid machine spec: %w", err)
+	}
+	if err := validateProvisioning(args.Spot, args.ReservationAffinity); err != nil {
+		return nil, fmt.Errorf("invalid capacity provisioning: %w", err)
+	}
+
+	if args.KmsKeyName != "" {
+		if args.CreateKmsKey {
+			return nil, fmt.Errorf("only one of KMS key name or create KMS key can be set")
+		}
+		if err := validateKmsKeyName(args.KmsKeyName, args.Region); err != nil {
+			return nil, fmt.Errorf("invalid KMS key name: %w", err)
+		}
+	}
+
+	if args.RetryPolicy != nil {
+		if err := validateRetryPolicy(args.RetryPolicy); err != nil {
+			return nil, fmt.Errorf("invalid retry policy: %w", err)
+		}
+	}
+	if len(args.Jobs) > 0 {
+		if err := validateBatchJobs(args); err != nil {
+			return nil, fmt.Errorf("invalid jobs: %w", err)
+		}
+	}
+	if args.WaitForCompletion != nil {
+		if args.WaitForCompletion.Timeout == 0 {
+			args.WaitForCompletion.Timeout = 24 * time.Hour
+		}
+		if args.WaitForCompletion.PollInterval == 0 {
+			args.WaitForCompletion.PollInterval = time.Minute
+		}
+		if args.WaitForCompletion.Timeout < 0 || args.WaitForCompletion.PollInterval < 0 {
+			return nil, fmt.Errorf("wait for completion timeout and poll interval must be positive")
+		}
+	}
 
 	if args.ModelBucketBasePath == "" {
 		args.ModelBucketBasePath = "model"
 	}
 
 	// Model input data defaults
+	if args.InputBigQueryURI != "" && args.InputFormat == "" {
+		args.InputFormat = bigQueryFormat
+	}
+	if args.InputFormat == bigQueryFormat {
+		if args.InputBigQueryURI == "" {
+			return nil, fmt.Errorf("input BigQuery URI is required when input format is %s", bigQueryFormat)
+		}
+		if _, err := parseBigQueryTableURI(args.InputBigQueryURI); err != nil {
+			return nil, fmt.Errorf("invalid input BigQuery URI: %w", err)
+		}
+	} else if args.InputBigQueryURI != "" {
+		return nil, fmt.Errorf("input format must be %s when input BigQuery URI is set", bigQueryFormat)
+	}
+	if format, ok := plainString(args.OutputFormat); ok && format == bigQueryFormat && args.OutputBigQuery == nil {
+		return nil, fmt.Errorf("output BigQuery dataset is required when output format is %s", bigQueryFormat)
+	}
+	if len(args.InputURIs) > 0 {
+		if args.InputFormat == bigQueryFormat {
+			return nil, fmt.Errorf("input URIs cannot be used with input format %s", bigQueryFormat)
+		}
+		if _, err := inputBucketNames(args.InputURIs); err != nil {
+			return nil, fmt.Errorf("invalid input URIs: %w", err)
+		}
+	}
+	if args.InstanceConfig != nil {
+		if err := validateInstanceConfig(args.InstanceConfig); err != nil {
+			return nil, fmt.Errorf("invalid instance config: %w", err)
+		}
+	}
 	if args.InputDataPath == "" {
 		args.InputDataPath = "inputs"
 	}
@@ -97,6 +288,104 @@ func NewAIBatch(ctx *pulumi.Context, name string, args *AIBatchArgs, opts ...pul
 	if args.InputFormat == "" {
 		args.InputFormat = "jsonl"
 	}
+	var inputShards []inputShard
+	if args.Sharding != nil {
+		if err := validateSharding(args); err != nil {
+			return nil, fmt.Errorf("invalid sharding: %w", err)
+		}
+		var err error
+		inputShards, err = splitInputShards(args.InputDataPath, args.InputFileName, args.InputFormat, args.Sharding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to shard input data: %w", err)
+		}
+	}
+	if args.Schedule != nil {
+		if err := validateSchedule(args); err != nil {
+			return nil, fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
+	if args.EventTrigger != nil {
+		if err := validateEventTrigger(args); err != nil {
+			return nil, fmt.Errorf("invalid event trigger: %w", err)
+		}
+	}
+	if args.Notifications != nil {
+		if err := validateNotifications(args.Notifications); err != nil {
+			return nil, fmt.Errorf("invalid notifications: %w", err)
+		}
+	}
+	if args.RetentionPolicy != nil {
+		if err := validateRetentionPolicy(args.RetentionPolicy); err != nil {
+			return nil, fmt.Errorf("invalid retention policy: %w", err)
+		}
+	}
+	var costEstimate *CostEstimate
+	if args.CostEstimate != nil {
+		var err error
+		costEstimate, err = EstimateCost(args, args.CostEstimate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to estimate cost: %w", err)
+		}
+		// Fail before anything is launched
+		if err := checkBudget(costEstimate, args.CostEstimate.BudgetUSD); err != nil {
+			return nil, err
+		}
+	}
+	// Predictions output defaults
+	outputFormat := setDefaultString(args.OutputFormat, "jsonl")
+	if args.OutputBigQuery == nil {
+		// Otherwise predictions would be written as files under the output bucket
+		outputFormat = outputFormat.ApplyT(func(format string) (string, error) {
+			if format == bigQueryFormat {
+				return "", fmt.Errorf("output BigQuery dataset is required when output format is %s", bigQueryFormat)
+			}
+
+			return format, nil
+		}).(pulumi.StringOutput)
+	}
+	if args.OutputBigQuery != nil {
+		if args.OutputBigQuery.Project == "" {
+			args.OutputBigQuery.Project = args.Project
+		}
+		if args.OutputBigQuery.Location == "" {
+			args.OutputBigQuery.Location = args.Region
+		}
+		outputFormat = pulumi.String(bigQueryFormat).ToStringOutput()
+	}
+
+	runIDStrategy := args.RunIDStrategy
+	if runIDStrategy == nil && args.RetryPolicy == nil {
+		runIDStrategy = TimestampRunID{}
+	}
+	var runID string
+	if runIDStrategy != nil {
+		var err error
+		runID, err = resolveRunID(runIDStrategy, args)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate run ID: %w", err)
+		}
+	}
+
+	inputDataTargetDir := "inputs"
+	outputDataPath := setDefaultString(args.OutputDataPath, "predictions/")
+	if args.VersionedRunPrefixes {
+		if runID == "" {
+			return nil, fmt.Errorf("versioned run prefixes require a run ID strategy")
+		}
+		inputDataTargetDir = path.Join(inputDataTargetDir, runID)
+		outputDataPath = outputDataPath.ApplyT(func(prefix string) string {
+			return subPrefix(prefix, runID)
+		}).(pulumi.StringOutput)
+	}
+
+	// Instances are read straight from BigQuery or existing objects, there is nothing to upload
+	inputDataLocalDir := args.InputDataPath
+	// Each job uploads its own inputs when there are several
+	if args.InputFormat == bigQueryFormat || len(args.InputURIs) > 0 || len(args.Jobs) > 0 || len(inputShards) > 0 {
+		inputDataLocalDir = ""
+	}
+
+	predictRoute, healthRoute := containerRoutes(args.ContainerSpec, pulumi.String("/predict").ToStringOutput(), pulumi.String("/health").ToStringOutput())
 
 	AIBatch := &AIBatch{
 		Namer:                             namer.New(name, namer.WithReplace()),
@@ -111,32 +400,64 @@ func NewAIBatch(ctx *pulumi.Context, name string, args *AIBatchArgs, opts ...pul
 
 		// Default to the latest TensorFlow 2.15 CPU prediction container
 		ModelImageURL:    setDefaultString(args.ModelImageURL, "us-docker.pkg.dev/vertex-ai/prediction/tf2-cpu.2-15:latest"),
-		MachineType:      setDefaultString(args.MachineType, "n1-highmem-4"),
+		PredictRoute:     predictRoute,
+		HealthRoute:      healthRoute,
+		ContainerSpec:    args.ContainerSpec,
+		MachineType:      setDefaultString(args.MachineType, defaultMachineType),
 		JobDisplayName:   setDefaultString(args.JobDisplayName, name),
 		ModelDisplayName: setDefaultString(args.ModelDisplayName, name+"-model"),
+		RunID:            runID,
 
 		// Model input data
 		InputDataPath: pulumi.String(args.InputDataPath).ToStringOutput(),
 		InputFormat:   pulumi.String(args.InputFormat).ToStringOutput(),
 		InputFileName: pulumi.String(args.InputFileName).ToStringOutput(),
 
+		InputBigQueryURI: args.InputBigQueryURI,
+		InputURIs:        args.InputURIs,
+		InstanceConfig:   args.InstanceConfig,
+		ModelParameters:  modelParameters,
+
+		GenerateExplanation: args.GenerateExplanation,
+		ExplanationSpec:     args.ExplanationSpec,
+
 		// Batch prediction job specific defaults
-		OutputDataPath:       setDefaultString(args.OutputDataPath, "predictions/"),
-		OutputFormat:         setDefaultString(args.OutputFormat, "jsonl"),
-		StartingReplicaCount: setDefaultInt(args.StartingReplicaCount, 1),
-		MaxReplicaCount:      setDefaultInt(args.MaxReplicaCount, 3),
+		OutputDataPath:       outputDataPath,
+		OutputFormat:         outputFormat,
+		OutputBigQuery:       args.OutputBigQuery,
+		StartingReplicaCount: setDefaultInt(args.StartingReplicaCount, defaultStartingReplicaCount),
+		MaxReplicaCount:      setDefaultInt(args.MaxReplicaCount, defaultMaxReplicaCount),
 		BatchSize:            setDefaultInt(args.BatchSize, 0), // 0 means auto-configure
-		AcceleratorType:      setDefaultString(args.AcceleratorType, "ACCELERATOR_TYPE_UNSPECIFIED"),
+		AcceleratorType:      setDefaultString(args.AcceleratorType, defaultAcceleratorType),
 		AcceleratorCount:     setDefaultInt(args.AcceleratorCount, 1),
+		TpuTopology:          setDefaultString(args.TpuTopology, ""),
+		Spot:                 args.Spot,
+		ReservationAffinity:  args.ReservationAffinity,
 		Labels:               args.Labels,
 
 		// Initial job state until we create the job
 		jobState: pulumi.String("").ToStringOutput(),
 
-		inputDataLocalDir:  args.InputDataPath,
-		inputDataTargetDir: "inputs", // Upload input data to a separate "inputs" directory in bucket
+		inputDataLocalDir:  inputDataLocalDir,
+		inputShards:        inputShards,
+		inputDataTargetDir: inputDataTargetDir, // Upload input data to a separate "inputs" directory in bucket
 
 		retainJobOnDelete: args.RetainJobOnDelete,
+		batchJobClient:    args.BatchJobClient,
+		waitForCompletion: args.WaitForCompletion,
+		retryPolicy:       args.RetryPolicy,
+
+		versionedRunPrefixes: args.VersionedRunPrefixes,
+		compareModels:        len(args.CompareModels) > 0,
+		schedule:             args.Schedule,
+		eventTrigger:         args.EventTrigger,
+		notifications:        args.Notifications,
+		retentionPolicy:      args.RetentionPolicy,
+		costEstimate:         costEstimate,
+
+		customerManagedEncryption: args.KmsKeyName != "" || args.CreateKmsKey,
+		// once the TPU, attached accelerator and model profile defaults are applied
+		unmodeledAcceleratorType: !isProviderAcceleratorType(args.AcceleratorType),
 	}
 
 	err := ctx.RegisterComponentResource("pulumi-ai-batch:gcp:AIBatch", name, AIBatch, opts...)
@@ -156,13 +477,87 @@ func NewAIBatch(ctx *pulumi.Context, name string, args *AIBatchArgs, opts ...pul
 		"vertex_ai_batch_job_id":                      AIBatch.batchPredictionJob.ID(),
 		"vertex_ai_batch_job_name":                    AIBatch.batchPredictionJob.Name,
 		"vertex_ai_batch_job_display_name":            AIBatch.batchPredictionJob.DisplayName,
-		"vertex_ai_batch_job_state":                   AIBatch.batchPredictionJob.State,
+		"vertex_ai_batch_job_state":                   AIBatch.jobState,
 		"vertex_ai_batch_artifacts_bucket_name":       AIBatch.artifactsBucket.Name,
 		"vertex_ai_batch_uploaded_model_files":        AIBatch.uploadedModelFiles,
-		"vertex_ai_batch_input_data_uri":              AIBatch.InputDataPath,
 		"vertex_ai_batch_output_data_uri_prefix":      AIBatch.OutputDataPath,
 	}
 
+	// The local input data path is only read when nothing else is
+	switch {
+	case AIBatch.InputBigQueryURI != "":
+		outputs["vertex_ai_batch_input_bigquery_uri"] = pulumi.String(AIBatch.InputBigQueryURI)
+	case len(AIBatch.InputURIs) > 0:
+		outputs["vertex_ai_batch_input_uris"] = pulumi.ToStringArray(AIBatch.InputURIs)
+	default:
+		outputs["vertex_ai_batch_input_data_uri"] = AIBatch.InputDataPath
+	}
+	if AIBatch.OutputBigQuery != nil {
+		outputs["vertex_ai_batch_output_bigquery_uri"] = AIBatch.outputBigQueryURI
+		if AIBatch.jobWaiter != nil {
+			outputs["vertex_ai_batch_output_bigquery_table"] = AIBatch.GetOutputBigQueryTable()
+		}
+	}
+	if AIBatch.jobWaiter != nil {
+		outputs["vertex_ai_batch_job_error_message"] = AIBatch.jobWaiter.ErrorMessage
+		outputs["vertex_ai_batch_job_successful_count"] = AIBatch.jobWaiter.SuccessfulCount
+		outputs["vertex_ai_batch_job_failed_count"] = AIBatch.jobWaiter.FailedCount
+		outputs["vertex_ai_batch_job_incomplete_count"] = AIBatch.jobWaiter.IncompleteCount
+	}
+	if AIBatch.RunID != "" {
+		outputs["vertex_ai_batch_run_id"] = pulumi.String(AIBatch.RunID)
+	}
+	if AIBatch.versionedRunPrefixes {
+		outputs["vertex_ai_batch_run_input_uris"] = AIBatch.runInputURIs
+		outputs["vertex_ai_batch_run_output_uri"] = AIBatch.runOutputURI
+	}
+	if len(args.Jobs) > 0 || len(args.CompareModels) > 0 {
+		jobNames := pulumi.StringMap{}
+		jobStates := pulumi.StringMap{}
+		for _, spec := range AIBatch.jobSpecs {
+			jobNames[spec.name] = spec.job.Name
+			jobStates[spec.name] = spec.state
+		}
+		outputs["vertex_ai_batch_jobs"] = jobNames
+		outputs["vertex_ai_batch_job_states"] = jobStates
+	}
+	if len(AIBatch.inputShards) > 0 {
+		outputs["vertex_ai_batch_shard_job_names"] = AIBatch.GetShardJobNames()
+		outputs["vertex_ai_batch_shard_output_uris"] = AIBatch.GetShardOutputURIs()
+	}
+	if AIBatch.compareModels {
+		outputs["vertex_ai_batch_comparison_models"] = AIBatch.comparisonModels()
+		outputs["vertex_ai_batch_comparison_output_uris"] = AIBatch.GetComparisonOutputURIs()
+	}
+	if AIBatch.scheduler != nil {
+		outputs["vertex_ai_batch_schedule_workflow_name"] = AIBatch.scheduleWorkflow.Name
+		outputs["vertex_ai_batch_scheduler_job_name"] = AIBatch.scheduler.Name
+	}
+	if AIBatch.eventarcTrigger != nil {
+		outputs["vertex_ai_batch_event_workflow_name"] = AIBatch.eventWorkflow.Name
+		outputs["vertex_ai_batch_event_trigger_name"] = AIBatch.eventarcTrigger.Name
+	}
+	if AIBatch.costEstimate != nil {
+		outputs["vertex_ai_batch_estimated_cost"] = AIBatch.costEstimate.outputs()
+	}
+	if AIBatch.retentionScheduler != nil {
+		outputs["vertex_ai_batch_retention_workflow_name"] = AIBatch.retentionWorkflow.Name
+		outputs["vertex_ai_batch_retention_scheduler_job_name"] = AIBatch.retentionScheduler.Name
+	}
+	if AIBatch.notificationsTopic != nil {
+		outputs["vertex_ai_batch_notifications_topic_name"] = AIBatch.notificationsTopic.Name
+	}
+	if AIBatch.workflowServiceAccount != nil {
+		outputs["vertex_ai_batch_workflow_service_account_email"] = AIBatch.workflowServiceAccount.Email
+	}
+	if AIBatch.retryPolicy != nil {
+		outputs["vertex_ai_batch_job_attempt"] = pulumi.Int(AIBatch.jobAttempt)
+		outputs["vertex_ai_batch_job_attempts"] = AIBatch.GetJobAttempts()
+	}
+	if AIBatch.customerManagedEncryption {
+		outputs["vertex_ai_batch_kms_key_name"] = AIBatch.KmsKeyName
+	}
+
 	// Add model deployment specific outputs only if model deployment exists
 	if AIBatch.modelDeployment != nil {
 		outputs["vertex_ai_batch_model_image_url"] = AIBatch.modelDeployment.ModelImageUrl
@@ -173,6 +568,11 @@ func NewAIBatch(ctx *pulumi.Context, name string, args *AIBatchArgs, opts ...pul
 		outputs["vertex_ai_batch_model_prediction_output_schema_uri"] = AIBatch.modelDeployment.ModelPredictionOutputSchemaUri
 		outputs["vertex_ai_batch_model_prediction_behavior_schema_uri"] = AIBatch.modelDeployment.ModelPredictionBehaviorSchemaUri
 	}
+	// or the model uploaded by the gcp-ai-batch provider
+	if AIBatch.managedModel != nil {
+		outputs["vertex_ai_batch_model_image_url"] = AIBatch.ModelImageURL
+		outputs["vertex_ai_batch_model_name"] = AIBatch.managedModel.Name
+	}
 
 	err = ctx.RegisterResourceOutputs(AIBatch, outputs)
 	if err != nil {
@@ -186,57 +586,229 @@ func NewAIBatch(ctx *pulumi.Context, name string, args *AIBatchArgs, opts ...pul
 func (v *AIBatch) deploy(ctx *pulumi.Context, args *AIBatchArgs) error {
 
 	isCustomModel := args.ModelDir != ""
+	// The single job of the component, or one per job spec or compared model
+	v.jobSpecs = v.batchJobSpecs(args.Jobs, args.CompareModels)
+	if v.retentionPolicy != nil {
+		// the cleanup finds the jobs of the component by label
+		v.labelComponentJobs(v.jobSpecs)
+	}
+	runsCustomModels := isCustomModel || slices.ContainsFunc(v.jobSpecs, func(spec *batchJobSpec) bool {
+		return spec.customModel != nil
+	})
+	inputURIs := batchJobInputURIs(v.jobSpecs)
+	if v.schedule != nil {
+		// scheduled runs read the same buckets, under another prefix
+		inputURIs = append(inputURIs, v.schedule.InputURIs...)
+	}
+
+	if v.retryPolicy != nil {
+		// Pick up where the previous update left off
+		jobAttempts, err := v.loadJobAttempts(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load job attempts: %w", err)
+		}
+		v.jobAttempts = jobAttempts
+		v.jobAttempt = nextJobAttempt(ctx, jobAttempts, v.RunID, v.retryPolicy)
+	}
+
+	if args.QuotaPreflight != nil {
+		// Fail before anything is launched, once the job names of the attempt are known
+		if err := v.runQuotaPreflight(ctx, args); err != nil {
+			return fmt.Errorf("quota preflight failed: %w", err)
+		}
+	}
+
+	if v.customerManagedEncryption {
+		// Grant the service agents access to the key before any encrypted resource is created
+		kmsKeyName, err := v.setupEncryption(ctx, args)
+		if err != nil {
+			return fmt.Errorf("failed to setup encryption: %w", err)
+		}
+		v.KmsKeyName = kmsKeyName
+	}
+
+	if args.OutputBigQuery != nil {
+		// Create the predictions dataset first so the model account can be granted access to it
+		outputBigQueryURI, err := v.setupOutputBigQueryDataset(ctx, args.OutputBigQuery, args.Labels)
+		if err != nil {
+			return fmt.Errorf("failed to setup output BigQuery dataset: %w", err)
+		}
+		v.outputBigQueryURI = outputBigQueryURI
+	}
 
 	var modelServiceAccountEmail pulumi.StringOutput
-	if isCustomModel {
+	if runsCustomModels {
 		// Custom model. Run it with custom GSA.
 
 		// Create service account for the model deployment
-		modelServiceAccountEmail, iamMembers, repoIamMember, err := v.setupCustomModelIAM(ctx, args)
+		serviceAccountEmail, iamMembers, repoIamMember, err := v.setupCustomModelIAM(ctx, args)
 		if err != nil {
 			return fmt.Errorf("failed to setup custom model IAM: %w", err)
 		}
-		v.modelServiceAccountEmail = modelServiceAccountEmail
+		modelServiceAccountEmail = serviceAccountEmail
+		v.modelServiceAccountEmail = serviceAccountEmail
 		v.iamMembers = iamMembers
 		v.repoIamMember = repoIamMember
+
+		if args.InputFormat == bigQueryFormat || args.OutputBigQuery != nil {
+			bigQueryIamMembers, bigQueryProjectMembers, err := v.grantBigQueryIAMAccess(ctx, "model-sa",
+				pulumi.Sprintf("serviceAccount:%s", serviceAccountEmail), args)
+			if err != nil {
+				return fmt.Errorf("failed to grant BigQuery access: %w", err)
+			}
+			v.bigQueryIamMembers = append(v.bigQueryIamMembers, bigQueryIamMembers...)
+			v.iamMembers = append(v.iamMembers, bigQueryProjectMembers...)
+		}
+
+		if len(inputURIs) > 0 {
+			inputBucketMembers, err := v.grantInputBucketsIAMAccess(ctx, "model-sa",
+				pulumi.Sprintf("serviceAccount:%s", serviceAccountEmail), inputURIs)
+			if err != nil {
+				return fmt.Errorf("failed to grant input buckets access: %w", err)
+			}
+			v.inputBucketMembers = append(v.inputBucketMembers, inputBucketMembers...)
+		}
+	}
+	// Models from the garden have to run with the default agent GSA, otherwise the internal endpoint
+	// automation fails with missing permissions ('storage.objects.list') error on bucket
+	// "vertex-model-garden-restricted-us". The agent reads the inputs and writes the predictions instead
+	// of the model SA.
+	runsGardenModels := !isCustomModel && slices.ContainsFunc(v.jobSpecs, func(spec *batchJobSpec) bool {
+		return spec.customModel == nil
+	})
+	usesBigQuery := args.InputFormat == bigQueryFormat || args.OutputBigQuery != nil
+	if runsGardenModels && (len(inputURIs) > 0 || usesBigQuery) {
+		vertexAgent, err := v.vertexServiceAgent(ctx)
+		if err != nil {
+			return err
+		}
+
+		if usesBigQuery {
+			bigQueryIamMembers, bigQueryProjectMembers, err := v.grantBigQueryIAMAccess(ctx, "vertex-agent", vertexAgent.Member, args)
+			if err != nil {
+				return fmt.Errorf("failed to grant the Vertex AI service agent BigQuery access: %w", err)
+			}
+			v.bigQueryIamMembers = append(v.bigQueryIamMembers, bigQueryIamMembers...)
+			v.iamMembers = append(v.iamMembers, bigQueryProjectMembers...)
+		}
+
+		if len(inputURIs) > 0 {
+			inputBucketMembers, err := v.grantInputBucketsIAMAccess(ctx, "vertex-agent", vertexAgent.Member, inputURIs)
+			if err != nil {
+				return fmt.Errorf("failed to grant the Vertex AI service agent input buckets access: %w", err)
+			}
+			v.inputBucketMembers = append(v.inputBucketMembers, inputBucketMembers...)
+		}
 	}
-	// Else, it's a model from the garden. We have to run it with the default agent GSA,
-	// otherwise the internal endpoint automation fails with missing permissions
-	// ('storage.objects.list') error on bucket "vertex-model-garden-restricted-us".
 
 	// Upload model artifacts (including schemas) to bucket
 	modelArtifactsURI, uploadedModelArtifacts, err := v.setupModelBucket(ctx, args.ModelDir, args.ModelBucketBasePath, args.Labels)
 	if err != nil {
 		return fmt.Errorf("failed to upload model to bucket: %w", err)
 	}
+	v.modelArtifactsURI = modelArtifactsURI
 
 	// Upload input data to bucket
-	inputDataBucketURI, uploadedDataObjects, err := v.uploadInputDataToBucket(ctx, v.inputDataLocalDir, v.inputDataTargetDir)
-	if err != nil {
-		return fmt.Errorf("failed to upload input data to bucket: %w", err)
+	var uploadedDataObjects []pulumi.Resource
+	for _, spec := range v.jobSpecs {
+		inputDataBucketURI, uploadedJobDataObjects, err := v.uploadInputDataToBucket(ctx, spec)
+		if err != nil {
+			return fmt.Errorf("failed to upload input data to bucket: %w", err)
+		}
+		spec.inputDataBucketURI = inputDataBucketURI
+		uploadedDataObjects = append(uploadedDataObjects, uploadedJobDataObjects...)
 	}
 
-	// Collect uploaded data file names for outputs
-	v.uploadedModelFiles = collectBucketObjectNames(uploadedModelArtifacts, uploadedDataObjects)
-
-	var modelDeployment *vertexmodeldeployment.VertexModelDeployment
 	if isCustomModel {
-		// Upload the model to the model registry and get a model ID for the job
-		modelDeployment, err = v.deployModel(ctx, modelArtifactsURI, modelServiceAccountEmail, uploadedModelArtifacts)
+		// Upload the model to the model registry and get a model ID for the jobs
+		registeredModel, err := v.registerModel(ctx, v.defaultCustomModel(), modelArtifactsURI, modelServiceAccountEmail, uploadedModelArtifacts)
 		if err != nil {
 			return fmt.Errorf("failed to deploy model /o\\: %w", err)
 		}
-		v.modelDeployment = modelDeployment
+		v.modelDeployment = registeredModel.deployment
+		v.managedModel = registeredModel.managed
+		for _, spec := range v.jobSpecs {
+			spec.registeredModel = registeredModel
+		}
 	}
 
-	// Create the batch prediction job
-	batchPredictionJob, err := v.createBatchPredictionJob(ctx, modelDeployment, inputDataBucketURI, modelServiceAccountEmail)
+	// Each custom model of a comparison is registered on its own
+	uploadedComparedArtifacts, err := v.deployComparedModels(ctx, modelServiceAccountEmail)
 	if err != nil {
-		return fmt.Errorf("failed to create batch prediction job: %w", err)
+		return fmt.Errorf("failed to deploy compared models: %w", err)
+	}
+	uploadedModelArtifacts = append(uploadedModelArtifacts, uploadedComparedArtifacts...)
+
+	// Collect uploaded data file names for outputs
+	v.uploadedModelFiles = collectBucketObjectNames(uploadedModelArtifacts, uploadedDataObjects)
+
+	// Create the batch prediction jobs, all sharing the model
+	for _, spec := range v.jobSpecs {
+		batchPredictionJob, err := v.createBatchPredictionJob(ctx, spec, modelServiceAccountEmail)
+		if err != nil {
+			return fmt.Errorf("failed to create batch prediction job: %w", err)
+		}
+		// track the job state to retry on failure
+		spec.job = batchPredictionJob
+		spec.state = batchPredictionJob.State
+
+		if v.waitForCompletion != nil {
+			jobWaiter, err := v.waitForJobCompletion(ctx, spec, v.waitForCompletion)
+			if err != nil {
+				return fmt.Errorf("failed to wait for batch prediction job: %w", err)
+			}
+			spec.waiter = jobWaiter
+			spec.state = jobWaiter.State
+		}
 	}
-	// track the job state to retry on failure
+
+	// The first job stands for the component
+	firstJob := v.jobSpecs[0]
+	batchPredictionJob := firstJob.job
 	v.batchPredictionJob = batchPredictionJob
-	v.jobState = batchPredictionJob.State
+	v.jobState = firstJob.state
+	v.jobWaiter = firstJob.waiter
+	v.runInputURIs = firstJob.runInputURIs
+	v.runOutputURI = firstJob.runOutputURI
+
+	if v.schedule != nil {
+		err = v.createSchedule(ctx, firstJob, v.schedule)
+		if err != nil {
+			return fmt.Errorf("failed to schedule batch prediction jobs: %w", err)
+		}
+	}
+	if v.eventTrigger != nil {
+		err = v.createEventTrigger(ctx, firstJob, v.eventTrigger)
+		if err != nil {
+			return fmt.Errorf("failed to trigger batch prediction jobs on new inputs: %w", err)
+		}
+	}
+	if v.notifications != nil {
+		err = v.setupNotifications(ctx, v.notifications)
+		if err != nil {
+			return fmt.Errorf("failed to setup notifications: %w", err)
+		}
+	}
+	if v.retentionPolicy != nil {
+		err = v.createRetentionPolicy(ctx, v.retentionPolicy)
+		if err != nil {
+			return fmt.Errorf("failed to create retention policy: %w", err)
+		}
+	}
+
+	if v.versionedRunPrefixes {
+		v.runIndex, err = v.saveRunIndex(ctx, batchPredictionJob)
+		if err != nil {
+			return fmt.Errorf("failed to save run index: %w", err)
+		}
+	}
+
+	if v.retryPolicy != nil {
+		_, err = v.saveJobAttempts(ctx, batchPredictionJob, v.jobState)
+		if err != nil {
+			return fmt.Errorf("failed to save job attempts: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -249,10 +821,71 @@ func (v *AIBatch) GetModelServiceAccountEmail() pulumi.StringOutput {
 }
 
 // GetBatchPredictionJob returns the Vertex AI Batch Prediction Job resource.
+// When Jobs is set, it returns the job of the first spec, see GetBatchJob.
 func (v *AIBatch) GetBatchPredictionJob() *v1.BatchPredictionJob {
 	return v.batchPredictionJob
 }
 
+// GetBatchJob returns the batch prediction job of the job spec or compared model with the given name,
+// or nil if there is none.
+func (v *AIBatch) GetBatchJob(name string) *v1.BatchPredictionJob {
+	for _, spec := range v.jobSpecs {
+		if spec.name != "" && spec.name == name {
+			return spec.job
+		}
+	}
+
+	return nil
+}
+
+// GetBatchJobState returns the state of the batch prediction job of the job spec or compared model with the given name.
+// It is the final state when WaitForCompletion is set, otherwise the state right after submission.
+func (v *AIBatch) GetBatchJobState(name string) pulumi.StringOutput {
+	for _, spec := range v.jobSpecs {
+		if spec.name != "" && spec.name == name {
+			return spec.state
+		}
+	}
+
+	return pulumi.String("").ToStringOutput()
+}
+
+// GetComparisonOutputURIs maps each compared model to the URI of its predictions, when CompareModels is set.
+// Predictions written to BigQuery are mapped to their table with WaitForCompletion, and to an empty URI otherwise.
+func (v *AIBatch) GetComparisonOutputURIs() pulumi.StringMapOutput {
+	if !v.compareModels {
+		return pulumi.StringMap{}.ToStringMapOutput()
+	}
+
+	return v.comparisonOutputURIs()
+}
+
+// GetArtifactsBucket returns the bucket holding the model artifacts, inputs and predictions.
+func (v *AIBatch) GetArtifactsBucket() *storage.Bucket {
+	return v.artifactsBucket
+}
+
+// GetJobState returns the state of the batch prediction job.
+// It is the final state when WaitForCompletion is set, otherwise the state right after submission.
+func (v *AIBatch) GetJobState() pulumi.StringOutput {
+	return v.jobState
+}
+
+// GetJobAttempts returns the jobs launched by the component, oldest first, as maps with the
+// attempt number, the job name and the job state. Only tracked when RetryPolicy is set.
+func (v *AIBatch) GetJobAttempts() pulumi.ArrayOutput {
+	if v.retryPolicy == nil {
+		return pulumi.Array{}.ToArrayOutput()
+	}
+
+	return v.jobAttemptsOutput()
+}
+
+// GetJobWaiter returns the waiter of the batch prediction job, if WaitForCompletion is set.
+func (v *AIBatch) GetJobWaiter() *BatchJobWaiter {
+	return v.jobWaiter
+}
+
 // GetModelDeployment returns the Vertex AI Model Deployment resource.
 func (v *AIBatch) GetModelDeployment() *vertexmodeldeployment.VertexModelDeployment {
 	return v.modelDeployment
@@ -263,6 +896,104 @@ func (v *AIBatch) GetIAMMembers() []*projects.IAMMember {
 	return v.iamMembers
 }
 
+// GetInputBucketIAMMembers returns the IAM members granting the model SA or, for models from the garden,
+// the Vertex AI service agent read access to existing input buckets.
+func (v *AIBatch) GetInputBucketIAMMembers() []*storage.BucketIAMMember {
+	return v.inputBucketMembers
+}
+
+// GetOutputBigQueryTable returns the fully qualified predictions table ("project.dataset.table"),
+// if the predictions are written to BigQuery. Requires WaitForCompletion: the job names the table once it
+// starts writing, so the table is empty when the deployment does not wait for the job.
+func (v *AIBatch) GetOutputBigQueryTable() pulumi.StringOutput {
+	if v.OutputBigQuery == nil || v.jobWaiter == nil {
+		return pulumi.String("").ToStringOutput()
+	}
+
+	return v.jobWaiter.OutputBigQueryTable
+}
+
+// GetRunInputURIs returns the URIs the job reads the instances from.
+func (v *AIBatch) GetRunInputURIs() pulumi.StringArrayOutput {
+	return v.runInputURIs
+}
+
+// GetRunOutputURI returns the URI the job writes the predictions to: a GCS prefix, or a BigQuery dataset.
+func (v *AIBatch) GetRunOutputURI() pulumi.StringOutput {
+	return v.runOutputURI
+}
+
+// GetRunIndex returns the bucket object listing the runs, when versioned run prefixes are enabled.
+func (v *AIBatch) GetRunIndex() *storage.BucketObject {
+	return v.runIndex
+}
+
+// GetScheduleWorkflow returns the workflow submitting the scheduled jobs, if Schedule is set.
+func (v *AIBatch) GetScheduleWorkflow() *workflows.Workflow {
+	return v.scheduleWorkflow
+}
+
+// GetScheduler returns the Cloud Scheduler job starting the scheduled runs, if Schedule is set.
+func (v *AIBatch) GetScheduler() *cloudscheduler.Job {
+	return v.scheduler
+}
+
+// GetEventWorkflow returns the workflow submitting a job for every new input file, if EventTrigger is set.
+func (v *AIBatch) GetEventWorkflow() *workflows.Workflow {
+	return v.eventWorkflow
+}
+
+// GetEventTrigger returns the Eventarc trigger starting the event workflow, if EventTrigger is set.
+func (v *AIBatch) GetEventTrigger() *eventarc.Trigger {
+	return v.eventarcTrigger
+}
+
+// GetCostEstimate returns the estimated cost of the job, if CostEstimate is set.
+func (v *AIBatch) GetCostEstimate() *CostEstimate {
+	return v.costEstimate
+}
+
+// GetRetentionWorkflow returns the workflow deleting the jobs outside of the retention policy, if RetentionPolicy is set.
+func (v *AIBatch) GetRetentionWorkflow() *workflows.Workflow {
+	return v.retentionWorkflow
+}
+
+// GetRetentionScheduler returns the Cloud Scheduler job starting the cleanup workflow, if RetentionPolicy is set.
+func (v *AIBatch) GetRetentionScheduler() *cloudscheduler.Job {
+	return v.retentionScheduler
+}
+
+// GetNotificationsTopic returns the topic the job state changes and prediction writes are published to,
+// if Notifications is set.
+func (v *AIBatch) GetNotificationsTopic() *pubsub.Topic {
+	return v.notificationsTopic
+}
+
+// GetNotificationsSink returns the log sink publishing the notifications, if Notifications is set.
+func (v *AIBatch) GetNotificationsSink() *logging.ProjectSink {
+	return v.notificationsSink
+}
+
+// GetWebhookSubscriptions returns the push subscriptions of the webhooks, in the order of WebhookURLs.
+func (v *AIBatch) GetWebhookSubscriptions() []*pubsub.Subscription {
+	return v.webhookSubscriptions
+}
+
+// GetWorkflowServiceAccount returns the Service Account running the workflows of the component, if any.
+func (v *AIBatch) GetWorkflowServiceAccount() *serviceaccount.Account {
+	return v.workflowServiceAccount
+}
+
+// GetKmsCryptoKey returns the KMS key created for the component, if CreateKmsKey is set.
+func (v *AIBatch) GetKmsCryptoKey() *kms.CryptoKey {
+	return v.kmsCryptoKey
+}
+
+// GetKmsKeyIAMMembers returns the IAM members granting the service agents access to the KMS key.
+func (v *AIBatch) GetKmsKeyIAMMembers() []*kms.CryptoKeyIAMMember {
+	return v.kmsKeyIamMembers
+}
+
 // GetUploadedModelArtifacts returns the array of uploaded model artifact names.
 func (v *AIBatch) GetUploadedModelArtifacts() pulumi.StringArrayOutput {
 	return v.uploadedModelFiles
@@ -1,17 +1,31 @@
 package gcp_test
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	gcpeventarc "github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/eventarc"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gopkg.in/yaml.v3"
 
 	"github.com/davidmontoyago/pulumi-gcp-ai-batch/pkg/gcp"
+	"github.com/davidmontoyago/pulumi-gcp-ai-batch/pkg/gcp/config"
 )
 
 const (
@@ -76,11 +90,35 @@ required:
   - sequence_output
 additionalProperties: false
 	`
+	testModelBehaviorSchema = `
+---
+type: object
+properties:
+  batch_size:
+    type: integer
+    minimum: 1
+    maximum: 128
+  max_seq_length:
+    type: integer
+    minimum: 1
+    maximum: 512
+additionalProperties: false
+`
 )
 
 type AIBatchMocks struct {
-	mockFailedJob bool
-	t             *testing.T
+	mockFailedJob   bool
+	mockJobAttempts string
+	mockRunIndex    string
+	// Dataset the finished jobs report to have written the predictions to, if any.
+	mockOutputDataset string
+	t                 *testing.T
+
+	mu sync.Mutex
+	// Jobs registered with the gcp-ai-batch provider.
+	managedJobs []pulumi.MockResourceArgs
+	// Models registered with the gcp-ai-batch provider.
+	managedModels []pulumi.MockResourceArgs
 }
 
 func (m *AIBatchMocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
@@ -99,12 +137,15 @@ func (m *AIBatchMocks) NewResource(args pulumi.MockResourceArgs) (string, resour
 		outputs["email"] = args.Name + "@test-project.iam.gserviceaccount.com"
 		// Expected outputs: name, accountId, project, displayName, email
 	case "gcp:projects/iAMMember:IAMMember":
-		// Mock one of the expected roles - storage.objectViewer, logging.logWriter, or monitoring.metricWriter
-		outputs["member"] = "serviceAccount:test-user@example.com"
+		// the role and member of the binding are echoed from the inputs
 		outputs["project"] = testProjectName
 		// Expected outputs: role, member, project
 	case "google-native:aiplatform/v1:BatchPredictionJob":
 		outputs["name"] = args.Name
+		if args.ID != "" {
+			// jobs read into the stack by their full resource name
+			outputs["name"] = args.ID
+		}
 		outputs["project"] = testProjectName
 		outputs["location"] = testRegion
 		if m.mockFailedJob {
@@ -113,6 +154,14 @@ func (m *AIBatchMocks) NewResource(args pulumi.MockResourceArgs) (string, resour
 			outputs["state"] = "JOB_STATE_SUCCEEDED"
 		}
 		outputs["createTime"] = "2023-01-01T00:00:00Z"
+		if outputConfig, ok := args.Inputs["outputConfig"]; ok && outputConfig.IsObject() {
+			if destination, ok := outputConfig.ObjectValue()["bigqueryDestination"]; ok && destination.IsObject() {
+				outputs["outputInfo"] = map[string]interface{}{
+					"bigqueryOutputDataset": destination.ObjectValue()["outputUri"].StringValue(),
+					"bigqueryOutputTable":   "predictions_2023_01_01T00_00_00_000Z",
+				}
+			}
+		}
 		// Expected outputs: name, project, location, displayName, state, createTime
 	case "gcp:storage/bucket:Bucket":
 		outputs["name"] = args.Name
@@ -128,20 +177,145 @@ func (m *AIBatchMocks) NewResource(args pulumi.MockResourceArgs) (string, resour
 		outputs["project"] = testProjectName
 		outputs["service"] = args.Inputs["service"]
 		// Expected outputs: project, service
+	case "gcp:projects/serviceIdentity:ServiceIdentity":
+		outputs["email"] = "service-123456789@gcp-sa-aiplatform.iam.gserviceaccount.com"
+		outputs["member"] = "serviceAccount:service-123456789@gcp-sa-aiplatform.iam.gserviceaccount.com"
+		// Expected outputs: project, service, email, member
+	case "gcp:kms/cryptoKey:CryptoKey":
+		// Key IDs are the full key name
+		keyID := fmt.Sprintf("projects/%s/locations/%s/keyRings/test-keyring/cryptoKeys/%s",
+			testProjectName, testRegion, args.Inputs["name"].StringValue())
+
+		return keyID, resource.NewPropertyMapFromMap(outputs), nil
+	case "gcp:logging/projectSink:ProjectSink":
+		outputs["writerIdentity"] = "serviceAccount:service-123456789@gcp-sa-logging.iam.gserviceaccount.com"
+		// Expected outputs: name, destination, filter, writerIdentity
+	case "gcp-ai-batch:resources:BatchPredictionJob":
+		m.mu.Lock()
+		m.managedJobs = append(m.managedJobs, args)
+		m.mu.Unlock()
+
+		jobName := fmt.Sprintf("projects/%s/locations/%s/batchPredictionJobs/%s", testProjectName, testRegion, args.Name)
+		outputs["name"] = jobName
+
+		return jobName, resource.NewPropertyMapFromMap(outputs), nil
+	case "gcp-ai-batch:resources:Model":
+		m.mu.Lock()
+		m.managedModels = append(m.managedModels, args)
+		m.mu.Unlock()
+
+		modelName := fmt.Sprintf("projects/%s/locations/%s/models/%s", testProjectName, testRegion, args.Name)
+		outputs["name"] = modelName
+
+		return modelName, resource.NewPropertyMapFromMap(outputs), nil
 	case "gcp-vertex-model-deployment:resources:VertexModelDeployment":
 		outputs["projectId"] = testProjectName
 		outputs["deployedModelId"] = "test-deployed-model-id"
 		outputs["modelArtifactsBucketUri"] = "gs://test-bucket"
+		outputs["modelName"] = fmt.Sprintf("projects/%s/locations/%s/models/%s", testProjectName, testRegion, args.Name)
 	}
 
 	return args.Name + "_id", resource.NewPropertyMapFromMap(outputs), nil
 }
 
-func (m *AIBatchMocks) Call(_ pulumi.MockCallArgs) (resource.PropertyMap, error) {
-	// No function calls needed for basic vertex endpoint test
+func (m *AIBatchMocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	switch args.Token {
+	case "gcp:storage/getProjectServiceAccount:getProjectServiceAccount":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{
+			"emailAddress": "service-123456789@gs-project-accounts.iam.gserviceaccount.com",
+			"member":       "serviceAccount:service-123456789@gs-project-accounts.iam.gserviceaccount.com",
+		}), nil
+	case "google-native:aiplatform/v1:getBatchPredictionJob":
+		job := map[string]interface{}{
+			"name":  "projects/test-project/locations/us-central1/batchPredictionJobs/" + args.Args["batchPredictionJobId"].StringValue(),
+			"state": "JOB_STATE_SUCCEEDED",
+			"completionStats": map[string]interface{}{
+				"successfulCount": "98",
+				"failedCount":     "2",
+				"incompleteCount": "0",
+			},
+		}
+		if m.mockOutputDataset != "" {
+			job["outputInfo"] = map[string]interface{}{
+				"bigqueryOutputDataset": m.mockOutputDataset,
+				"bigqueryOutputTable":   "predictions_2023_01_01T00_00_00_000Z",
+			}
+		}
+		if m.mockFailedJob {
+			job["state"] = "JOB_STATE_FAILED"
+			job["error"] = map[string]interface{}{
+				"code":    3,
+				"message": "Model server never became ready",
+			}
+		}
+
+		return resource.NewPropertyMapFromMap(job), nil
+	case "gcp:storage/getBuckets:getBuckets":
+		// the artifacts bucket exists once the history of a previous update is there
+		var buckets []interface{}
+		if m.mockJobAttempts != "" || m.mockRunIndex != "" {
+			buckets = append(buckets, map[string]interface{}{"name": args.Args["prefix"]})
+		}
+
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"buckets": buckets}), nil
+	case "gcp:storage/getBucketObjects:getBucketObjects":
+		var objects []interface{}
+		if m.mockObjectContent(args.Args["prefix"].StringValue()) != "" {
+			objects = append(objects, map[string]interface{}{"name": args.Args["prefix"]})
+		}
+
+		return resource.NewPropertyMapFromMap(map[string]interface{}{"bucketObjects": objects}), nil
+	case "gcp:storage/getBucketObjectContent:getBucketObjectContent":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{
+			"bucket":  args.Args["bucket"],
+			"name":    args.Args["name"],
+			"content": m.mockObjectContent(args.Args["name"].StringValue()),
+		}), nil
+	case "gcp:bigquery/getDefaultServiceAccount:getDefaultServiceAccount":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{
+			"email":  "bq-123456789@bigquery-encryption.iam.gserviceaccount.com",
+			"member": "serviceAccount:bq-123456789@bigquery-encryption.iam.gserviceaccount.com",
+		}), nil
+	}
+
 	return resource.PropertyMap{}, nil
 }
 
+// managedJobRequests returns the Vertex AI requests of the jobs registered with the gcp-ai-batch provider.
+func (m *AIBatchMocks) managedJobRequests() []map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var requests []map[string]interface{}
+	for _, job := range m.managedJobs {
+		requests = append(requests, job.Inputs["request"].ObjectValue().Mappable())
+	}
+
+	return requests
+}
+
+// managedModelRequests returns the upload requests of the models registered with the gcp-ai-batch provider.
+func (m *AIBatchMocks) managedModelRequests() []map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var requests []map[string]interface{}
+	for _, model := range m.managedModels {
+		requests = append(requests, model.Inputs["request"].ObjectValue().Mappable())
+	}
+
+	return requests
+}
+
+// mockObjectContent returns the content of an object of the artifacts bucket left by a previous update.
+func (m *AIBatchMocks) mockObjectContent(objectName string) string {
+	if objectName == "runs/index.json" {
+		return m.mockRunIndex
+	}
+
+	return m.mockJobAttempts
+}
+
 // createTempModelDir creates a temporary directory with a dummy model file for testing
 func createTempModelDir(t *testing.T) string {
 	t.Helper()
@@ -887,71 +1061,3399 @@ func TestNewAIBatch_WithModelFromTheGarden(t *testing.T) {
 	}
 }
 
-func TestNewAIBatch_RequiredFields(t *testing.T) {
+func TestNewAIBatch_WithBigQueryInput(t *testing.T) {
 	t.Parallel()
 
-	tests := []struct {
-		name        string
-		args        *gcp.AIBatchArgs
-		expectedErr string
-	}{
-		{
-			name: "missing project",
-			args: &gcp.AIBatchArgs{
-				Region:                          testRegion,
-				ModelImageURL:                   pulumi.String("gcr.io/test-project/my-model:latest"),
-				MachineType:                     pulumi.String("n1-standard-2"),
-				ModelPredictionInputSchemaPath:  "input_schema.yaml",
-				ModelPredictionOutputSchemaPath: "output_schema.yaml",
-			},
-			expectedErr: "project is required",
-		},
-		{
-			name: "missing region",
-			args: &gcp.AIBatchArgs{
-				Project:                         testProjectName,
-				ModelImageURL:                   pulumi.String("gcr.io/test-project/my-model:latest"),
-				MachineType:                     pulumi.String("n1-standard-2"),
-				ModelPredictionInputSchemaPath:  "input_schema.yaml",
-				ModelPredictionOutputSchemaPath: "output_schema.yaml",
-			},
-			expectedErr: "region is required",
-		},
-		{
-			name: "missing both model directory and model name",
-			args: &gcp.AIBatchArgs{
-				Project:                         testProjectName,
-				Region:                          testRegion,
-				ModelImageURL:                   pulumi.String("gcr.io/test-project/my-model:latest"),
-				MachineType:                     pulumi.String("n1-standard-2"),
-				ModelPredictionInputSchemaPath:  "input_schema.yaml",
-				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+	tempModelDir := createTempModelDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputBigQueryURI:                "bq://features-project.features.customer_reviews",
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-bq-batch", args)
+		require.NoError(t, err)
+
+		inputFormatCh := make(chan string, 1)
+		defer close(inputFormatCh)
+		AIBatch.InputFormat.ApplyT(func(format string) error {
+			inputFormatCh <- format
+
+			return nil
+		})
+		assert.Equal(t, "bigquery", <-inputFormatCh, "Input format should default to 'bigquery' when a BigQuery URI is set")
+
+		// Verify no input data is uploaded, only the model artifacts
+		filesCh := make(chan []string, 1)
+		defer close(filesCh)
+		AIBatch.GetUploadedModelArtifacts().ApplyT(func(files []string) error {
+			filesCh <- files
+
+			return nil
+		})
+		files := <-filesCh
+		require.Len(t, files, 4, "Should have uploaded only the 4 model artifacts")
+		for _, file := range files {
+			assert.NotContains(t, file, "inputs/", "Should not upload input data in BigQuery mode: %s", file)
+		}
+
+		// Verify the job reads from BigQuery instead of GCS
+		batchJob := AIBatch.GetBatchPredictionJob()
+		require.NotNil(t, batchJob, "Batch prediction job should not be nil")
+
+		inputURICh := make(chan string, 1)
+		defer close(inputURICh)
+		batchJob.InputConfig.BigquerySource().InputUri().ApplyT(func(uri string) error {
+			inputURICh <- uri
+
+			return nil
+		})
+		assert.Equal(t, "bq://features-project.features.customer_reviews", <-inputURICh, "Input config should point to the BigQuery table")
+
+		// Verify the model service account can read the table
+		iamMembers := AIBatch.GetIAMMembers()
+		require.Len(t, iamMembers, 7, "Should have the 5 base IAM members plus bigquery.jobUser and bigquery.readSessionUser")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithBigQueryOutput(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			OutputBigQuery:                  &gcp.BigQueryOutputArgs{},
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-bq-output", args)
+		require.NoError(t, err)
+
+		outputFormatCh := make(chan string, 1)
+		defer close(outputFormatCh)
+		AIBatch.OutputFormat.ApplyT(func(format string) error {
+			outputFormatCh <- format
+
+			return nil
+		})
+		assert.Equal(t, "bigquery", <-outputFormatCh, "Output format should be 'bigquery' when writing to a dataset")
+
+		// Verify the job writes to the dataset created by the component
+		batchJob := AIBatch.GetBatchPredictionJob()
+		require.NotNil(t, batchJob, "Batch prediction job should not be nil")
+
+		outputURICh := make(chan string, 1)
+		defer close(outputURICh)
+		batchJob.OutputConfig.BigqueryDestination().OutputUri().ApplyT(func(uri string) error {
+			outputURICh <- uri
+
+			return nil
+		})
+		assert.Equal(t, "bq://test-project.test_bq_output_predictions", <-outputURICh, "Output config should point to the created dataset")
+
+		outputTableCh := make(chan string, 1)
+		defer close(outputTableCh)
+		AIBatch.GetOutputBigQueryTable().ApplyT(func(table string) error {
+			outputTableCh <- table
+
+			return nil
+		})
+		assert.Empty(t, <-outputTableCh, "Output table should only be known once the job finishes")
+
+		// Verify the model service account can run BigQuery jobs
+		iamMembers := AIBatch.GetIAMMembers()
+		require.Len(t, iamMembers, 6, "Should have the 5 base IAM members plus bigquery.jobUser")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithBigQueryOutputWaitForCompletion(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			OutputBigQuery:                  &gcp.BigQueryOutputArgs{},
+			CompareModels: []gcp.ComparedModelArgs{
+				{
+					Name:      "gemma",
+					ModelName: "publishers/google/models/gemma-2b-it",
+				},
+				{
+					Name:     "fine-tuned",
+					ModelDir: tempModelDir,
+				},
 			},
-			expectedErr: "one of model directory or model name is required",
-		},
-		{
-			name: "missing input schema path when using model directory",
-			args: &gcp.AIBatchArgs{
-				Project:                         testProjectName,
-				Region:                          testRegion,
-				ModelImageURL:                   pulumi.String("gcr.io/test-project/my-model:latest"),
-				ModelDir:                        "dummy-model-dir",
-				MachineType:                     pulumi.String("n1-standard-2"),
-				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			WaitForCompletion: &gcp.WaitForCompletionArgs{
+				Timeout:      time.Minute,
+				PollInterval: time.Millisecond,
 			},
-			expectedErr: "model prediction input schema path is required",
-		},
-		{
-			name: "missing output schema path when using model directory",
-			args: &gcp.AIBatchArgs{
-				Project:                        testProjectName,
-				Region:                         testRegion,
-				ModelImageURL:                  pulumi.String("gcr.io/test-project/my-model:latest"),
-				ModelDir:                       "dummy-model-dir",
-				MachineType:                    pulumi.String("n1-standard-2"),
-				ModelPredictionInputSchemaPath: "input_schema.yaml",
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-bq-output-wait", args)
+		require.NoError(t, err)
+
+		outputsCh := make(chan []interface{}, 1)
+		defer close(outputsCh)
+		pulumi.All(
+			AIBatch.GetOutputBigQueryTable(),
+			AIBatch.GetComparisonOutputURIs(),
+		).ApplyT(func(values []interface{}) error {
+			outputsCh <- values
+
+			return nil
+		})
+		outputs := <-outputsCh
+		assert.Equal(t, "test-project.test_bq_output_wait_predictions.predictions_2023_01_01T00_00_00_000Z", outputs[0],
+			"Output table should be read from the finished job and fully qualified for use in SQL")
+		assert.Equal(t, map[string]string{
+			"gemma":      "bq://test-project.test_bq_output_wait_predictions.predictions_2023_01_01T00_00_00_000Z",
+			"fine-tuned": "bq://test-project.test_bq_output_wait_predictions.predictions_2023_01_01T00_00_00_000Z",
+		}, outputs[1], "Compared models should be mapped to the tables of their finished jobs")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{
+		t:                 t,
+		mockOutputDataset: "bq://test-project.test_bq_output_wait_predictions",
+	}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithExistingInputURIs(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputURIs: []string{
+				"gs://reviews-pipeline/2025/*.jsonl",
+				"gs://reviews-pipeline/2026/*.jsonl",
+				"gs://support-tickets/export.jsonl",
 			},
-			expectedErr: "model prediction output schema path is required",
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-input-uris", args)
+		require.NoError(t, err)
+
+		// Verify no input data is uploaded, only the model artifacts
+		filesCh := make(chan []string, 1)
+		defer close(filesCh)
+		AIBatch.GetUploadedModelArtifacts().ApplyT(func(files []string) error {
+			filesCh <- files
+
+			return nil
+		})
+		require.Len(t, <-filesCh, 4, "Should have uploaded only the 4 model artifacts")
+
+		// Verify the job reads the existing objects
+		batchJob := AIBatch.GetBatchPredictionJob()
+		require.NotNil(t, batchJob, "Batch prediction job should not be nil")
+
+		inputConfigCh := make(chan []string, 1)
+		defer close(inputConfigCh)
+		batchJob.InputConfig.GcsSource().Uris().ApplyT(func(uris []string) error {
+			inputConfigCh <- uris
+
+			return nil
+		})
+		assert.Equal(t, args.InputURIs, <-inputConfigCh, "Input config should use the existing URIs as is")
+
+		// Verify read access is granted once per source bucket
+		bucketMembers := AIBatch.GetInputBucketIAMMembers()
+		require.Len(t, bucketMembers, 2, "Should grant read access to each unique source bucket")
+
+		bucketsCh := make(chan []string, 1)
+		defer close(bucketsCh)
+		pulumi.All(bucketMembers[0].Bucket, bucketMembers[1].Bucket).ApplyT(func(buckets []interface{}) error {
+			bucketsCh <- []string{buckets[0].(string), buckets[1].(string)}
+
+			return nil
+		})
+		assert.Equal(t, []string{"reviews-pipeline", "support-tickets"}, <-bucketsCh)
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_GardenModelWithExistingInputURIs(t *testing.T) {
+	t.Parallel()
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:   testProjectName,
+			Region:    testRegion,
+			ModelName: "publishers/google/models/gemma-2b-it",
+			InputURIs: []string{"gs://support-tickets/export.jsonl"},
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-garden-input-uris", args)
+		require.NoError(t, err)
+
+		// Garden models run with the Vertex AI service agent instead of a model SA
+		bucketMembers := AIBatch.GetInputBucketIAMMembers()
+		require.Len(t, bucketMembers, 1, "Should grant the Vertex AI service agent read access to the source bucket")
+
+		memberCh := make(chan []interface{}, 1)
+		defer close(memberCh)
+		pulumi.All(bucketMembers[0].Bucket, bucketMembers[0].Role, bucketMembers[0].Member).ApplyT(func(values []interface{}) error {
+			memberCh <- values
+
+			return nil
+		})
+		member := <-memberCh
+		assert.Equal(t, "support-tickets", member[0])
+		assert.Equal(t, "roles/storage.objectViewer", member[1])
+		assert.Equal(t, "serviceAccount:service-123456789@gcp-sa-aiplatform.iam.gserviceaccount.com", member[2])
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_GardenModelWithBigQuery(t *testing.T) {
+	t.Parallel()
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:          testProjectName,
+			Region:           testRegion,
+			ModelName:        "publishers/google/models/gemma-2b-it",
+			InputBigQueryURI: "bq://features-project.features.customer_reviews",
+			OutputBigQuery:   &gcp.BigQueryOutputArgs{},
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-garden-bigquery", args)
+		require.NoError(t, err)
+
+		// Garden models run with the Vertex AI service agent instead of a model SA
+		iamMembers := AIBatch.GetIAMMembers()
+		require.Len(t, iamMembers, 2, "Should grant the Vertex AI service agent bigquery.jobUser and bigquery.readSessionUser")
+
+		membersCh := make(chan []interface{}, 1)
+		defer close(membersCh)
+		pulumi.All(iamMembers[0].Role, iamMembers[0].Member, iamMembers[1].Role, iamMembers[1].Member).ApplyT(func(values []interface{}) error {
+			membersCh <- values
+
+			return nil
+		})
+		members := <-membersCh
+		assert.Equal(t, "roles/bigquery.jobUser", members[0])
+		assert.Equal(t, "serviceAccount:service-123456789@gcp-sa-aiplatform.iam.gserviceaccount.com", members[1])
+		assert.Equal(t, "roles/bigquery.readSessionUser", members[2])
+		assert.Equal(t, "serviceAccount:service-123456789@gcp-sa-aiplatform.iam.gserviceaccount.com", members[3])
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithInstanceConfig(t *testing.T) {
+	t.Parallel()
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:          testProjectName,
+			Region:           testRegion,
+			ModelName:        "publishers/google/models/gemma-2b-it",
+			InputBigQueryURI: "bq://features-project.features.customer_reviews",
+			InstanceConfig: &gcp.InstanceConfigArgs{
+				InstanceType:   "object",
+				KeyField:       "review_id",
+				ExcludedFields: []string{"customer_email", "customer_phone"},
+			},
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-instance-config", args)
+		require.NoError(t, err)
+
+		batchJob := AIBatch.GetBatchPredictionJob()
+		require.NotNil(t, batchJob, "Batch prediction job should not be nil")
+
+		instanceConfigCh := make(chan []interface{}, 1)
+		defer close(instanceConfigCh)
+		pulumi.All(
+			batchJob.InstanceConfig.InstanceType(),
+			batchJob.InstanceConfig.KeyField(),
+			batchJob.InstanceConfig.ExcludedFields(),
+			batchJob.InstanceConfig.IncludedFields(),
+		).ApplyT(func(values []interface{}) error {
+			instanceConfigCh <- values
+
+			return nil
+		})
+		instanceConfig := <-instanceConfigCh
+		assert.Equal(t, "object", instanceConfig[0], "Instance type should be passed to the job")
+		assert.Equal(t, "review_id", instanceConfig[1], "Key field should be passed to the job")
+		assert.Equal(t, []string{"customer_email", "customer_phone"}, instanceConfig[2], "Excluded fields should be passed to the job")
+		assert.Empty(t, instanceConfig[3], "Included fields should not be set")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithModelParameters(t *testing.T) {
+	t.Parallel()
+
+	tempInputDataDir := createTempInputDataDir(t)
+
+	temperature := 0.2
+	maxOutputTokens := 256
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:       testProjectName,
+			Region:        testRegion,
+			ModelName:     "publishers/meta/models/llama3-2@llama-3.2-3b-instruct",
+			InputDataPath: tempInputDataDir,
+			ModelParameters: &gcp.ModelParametersArgs{
+				Temperature:     &temperature,
+				MaxOutputTokens: &maxOutputTokens,
+				StopSequences:   []string{"</summary>"},
+			},
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-model-parameters", args)
+		require.NoError(t, err)
+
+		batchJob := AIBatch.GetBatchPredictionJob()
+		require.NotNil(t, batchJob, "Batch prediction job should not be nil")
+
+		parametersCh := make(chan map[string]interface{}, 1)
+		defer close(parametersCh)
+		batchJob.ModelParameters.ApplyT(func(parameters interface{}) error {
+			parametersCh <- parameters.(map[string]interface{})
+
+			return nil
+		})
+		parameters := <-parametersCh
+		assert.InDelta(t, 0.2, parameters["temperature"], 0.0001, "Temperature should be passed to the job")
+		assert.InDelta(t, 256, parameters["maxOutputTokens"], 0.0001, "Max output tokens should be passed to the job")
+		assert.Equal(t, []interface{}{"</summary>"}, parameters["stopSequences"], "Stop sequences should be passed to the job")
+		assert.NotContains(t, parameters, "topP", "Unset parameters should not be passed to the job")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithModelParametersValidatedBySchema(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	behaviorSchemaFile := filepath.Join(tempModelDir, "behavior_schema.yaml")
+	err := os.WriteFile(behaviorSchemaFile, []byte(testModelBehaviorSchema), 0600)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		parameters  map[string]interface{}
+		expectedErr string
+	}{
+		{
+			name:       "parameters match the behavior schema",
+			parameters: map[string]interface{}{"batch_size": 8, "max_seq_length": 256},
+		},
+		{
+			name:        "parameter out of range",
+			parameters:  map[string]interface{}{"batch_size": 500},
+			expectedErr: "model parameters do not match behavior schema",
+		},
+		{
+			name:        "parameter not in the behavior schema",
+			parameters:  map[string]interface{}{"temperature": 0.7},
+			expectedErr: "model parameters do not match behavior schema",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				args := &gcp.AIBatchArgs{
+					Project:                           testProjectName,
+					Region:                            testRegion,
+					ModelDir:                          tempModelDir,
+					ModelPredictionInputSchemaPath:    "input_schema.yaml",
+					ModelPredictionOutputSchemaPath:   "output_schema.yaml",
+					ModelPredictionBehaviorSchemaPath: "behavior_schema.yaml",
+					InputDataPath:                     tempInputDataDir,
+					ModelParameters: &gcp.ModelParametersArgs{
+						Custom: testCase.parameters,
+					},
+				}
+
+				_, err := gcp.NewAIBatch(ctx, "test-model-parameters-schema", args)
+				if testCase.expectedErr == "" {
+					assert.NoError(t, err)
+				} else {
+					require.Error(t, err)
+					assert.Contains(t, err.Error(), testCase.expectedErr)
+				}
+
+				return nil
+			}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+			assert.NoError(t, err, "Pulumi test should not fail")
+		})
+	}
+}
+
+func TestNewAIBatch_WithExplanations(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			GenerateExplanation:             true,
+			ExplanationSpec: &gcp.ExplanationSpecArgs{
+				Method: gcp.ExplanationMethodSampledShapley,
+				TopK:   3,
+				Metadata: &gcp.ExplanationMetadataArgs{
+					FeatureAttributionsSchemaPath: "attributions_schema.yaml",
+				},
+			},
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-explanations", args)
+		require.NoError(t, err)
+
+		batchJob := AIBatch.GetBatchPredictionJob()
+		require.NotNil(t, batchJob, "Batch prediction job should not be nil")
+
+		explanationCh := make(chan []interface{}, 1)
+		defer close(explanationCh)
+		pulumi.All(
+			batchJob.GenerateExplanation,
+			batchJob.ExplanationSpec.Parameters().SampledShapleyAttribution().PathCount(),
+			batchJob.ExplanationSpec.Parameters().TopK(),
+			batchJob.ExplanationSpec.Parameters().IntegratedGradientsAttribution().StepCount(),
+			batchJob.ExplanationSpec.Metadata().FeatureAttributionsSchemaUri(),
+		).ApplyT(func(values []interface{}) error {
+			explanationCh <- values
+
+			return nil
+		})
+		explanation := <-explanationCh
+		assert.Equal(t, true, explanation[0], "Explanations should be generated")
+		assert.Equal(t, 10, explanation[1], "Path count should default to 10 for sampled Shapley")
+		assert.Equal(t, 3, explanation[2], "Top K should be passed to the job")
+		assert.Equal(t, 0, explanation[3], "Integrated gradients should not be set")
+		assert.Equal(t, "gs://test-explanations-vertex-model-bucket/model/attributions_schema.yaml", explanation[4],
+			"Attributions schema should be read from the model artifacts")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithExplanationMetadata(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	mocks := &AIBatchMocks{t: t}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			GenerateExplanation:             true,
+			ExplanationSpec: &gcp.ExplanationSpecArgs{
+				Method: gcp.ExplanationMethodIntegratedGradients,
+				Metadata: &gcp.ExplanationMetadataArgs{
+					Inputs: map[string]gcp.ExplanationInputArgs{
+						"review": {InputTensorName: "text_input", Modality: "text"},
+					},
+					Outputs: map[string]gcp.ExplanationOutputArgs{
+						"sentiment": {DisplayNameMappingKey: "label"},
+					},
+					FeatureAttributionsSchemaPath: "attributions_schema.yaml",
+				},
+			},
+		}
+
+		_, err := gcp.NewAIBatch(ctx, "test-explanation-metadata", args)
+		require.NoError(t, err)
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks))
+	require.NoError(t, err)
+
+	requests := mocks.managedJobRequests()
+	require.Len(t, requests, 1, "Jobs with explanation metadata should be managed by the gcp-ai-batch provider")
+	assert.Equal(t, true, requests[0]["generateExplanation"], "Explanations should be generated")
+	assert.Equal(t, map[string]interface{}{
+		"parameters": map[string]interface{}{
+			"integratedGradientsAttribution": map[string]interface{}{"stepCount": float64(50)},
+		},
+		"metadata": map[string]interface{}{
+			"inputs": map[string]interface{}{
+				"review": map[string]interface{}{"inputTensorName": "text_input", "modality": "text"},
+			},
+			"outputs": map[string]interface{}{
+				"sentiment": map[string]interface{}{"displayNameMappingKey": "label"},
+			},
+			"featureAttributionsSchemaUri": "gs://test-explanation-metadata-vertex-model-bucket/model/attributions_schema.yaml",
+		},
+	}, requests[0]["explanationSpec"], "Input and output metadata should be passed to the job as objects")
+}
+
+func TestNewAIBatch_WithContainerSpecRoutes(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			ContainerSpec: &gcp.ContainerSpecArgs{
+				PredictRoute: pulumi.String("/v1/models/sentiment:predict"),
+			},
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-container-routes", args)
+		require.NoError(t, err)
+
+		modelDeployment := AIBatch.GetModelDeployment()
+		require.NotNil(t, modelDeployment, "Model deployment should not be nil")
+
+		routesCh := make(chan []interface{}, 1)
+		defer close(routesCh)
+		pulumi.All(
+			modelDeployment.PredictRoute.Elem(),
+			modelDeployment.HealthRoute.Elem(),
+		).ApplyT(func(values []interface{}) error {
+			routesCh <- values
+
+			return nil
+		})
+		routes := <-routesCh
+		assert.Equal(t, "/v1/models/sentiment:predict", routes[0], "Predict route should be passed to the model deployment")
+		assert.Equal(t, "/health", routes[1], "Health route should default to /health")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithFullContainerSpec(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	mocks := &AIBatchMocks{t: t}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			ContainerSpec: &gcp.ContainerSpecArgs{
+				HealthRoute:        pulumi.String("/ready"),
+				Env:                map[string]string{"HF_HOME": "/tmp/huggingface", "PREDICTOR": "bert"},
+				Ports:              []int{7080},
+				Command:            []string{"python", "-m", "server"},
+				Args:               []string{"--workers", "2"},
+				SharedMemorySizeMB: 1024,
+				StartupProbe: &gcp.ProbeArgs{
+					HTTPGetPath:      "/ready",
+					PeriodSeconds:    30,
+					FailureThreshold: 20,
+				},
+			},
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-full-container-spec", args)
+		require.NoError(t, err)
+
+		// the model deployment resource only supports the routes
+		assert.Nil(t, AIBatch.GetModelDeployment(), "Model should be uploaded by the gcp-ai-batch provider")
+
+		modelCh := make(chan string, 1)
+		defer close(modelCh)
+		AIBatch.GetBatchPredictionJob().Model.ApplyT(func(model string) error {
+			modelCh <- model
+
+			return nil
+		})
+		assert.Equal(t, "projects/test-project/locations/us-central1/models/test-full-container-spec-model", <-modelCh,
+			"Job should run the uploaded model")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks))
+	require.NoError(t, err)
+
+	requests := mocks.managedModelRequests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "test-full-container-spec-model-account@test-project.iam.gserviceaccount.com", requests[0]["serviceAccount"])
+
+	model, ok := requests[0]["model"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "test-full-container-spec-model", model["displayName"])
+
+	containerSpec, ok := model["containerSpec"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "us-docker.pkg.dev/vertex-ai/prediction/tf2-cpu.2-15:latest", containerSpec["imageUri"])
+	assert.Equal(t, "/predict", containerSpec["predictRoute"], "Predict route should default to /predict")
+	assert.Equal(t, "/ready", containerSpec["healthRoute"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "HF_HOME", "value": "/tmp/huggingface"},
+		map[string]interface{}{"name": "PREDICTOR", "value": "bert"},
+	}, containerSpec["env"], "Env should be sorted by name")
+	assert.Equal(t, []interface{}{map[string]interface{}{"containerPort": float64(7080)}}, containerSpec["ports"])
+	assert.Equal(t, []interface{}{"python", "-m", "server"}, containerSpec["command"])
+	assert.Equal(t, []interface{}{"--workers", "2"}, containerSpec["args"])
+	assert.Equal(t, float64(1024), containerSpec["sharedMemorySizeMb"])
+	assert.Equal(t, map[string]interface{}{
+		"httpGet":          map[string]interface{}{"path": "/ready"},
+		"periodSeconds":    float64(30),
+		"failureThreshold": float64(20),
+	}, containerSpec["startupProbe"])
+}
+
+func TestNewAIBatch_WithKmsKey(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	kmsKeyName := "projects/security-project/locations/us-central1/keyRings/data/cryptoKeys/vertex"
+
+	mocks := &AIBatchMocks{t: t}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			KmsKeyName:                      kmsKeyName,
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-kms-key", args)
+		require.NoError(t, err)
+
+		assert.Nil(t, AIBatch.GetKmsCryptoKey(), "No key should be created when a key name is set")
+
+		keyMembers := AIBatch.GetKmsKeyIAMMembers()
+		require.Len(t, keyMembers, 2, "Storage and Vertex AI service agents should be granted access to the key")
+
+		membersCh := make(chan []interface{}, 1)
+		defer close(membersCh)
+		pulumi.All(
+			keyMembers[0].CryptoKeyId,
+			keyMembers[0].Role,
+			keyMembers[0].Member,
+			keyMembers[1].Member,
+		).ApplyT(func(values []interface{}) error {
+			membersCh <- values
+
+			return nil
+		})
+		members := <-membersCh
+		assert.Equal(t, kmsKeyName, members[0], "Service agents should be granted access to the key")
+		assert.Equal(t, "roles/cloudkms.cryptoKeyEncrypterDecrypter", members[1])
+		assert.Equal(t, "serviceAccount:service-123456789@gs-project-accounts.iam.gserviceaccount.com", members[2],
+			"Cloud Storage service agent should be granted access to the key")
+		assert.Equal(t, "serviceAccount:service-123456789@gcp-sa-aiplatform.iam.gserviceaccount.com", members[3],
+			"Vertex AI service agent should be granted access to the key")
+
+		batchJob := AIBatch.GetBatchPredictionJob()
+		require.NotNil(t, batchJob, "Batch prediction job should not be nil")
+
+		encryptionCh := make(chan []interface{}, 1)
+		defer close(encryptionCh)
+		pulumi.All(
+			batchJob.EncryptionSpec.KmsKeyName(),
+			AIBatch.GetArtifactsBucket().Encryption.DefaultKmsKeyName().Elem(),
+		).ApplyT(func(values []interface{}) error {
+			encryptionCh <- values
+
+			return nil
+		})
+		encryption := <-encryptionCh
+		assert.Equal(t, kmsKeyName, encryption[0], "Job should be encrypted with the key")
+		assert.Equal(t, kmsKeyName, encryption[1], "Bucket should be encrypted with the key")
+
+		// the model deployment resource does not support an encryption spec
+		assert.Nil(t, AIBatch.GetModelDeployment(), "Model should be uploaded by the gcp-ai-batch provider")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks))
+	require.NoError(t, err)
+
+	requests := mocks.managedModelRequests()
+	require.Len(t, requests, 1)
+	model, ok := requests[0]["model"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"kmsKeyName": kmsKeyName}, model["encryptionSpec"], "Model should be encrypted with the key")
+	assert.NotContains(t, model["containerSpec"], "env", "Container settings should default to the ones of the image")
+}
+
+func TestNewAIBatch_WithCreatedKmsKey(t *testing.T) {
+	t.Parallel()
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:          testProjectName,
+			Region:           testRegion,
+			ModelName:        "publishers/google/models/gemma-2b-it",
+			InputBigQueryURI: "bq://features-project.features.customer_reviews",
+			OutputBigQuery:   &gcp.BigQueryOutputArgs{},
+			CreateKmsKey:     true,
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-created-kms-key", args)
+		require.NoError(t, err)
+
+		cryptoKey := AIBatch.GetKmsCryptoKey()
+		require.NotNil(t, cryptoKey, "Key should be created")
+		assert.Len(t, AIBatch.GetKmsKeyIAMMembers(), 3, "BigQuery, Storage and Vertex AI service agents should be granted access to the key")
+
+		batchJob := AIBatch.GetBatchPredictionJob()
+		require.NotNil(t, batchJob, "Batch prediction job should not be nil")
+
+		encryptionCh := make(chan []interface{}, 1)
+		defer close(encryptionCh)
+		pulumi.All(
+			cryptoKey.ID(),
+			batchJob.EncryptionSpec.KmsKeyName(),
+			cryptoKey.RotationPeriod.Elem(),
+		).ApplyT(func(values []interface{}) error {
+			encryptionCh <- values
+
+			return nil
+		})
+		encryption := <-encryptionCh
+		assert.Equal(t, string(encryption[0].(pulumi.ID)), encryption[1], "Job should be encrypted with the created key")
+		assert.Equal(t, "7776000s", encryption[2], "Created key should be rotated every 90 days")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+// fakeBatchJobClient reads the jobs launched by previous updates.
+type fakeBatchJobClient struct {
+	// Resource name found for every launch ID, as if every job was launched by a previous update.
+	launchedJob string
+	// States of the jobs launched by previous updates, by resource name.
+	states map[string]string
+}
+
+func (c *fakeBatchJobClient) FindBatchPredictionJob(context.Context, string, string, string, string) (string, error) {
+	return c.launchedJob, nil
+}
+
+func (c *fakeBatchJobClient) GetBatchPredictionJobState(_ context.Context, _, jobName string) (string, error) {
+	return c.states[jobName], nil
+}
+
+func TestNewAIBatch_WithSpotReplicas(t *testing.T) {
+	t.Parallel()
+
+	mocks := &AIBatchMocks{t: t}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:          testProjectName,
+			Region:           testRegion,
+			ModelName:        "publishers/meta/models/llama3-2@llama-3.2-3b-instruct",
+			InputBigQueryURI: "bq://features-project.features.customer_reviews",
+			MachineType:      pulumi.String("g2-standard-8"),
+			AcceleratorType:  pulumi.String("NVIDIA_L4"),
+			Spot:             true,
+			ReservationAffinity: &gcp.ReservationAffinityArgs{
+				Type: gcp.ReservationAffinityNone,
+			},
+			RunIDStrategy:     gcp.ExplicitRunID("release-42"),
+			RetainJobOnDelete: true,
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-spot-replicas", args)
+		require.NoError(t, err)
+
+		assert.True(t, AIBatch.Spot, "Spot should be set on the component")
+		assert.Equal(t, gcp.ReservationAffinityNone, AIBatch.ReservationAffinity.Type)
+		require.NotNil(t, AIBatch.GetBatchPredictionJob(), "Batch prediction job should not be nil")
+
+		jobNameCh := make(chan string, 1)
+		defer close(jobNameCh)
+		AIBatch.GetBatchPredictionJob().Name.ApplyT(func(name string) error {
+			jobNameCh <- name
+
+			return nil
+		})
+		assert.Equal(t, "projects/test-project/locations/us-central1/batchPredictionJobs/test-spot-replicas-batch-prediction-job-release-42",
+			<-jobNameCh, "Job created by the gcp-ai-batch provider should be read into the stack")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks))
+	require.NoError(t, err)
+
+	require.Len(t, mocks.managedJobs, 1, "Job should be managed by the gcp-ai-batch provider")
+	managedJob := mocks.managedJobs[0]
+	assert.Equal(t, testProjectName, managedJob.Inputs["project"].StringValue())
+	assert.Equal(t, testRegion, managedJob.Inputs["region"].StringValue())
+	assert.True(t, strings.HasPrefix(managedJob.Provider, "urn:pulumi:stack::project::pulumi-ai-batch:gcp:AIBatch$pulumi:providers:gcp-ai-batch::"),
+		"Job should be managed by the explicit gcp-ai-batch provider, got %s", managedJob.Provider)
+	require.NotNil(t, managedJob.RegisterRPC)
+	assert.True(t, managedJob.RegisterRPC.GetRetainOnDelete(), "Job should be kept on destroy with RetainJobOnDelete")
+
+	request := mocks.managedJobRequests()[0]
+	dedicatedResources := request["dedicatedResources"].(map[string]interface{})
+	assert.Equal(t, true, dedicatedResources["spot"], "Replicas should run on Spot VMs")
+	assert.Equal(t, map[string]interface{}{
+		"machineType":      "g2-standard-8",
+		"acceleratorType":  "NVIDIA_L4",
+		"acceleratorCount": float64(1),
+		"reservationAffinity": map[string]interface{}{
+			"reservationAffinityType": gcp.ReservationAffinityNone,
+		},
+	}, dedicatedResources["machineSpec"], "Machine spec should keep the machine type and set the reservation affinity")
+	assert.Equal(t, map[string]interface{}{
+		"instancesFormat": "bigquery",
+		"bigquerySource":  map[string]interface{}{"inputUri": "bq://features-project.features.customer_reviews"},
+	}, request["inputConfig"], "Instances should be read from BigQuery")
+	assert.Equal(t, "publishers/meta/models/llama3-2@llama-3.2-3b-instruct", request["model"])
+	assert.Contains(t, request["labels"], "ai-batch-launch-id", "Job should be labeled with its launch ID")
+}
+
+func TestNewAIBatch_WithSpecificReservation(t *testing.T) {
+	t.Parallel()
+
+	mocks := &AIBatchMocks{t: t}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:          testProjectName,
+			Region:           testRegion,
+			ModelName:        "publishers/meta/models/llama3-2@llama-3.2-3b-instruct",
+			InputBigQueryURI: "bq://features-project.features.customer_reviews",
+			MachineType:      pulumi.String("a2-highgpu-1g"),
+			ReservationAffinity: &gcp.ReservationAffinityArgs{
+				Type:            gcp.ReservationAffinitySpecific,
+				ReservationName: "projects/test-project/zones/us-central1-a/reservations/a100-pool",
+			},
+		}
+
+		_, err := gcp.NewAIBatch(ctx, "test-specific-reservation", args)
+		require.NoError(t, err)
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks))
+	require.NoError(t, err)
+
+	requests := mocks.managedJobRequests()
+	require.Len(t, requests, 1)
+	require.NotNil(t, mocks.managedJobs[0].RegisterRPC)
+	assert.False(t, mocks.managedJobs[0].RegisterRPC.GetRetainOnDelete(), "Job should be deleted on destroy by default")
+	dedicatedResources := requests[0]["dedicatedResources"].(map[string]interface{})
+	assert.NotContains(t, dedicatedResources, "spot", "Replicas should run on standard VMs")
+	machineSpec := dedicatedResources["machineSpec"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"reservationAffinityType": gcp.ReservationAffinitySpecific,
+		"key":                     "compute.googleapis.com/reservation-name",
+		"values":                  []interface{}{"projects/test-project/zones/us-central1-a/reservations/a100-pool"},
+	}, machineSpec["reservationAffinity"], "Replicas should consume the named reservation")
+}
+
+// rewriteHostTransport sends the requests to a test server instead of the Google Cloud APIs.
+type rewriteHostTransport struct {
+	host string
+}
+
+func (r *rewriteHostTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request.URL.Scheme = "http"
+	request.URL.Host = r.host
+
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+func TestResourceProvider_BatchPredictionJob(t *testing.T) {
+	t.Parallel()
+
+	const (
+		urn     = "urn:pulumi:stack::project::pulumi-ai-batch:gcp:AIBatch$gcp-ai-batch:resources:BatchPredictionJob::test-job"
+		jobName = "projects/test-project/locations/us-central1/batchPredictionJobs/123"
+	)
+
+	var mu sync.Mutex
+	var calls []string
+	jobState := "JOB_STATE_RUNNING"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/projects/test-project/locations/us-central1/batchPredictionJobs":
+			var request map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, "test-job", request["displayName"])
+			_, _ = fmt.Fprintf(w, `{"name": %q, "state": "JOB_STATE_PENDING"}`, jobName)
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/"+jobName+":cancel":
+			jobState = "JOB_STATE_CANCELLED"
+			_, _ = fmt.Fprint(w, `{}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/"+jobName && jobState != "":
+			_, _ = fmt.Fprintf(w, `{"name": %q, "state": %q}`, jobName, jobState)
+		case r.Method == http.MethodDelete && r.URL.Path == "/v1/"+jobName:
+			jobState = ""
+			_, _ = fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := gcp.NewResourceProvider(&http.Client{Transport: &rewriteHostTransport{host: server.Listener.Addr().String()}})
+	ctx := context.Background()
+
+	marshal := func(properties map[string]interface{}) *structpb.Struct {
+		marshaled, err := plugin.MarshalProperties(resource.NewPropertyMapFromMap(properties), plugin.MarshalOptions{})
+		require.NoError(t, err)
+
+		return marshaled
+	}
+	config := marshal(map[string]interface{}{"accessToken": "test-token"})
+	_, err := provider.Configure(ctx, &pulumirpc.ConfigureRequest{Args: config})
+	require.NoError(t, err)
+
+	inputs := marshal(map[string]interface{}{
+		"project": testProjectName,
+		"region":  testRegion,
+		"request": map[string]interface{}{"displayName": "test-job", "dedicatedResources": map[string]interface{}{"spot": true}},
+	})
+
+	check, err := provider.Check(ctx, &pulumirpc.CheckRequest{Urn: urn, News: marshal(map[string]interface{}{"project": testProjectName})})
+	require.NoError(t, err)
+	assert.Len(t, check.GetFailures(), 2, "Region and request should be required")
+
+	created, err := provider.Create(ctx, &pulumirpc.CreateRequest{Urn: urn, Properties: inputs})
+	require.NoError(t, err)
+	assert.Equal(t, jobName, created.GetId(), "Job ID should be its full resource name")
+	assert.Equal(t, jobName, created.GetProperties().GetFields()["name"].GetStringValue())
+
+	// any change to the request replaces the job
+	changed := marshal(map[string]interface{}{
+		"project": testProjectName,
+		"region":  testRegion,
+		"request": map[string]interface{}{"displayName": "test-job", "dedicatedResources": map[string]interface{}{"spot": false}},
+	})
+	diff, err := provider.Diff(ctx, &pulumirpc.DiffRequest{Urn: urn, Id: jobName, OldInputs: inputs, News: changed})
+	require.NoError(t, err)
+	assert.Equal(t, pulumirpc.DiffResponse_DIFF_SOME, diff.GetChanges())
+	assert.Equal(t, []string{"request"}, diff.GetReplaces())
+	diff, err = provider.Diff(ctx, &pulumirpc.DiffRequest{Urn: urn, Id: jobName, OldInputs: inputs, News: inputs})
+	require.NoError(t, err)
+	assert.Equal(t, pulumirpc.DiffResponse_DIFF_NONE, diff.GetChanges())
+
+	read, err := provider.Read(ctx, &pulumirpc.ReadRequest{Urn: urn, Id: jobName, Properties: created.GetProperties(), Inputs: inputs})
+	require.NoError(t, err)
+	assert.Equal(t, jobName, read.GetId())
+
+	// a running job is cancelled before it is deleted
+	_, err = provider.Delete(ctx, &pulumirpc.DeleteRequest{Urn: urn, Id: jobName, Properties: created.GetProperties()})
+	require.NoError(t, err)
+
+	read, err = provider.Read(ctx, &pulumirpc.ReadRequest{Urn: urn, Id: jobName, Properties: created.GetProperties(), Inputs: inputs})
+	require.NoError(t, err)
+	assert.Empty(t, read.GetId(), "Deleted job should be gone from the stack")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"POST /v1/projects/test-project/locations/us-central1/batchPredictionJobs",
+		"GET /v1/" + jobName,
+		"GET /v1/" + jobName,
+		"POST /v1/" + jobName + ":cancel",
+		"GET /v1/" + jobName,
+		"DELETE /v1/" + jobName,
+		"GET /v1/" + jobName,
+	}, calls)
+}
+
+func TestResourceProvider_Model(t *testing.T) {
+	t.Parallel()
+
+	const (
+		urn       = "urn:pulumi:stack::project::pulumi-ai-batch:gcp:AIBatch$gcp-ai-batch:resources:Model::test-model"
+		modelName = "projects/test-project/locations/us-central1/models/456"
+		operation = "projects/test-project/locations/us-central1/operations/789"
+	)
+
+	var mu sync.Mutex
+	var calls []string
+	modelExists := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/projects/test-project/locations/us-central1/models:upload":
+			var request map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, map[string]interface{}{"displayName": "test-model"}, request["model"])
+			modelExists = true
+			_, _ = fmt.Fprintf(w, `{"name": %q, "done": true, "response": {"model": %q}}`, operation, modelName)
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/"+modelName && modelExists:
+			_, _ = fmt.Fprintf(w, `{"name": %q}`, modelName)
+		case r.Method == http.MethodDelete && r.URL.Path == "/v1/"+modelName:
+			modelExists = false
+			_, _ = fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := gcp.NewResourceProvider(&http.Client{Transport: &rewriteHostTransport{host: server.Listener.Addr().String()}})
+	ctx := context.Background()
+
+	marshal := func(properties map[string]interface{}) *structpb.Struct {
+		marshaled, err := plugin.MarshalProperties(resource.NewPropertyMapFromMap(properties), plugin.MarshalOptions{})
+		require.NoError(t, err)
+
+		return marshaled
+	}
+	_, err := provider.Configure(ctx, &pulumirpc.ConfigureRequest{Args: marshal(map[string]interface{}{"accessToken": "test-token"})})
+	require.NoError(t, err)
+
+	inputs := marshal(map[string]interface{}{
+		"project": testProjectName,
+		"region":  testRegion,
+		"request": map[string]interface{}{"model": map[string]interface{}{"displayName": "test-model"}},
+	})
+
+	created, err := provider.Create(ctx, &pulumirpc.CreateRequest{Urn: urn, Properties: inputs})
+	require.NoError(t, err)
+	assert.Equal(t, modelName, created.GetId(), "Model ID should be its full resource name")
+	assert.Equal(t, modelName, created.GetProperties().GetFields()["name"].GetStringValue())
+
+	read, err := provider.Read(ctx, &pulumirpc.ReadRequest{Urn: urn, Id: modelName, Properties: created.GetProperties(), Inputs: inputs})
+	require.NoError(t, err)
+	assert.Equal(t, modelName, read.GetId())
+
+	_, err = provider.Delete(ctx, &pulumirpc.DeleteRequest{Urn: urn, Id: modelName, Properties: created.GetProperties()})
+	require.NoError(t, err)
+
+	read, err = provider.Read(ctx, &pulumirpc.ReadRequest{Urn: urn, Id: modelName, Properties: created.GetProperties(), Inputs: inputs})
+	require.NoError(t, err)
+	assert.Empty(t, read.GetId(), "Deleted model should be gone from the stack")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"POST /v1/projects/test-project/locations/us-central1/models:upload",
+		"GET /v1/" + modelName,
+		"DELETE /v1/" + modelName,
+		"GET /v1/" + modelName,
+	}, calls)
+}
+
+func TestNewAIBatch_WithTPUMachine(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	mocks := &AIBatchMocks{t: t}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			MachineType:                     pulumi.String("ct5lp-hightpu-4t"),
+		}
+
+		_, err := gcp.NewAIBatch(ctx, "test-tpu-machine", args)
+		require.NoError(t, err)
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks))
+	require.NoError(t, err)
+
+	// TPU_V5_LITEPOD is missing from the accelerator types of the google-native provider
+	requests := mocks.managedJobRequests()
+	require.Len(t, requests, 1, "TPU jobs should be managed by the gcp-ai-batch provider")
+	dedicatedResources := requests[0]["dedicatedResources"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"machineType":      "ct5lp-hightpu-4t",
+		"acceleratorType":  "TPU_V5_LITEPOD",
+		"acceleratorCount": float64(4),
+		"tpuTopology":      "2x2",
+	}, dedicatedResources["machineSpec"], "Accelerators and topology should default to the TPU machine type")
+}
+
+func TestNewAIBatch_WithProviderAcceleratorType(t *testing.T) {
+	t.Parallel()
+
+	tempInputDataDir := createTempInputDataDir(t)
+
+	mocks := &AIBatchMocks{t: t}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:         testProjectName,
+			Region:          testRegion,
+			ModelName:       "publishers/google/models/gemma-2b-it",
+			InputDataPath:   tempInputDataDir,
+			MachineType:     pulumi.String("g2-standard-8"),
+			AcceleratorType: pulumi.String("NVIDIA_L4"),
+		}
+
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-provider-accelerator", args)
+		require.NoError(t, err)
+
+		acceleratorCh := make(chan string, 1)
+		defer close(acceleratorCh)
+		aiBatch.GetBatchPredictionJob().DedicatedResources.MachineSpec().AcceleratorType().ApplyT(func(acceleratorType string) error {
+			acceleratorCh <- acceleratorType
+
+			return nil
+		})
+		assert.Equal(t, "NVIDIA_L4", <-acceleratorCh, "Accelerator type should be set on the job resource")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks))
+	require.NoError(t, err)
+
+	assert.Empty(t, mocks.managedJobRequests(), "Jobs with accelerator types of the provider enum should be created by the google-native provider")
+}
+
+func TestNewAIBatch_AttachedAccelerators(t *testing.T) {
+	t.Parallel()
+
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:       testProjectName,
+			Region:        testRegion,
+			ModelName:     "publishers/google/models/gemma-2b-it",
+			InputDataPath: tempInputDataDir,
+			MachineType:   pulumi.String("a2-highgpu-2g"),
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-attached-gpus", args)
+		require.NoError(t, err)
+
+		batchJob := AIBatch.GetBatchPredictionJob()
+		machineSpecCh := make(chan []interface{}, 1)
+		defer close(machineSpecCh)
+		pulumi.All(
+			batchJob.DedicatedResources.MachineSpec().AcceleratorType(),
+			batchJob.DedicatedResources.MachineSpec().AcceleratorCount(),
+		).ApplyT(func(values []interface{}) error {
+			machineSpecCh <- values
+
+			return nil
+		})
+		machineSpec := <-machineSpecCh
+		assert.Equal(t, "NVIDIA_TESLA_A100", machineSpec[0], "Accelerator type should default to the GPUs of the machine")
+		assert.Equal(t, 2, machineSpec[1], "Accelerator count should default to the GPUs of the machine")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_GardenModelProfile(t *testing.T) {
+	t.Parallel()
+
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:       testProjectName,
+			Region:        testRegion,
+			ModelName:     "publishers/google/models/gemma2@gemma-2-2b-it",
+			InputDataPath: tempInputDataDir,
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-garden-profile", args)
+		require.NoError(t, err)
+
+		batchJob := AIBatch.GetBatchPredictionJob()
+		dedicatedResourcesCh := make(chan []interface{}, 1)
+		defer close(dedicatedResourcesCh)
+		pulumi.All(
+			batchJob.DedicatedResources.MachineSpec().MachineType(),
+			batchJob.DedicatedResources.MachineSpec().AcceleratorType(),
+			batchJob.DedicatedResources.MachineSpec().AcceleratorCount(),
+			batchJob.DedicatedResources.StartingReplicaCount(),
+			batchJob.DedicatedResources.MaxReplicaCount(),
+		).ApplyT(func(values []interface{}) error {
+			dedicatedResourcesCh <- values
+
+			return nil
+		})
+		dedicatedResources := <-dedicatedResourcesCh
+		assert.Equal(t, "g2-standard-12", dedicatedResources[0], "Machine type should default to the one of the profile")
+		assert.Equal(t, "NVIDIA_L4", dedicatedResources[1], "Accelerator type should default to the one of the profile")
+		assert.Equal(t, 1, dedicatedResources[2], "Accelerator count should default to the one of the profile")
+		assert.Equal(t, 1, dedicatedResources[3], "Starting replica count should default to the one of the profile")
+		assert.Equal(t, 1, dedicatedResources[4], "Max replica count should default to the one of the profile")
+
+		// Overrides known not to run the model only warn
+		overrideArgs := &gcp.AIBatchArgs{
+			Project:          testProjectName,
+			Region:           "europe-west1",
+			ModelName:        "publishers/mistral-ai/models/mistral@mistral-7b-instruct-v0.2",
+			InputDataPath:    tempInputDataDir,
+			MachineType:      pulumi.String("n1-standard-8"),
+			AcceleratorType:  pulumi.String("NVIDIA_TESLA_T4"),
+			AcceleratorCount: pulumi.Int(2),
+		}
+
+		overrideAIBatch, err := gcp.NewAIBatch(ctx, "test-garden-profile-override", overrideArgs)
+		require.NoError(t, err)
+
+		overrideJob := overrideAIBatch.GetBatchPredictionJob()
+		overrideCh := make(chan []interface{}, 1)
+		defer close(overrideCh)
+		pulumi.All(
+			overrideJob.DedicatedResources.MachineSpec().MachineType(),
+			overrideJob.DedicatedResources.MachineSpec().AcceleratorType(),
+			overrideJob.DedicatedResources.MaxReplicaCount(),
+		).ApplyT(func(values []interface{}) error {
+			overrideCh <- values
+
+			return nil
+		})
+		override := <-overrideCh
+		assert.Equal(t, "n1-standard-8", override[0], "Machine type override should be kept")
+		assert.Equal(t, "NVIDIA_TESLA_T4", override[1], "Accelerator type override should be kept")
+		assert.Equal(t, 1, override[2], "Max replica count should still default to the one of the profile")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_GardenModelProfileFromEnv(t *testing.T) {
+	tempInputDataDir := createTempInputDataDir(t)
+
+	// Only the required settings, as in the example env files of garden models
+	t.Setenv("GCP_PROJECT", testProjectName)
+	t.Setenv("GCP_REGION", testRegion)
+	t.Setenv("MODEL_NAME", "publishers/meta/models/llama3-2@llama-3.2-3b-instruct")
+	t.Setenv("INPUT_DATA_URI", tempInputDataDir)
+
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+
+	err = pulumi.RunErr(func(ctx *pulumi.Context) error {
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-garden-profile-env", cfg.ToAIBatchArgs())
+		require.NoError(t, err)
+
+		batchJob := AIBatch.GetBatchPredictionJob()
+		dedicatedResourcesCh := make(chan []interface{}, 1)
+		defer close(dedicatedResourcesCh)
+		pulumi.All(
+			batchJob.DedicatedResources.MachineSpec().MachineType(),
+			batchJob.DedicatedResources.MachineSpec().AcceleratorType(),
+			batchJob.DedicatedResources.MachineSpec().AcceleratorCount(),
+			batchJob.DedicatedResources.StartingReplicaCount(),
+			batchJob.DedicatedResources.MaxReplicaCount(),
+		).ApplyT(func(values []interface{}) error {
+			dedicatedResourcesCh <- values
+
+			return nil
+		})
+		dedicatedResources := <-dedicatedResourcesCh
+		assert.Equal(t, "g2-standard-12", dedicatedResources[0], "Machine type should default to the one of the profile")
+		assert.Equal(t, "NVIDIA_L4", dedicatedResources[1], "Accelerator type should default to the one of the profile")
+		assert.Equal(t, 1, dedicatedResources[2], "Accelerator count should default to the one of the profile")
+		assert.Equal(t, 1, dedicatedResources[3], "Starting replica count should default to the one of the profile")
+		assert.Equal(t, 1, dedicatedResources[4], "Max replica count should default to the one of the profile")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WaitForCompletion(t *testing.T) {
+	t.Parallel()
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:          testProjectName,
+			Region:           testRegion,
+			ModelName:        "publishers/google/models/gemma-2b-it",
+			InputBigQueryURI: "bq://features-project.features.customer_reviews",
+			WaitForCompletion: &gcp.WaitForCompletionArgs{
+				Timeout:      time.Minute,
+				PollInterval: time.Millisecond,
+			},
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-wait-for-completion", args)
+		require.NoError(t, err)
+
+		waiter := AIBatch.GetJobWaiter()
+		require.NotNil(t, waiter, "Job waiter should be created")
+
+		resultCh := make(chan []interface{}, 1)
+		defer close(resultCh)
+		pulumi.All(
+			AIBatch.GetJobState(),
+			waiter.ErrorMessage,
+			waiter.SuccessfulCount,
+			waiter.FailedCount,
+			waiter.IncompleteCount,
+		).ApplyT(func(values []interface{}) error {
+			resultCh <- values
+
+			return nil
+		})
+		result := <-resultCh
+		assert.Equal(t, gcp.JobStateSucceeded, result[0], "Job state should be the final state")
+		assert.Empty(t, result[1], "Succeeded job should have no error")
+		assert.Equal(t, "98", result[2], "Successful count should be exposed")
+		assert.Equal(t, "2", result[3], "Failed count should be exposed")
+		assert.Equal(t, "0", result[4], "Incomplete count should be exposed")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WaitForCompletionOnPreview(t *testing.T) {
+	t.Parallel()
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:          testProjectName,
+			Region:           testRegion,
+			ModelName:        "publishers/google/models/gemma-2b-it",
+			InputBigQueryURI: "bq://features-project.features.customer_reviews",
+			RunIDStrategy:    gcp.ExplicitRunID("release-42"),
+			WaitForCompletion: &gcp.WaitForCompletionArgs{
+				PollInterval:          time.Millisecond,
+				FailOnUnsuccessfulJob: true,
+			},
+		}
+
+		AIBatch, err := gcp.NewAIBatch(ctx, "test-wait-on-preview", args)
+		require.NoError(t, err)
+
+		jobNameCh := make(chan string, 1)
+		defer close(jobNameCh)
+		AIBatch.GetBatchPredictionJob().Name.ApplyT(func(name string) error {
+			jobNameCh <- name
+
+			return nil
+		})
+		assert.NotEmpty(t, <-jobNameCh, "Job name should be known on preview")
+
+		require.NotNil(t, AIBatch.GetJobWaiter(), "Job waiter should be created")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t, mockFailedJob: true}), func(info *pulumi.RunInfo) {
+		info.DryRun = true
+	})
+
+	// Polling the failed job would fail the preview
+	assert.NoError(t, err, "Job should not be polled on preview")
+}
+
+func TestNewAIBatch_WaitForCompletionOfFailedJob(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                  string
+		failOnUnsuccessfulJob bool
+		expectedErr           string
+	}{
+		{
+			name: "failed job is reported",
+		},
+		{
+			name:                  "failed job fails the deployment",
+			failOnUnsuccessfulJob: true,
+			expectedErr:           "ended in state JOB_STATE_FAILED: Model server never became ready",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				args := &gcp.AIBatchArgs{
+					Project:          testProjectName,
+					Region:           testRegion,
+					ModelName:        "publishers/google/models/gemma-2b-it",
+					InputBigQueryURI: "bq://features-project.features.customer_reviews",
+					WaitForCompletion: &gcp.WaitForCompletionArgs{
+						PollInterval:          time.Millisecond,
+						FailOnUnsuccessfulJob: testCase.failOnUnsuccessfulJob,
+					},
+				}
+
+				AIBatch, err := gcp.NewAIBatch(ctx, "test-wait-for-failed-job", args)
+				require.NoError(t, err)
+
+				if testCase.failOnUnsuccessfulJob {
+					return nil
+				}
+
+				resultCh := make(chan []interface{}, 1)
+				defer close(resultCh)
+				pulumi.All(AIBatch.GetJobState(), AIBatch.GetJobWaiter().ErrorMessage).ApplyT(func(values []interface{}) error {
+					resultCh <- values
+
+					return nil
+				})
+				result := <-resultCh
+				assert.Equal(t, gcp.JobStateFailed, result[0], "Job state should be the final state")
+				assert.Equal(t, "Model server never became ready", result[1], "Job error should be exposed")
+
+				return nil
+			}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t, mockFailedJob: true}))
+
+			if testCase.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testCase.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewAIBatch_WithRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		mockJobAttempts   string
+		lastAttemptState  string
+		mockFailedJob     bool
+		expectedAttempt   int
+		expectedAttempts  int
+		expectedLastState string
+	}{
+		{
+			name:              "first update launches the first attempt",
+			expectedAttempt:   1,
+			expectedAttempts:  1,
+			expectedLastState: gcp.JobStateSucceeded,
+		},
+		{
+			name:              "succeeded attempt is left alone",
+			mockJobAttempts:   `[{"attempt":1,"jobName":"projects/test-project/locations/us-central1/batchPredictionJobs/111","state":"JOB_STATE_PENDING"}]`,
+			lastAttemptState:  gcp.JobStateSucceeded,
+			expectedAttempt:   1,
+			expectedAttempts:  1,
+			expectedLastState: gcp.JobStateSucceeded,
+		},
+		{
+			name:              "failed attempt is retried",
+			mockJobAttempts:   `[{"attempt":1,"jobName":"projects/test-project/locations/us-central1/batchPredictionJobs/111","state":"JOB_STATE_PENDING"}]`,
+			lastAttemptState:  gcp.JobStateFailed,
+			mockFailedJob:     true,
+			expectedAttempt:   2,
+			expectedAttempts:  2,
+			expectedLastState: gcp.JobStateFailed,
+		},
+		{
+			name: "failed attempt is kept when all attempts are used",
+			mockJobAttempts: `[{"attempt":1,"jobName":"projects/test-project/locations/us-central1/batchPredictionJobs/111","state":"JOB_STATE_FAILED"},` +
+				`{"attempt":2,"jobName":"projects/test-project/locations/us-central1/batchPredictionJobs/222","state":"JOB_STATE_PENDING"}]`,
+			lastAttemptState:  gcp.JobStateFailed,
+			mockFailedJob:     true,
+			expectedAttempt:   2,
+			expectedAttempts:  2,
+			expectedLastState: gcp.JobStateFailed,
+		},
+		{
+			name:              "deleted attempt is not retried",
+			mockJobAttempts:   `[{"attempt":1,"jobName":"projects/test-project/locations/us-central1/batchPredictionJobs/111","state":"JOB_STATE_PENDING"}]`,
+			expectedAttempt:   1,
+			expectedAttempts:  1,
+			expectedLastState: gcp.JobStateSucceeded,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				args := &gcp.AIBatchArgs{
+					Project:          testProjectName,
+					Region:           testRegion,
+					ModelName:        "publishers/google/models/gemma-2b-it",
+					InputBigQueryURI: "bq://features-project.features.customer_reviews",
+					RetryPolicy: &gcp.RetryPolicyArgs{
+						MaxAttempts: 2,
+					},
+				}
+				// the jobs of the history are gone when the last attempt has no state
+				states := map[string]string{}
+				if testCase.lastAttemptState != "" {
+					states["projects/test-project/locations/us-central1/batchPredictionJobs/111"] = testCase.lastAttemptState
+					states["projects/test-project/locations/us-central1/batchPredictionJobs/222"] = testCase.lastAttemptState
+				}
+				args.BatchJobClient = &fakeBatchJobClient{states: states}
+
+				AIBatch, err := gcp.NewAIBatch(ctx, "test-retry-policy", args)
+				require.NoError(t, err)
+
+				batchJob := AIBatch.GetBatchPredictionJob()
+				require.NotNil(t, batchJob, "Batch prediction job should not be nil")
+
+				resultCh := make(chan []interface{}, 1)
+				defer close(resultCh)
+				pulumi.All(batchJob.Name, AIBatch.GetJobAttempts()).ApplyT(func(values []interface{}) error {
+					resultCh <- values
+
+					return nil
+				})
+				result := <-resultCh
+
+				expectedJobName := fmt.Sprintf("test-retry-policy-batch-prediction-job-attempt-%d", testCase.expectedAttempt)
+				assert.Equal(t, expectedJobName, result[0], "Job name should be stable for the attempt")
+
+				attempts := result[1].([]interface{})
+				require.Len(t, attempts, testCase.expectedAttempts, "All attempts should be exposed")
+				currentAttempt := attempts[len(attempts)-1].(map[string]interface{})
+				assert.Equal(t, testCase.expectedAttempt, currentAttempt["attempt"], "Last attempt should be the current one")
+				assert.Equal(t, expectedJobName, currentAttempt["jobName"])
+				assert.Equal(t, testCase.expectedLastState, currentAttempt["state"])
+				if testCase.expectedAttempts > 1 {
+					previousAttempt := attempts[len(attempts)-2].(map[string]interface{})
+					assert.Equal(t, gcp.JobStateFailed, previousAttempt["state"], "Previous attempts should have their final state")
+				}
+
+				return nil
+			}, pulumi.WithMocks("project", "stack", &AIBatchMocks{
+				t:               t,
+				mockFailedJob:   testCase.mockFailedJob,
+				mockJobAttempts: testCase.mockJobAttempts,
+			}))
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewAIBatch_WithContentHashRunID(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	runJob := func(machineType string, containerSpec *gcp.ContainerSpecArgs) string {
+		var jobName string
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			args := &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				Region:                          testRegion,
+				ModelDir:                        tempModelDir,
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+				InputDataPath:                   tempInputDataDir,
+				MachineType:                     pulumi.String(machineType),
+				RunIDStrategy:                   gcp.ContentHashRunID{},
+				ContainerSpec:                   containerSpec,
+			}
+
+			aiBatch, err := gcp.NewAIBatch(ctx, "test-content-hash", args)
+			require.NoError(t, err)
+			assert.Len(t, aiBatch.RunID, 12, "Run ID should be a short hash")
+
+			jobNameCh := make(chan string, 1)
+			defer close(jobNameCh)
+			aiBatch.GetBatchPredictionJob().Name.ApplyT(func(name string) error {
+				jobNameCh <- name
+
+				return nil
+			})
+			jobName = <-jobNameCh
+
+			return nil
+		}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+		require.NoError(t, err)
+
+		return jobName
+	}
+
+	firstJobName := runJob("n1-standard-4", nil)
+	assert.Equal(t, firstJobName, runJob("n1-standard-4", nil), "Job should not be relaunched when nothing changed")
+	assert.NotEqual(t, firstJobName, runJob("n1-standard-8", nil), "Job should be relaunched when the job settings change")
+	assert.NotEqual(t, firstJobName, runJob("n1-standard-4", &gcp.ContainerSpecArgs{PredictRoute: pulumi.String("/v2/predict")}),
+		"Job should be relaunched when the container routes change")
+
+	err := os.WriteFile(filepath.Join(tempInputDataDir, "data3.jsonl"), []byte(`{"text": "New review"}`), 0600)
+	require.NoError(t, err)
+	assert.NotEqual(t, firstJobName, runJob("n1-standard-4", nil), "Job should be relaunched when the input files change")
+}
+
+func TestNewAIBatch_WithExplicitRunID(t *testing.T) {
+	t.Parallel()
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:          testProjectName,
+			Region:           testRegion,
+			ModelName:        "publishers/google/models/gemma-2b-it",
+			InputBigQueryURI: "bq://features-project.features.customer_reviews",
+			RunIDStrategy:    gcp.ExplicitRunID("release-42"),
+		}
+
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-explicit-run-id", args)
+		require.NoError(t, err)
+		assert.Equal(t, "release-42", aiBatch.RunID)
+
+		jobNameCh := make(chan string, 1)
+		defer close(jobNameCh)
+		aiBatch.GetBatchPredictionJob().Name.ApplyT(func(name string) error {
+			jobNameCh <- name
+
+			return nil
+		})
+		assert.Equal(t, "test-explicit-run-id-batch-prediction-job-release-42", <-jobNameCh,
+			"Job name should be derived from the run ID")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithVersionedRunPrefixes(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			InputFileName:                   "data1.jsonl",
+			OutputDataPath:                  pulumi.String("predictions/"),
+			RunIDStrategy:                   gcp.ExplicitRunID("release-42"),
+			VersionedRunPrefixes:            true,
+		}
+
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-versioned", args)
+		require.NoError(t, err)
+
+		inputURIsCh := make(chan []string, 1)
+		defer close(inputURIsCh)
+		aiBatch.GetBatchPredictionJob().InputConfig.GcsSource().Uris().ApplyT(func(uris []string) error {
+			inputURIsCh <- uris
+
+			return nil
+		})
+		assert.Equal(t, []string{"gs://test-versioned-vertex-model-bucket/inputs/release-42/data1.jsonl"}, <-inputURIsCh,
+			"Inputs should be read from the prefix of the run")
+
+		outputURICh := make(chan string, 1)
+		defer close(outputURICh)
+		aiBatch.GetRunOutputURI().ApplyT(func(uri string) error {
+			outputURICh <- uri
+
+			return nil
+		})
+		assert.Equal(t, "gs://test-versioned-vertex-model-bucket/predictions/release-42/", <-outputURICh,
+			"Predictions should be written to the prefix of the run")
+
+		require.NotNil(t, aiBatch.GetRunIndex())
+		indexCh := make(chan string, 1)
+		defer close(indexCh)
+		aiBatch.GetRunIndex().Content.ApplyT(func(content string) error {
+			indexCh <- content
+
+			return nil
+		})
+		var runs []gcp.RunIndexEntry
+		require.NoError(t, json.Unmarshal([]byte(<-indexCh), &runs))
+		require.Len(t, runs, 2, "Previous runs should be kept in the index")
+		assert.Equal(t, "release-41", runs[0].RunID)
+		assert.Equal(t, "release-42", runs[1].RunID)
+		assert.Equal(t, []string{"gs://test-versioned-vertex-model-bucket/inputs/release-42/data1.jsonl"}, runs[1].InputURIs)
+		assert.Equal(t, "gs://test-versioned-vertex-model-bucket/predictions/release-42/", runs[1].OutputURI)
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{
+		t: t,
+		mockRunIndex: `[{"runId":"release-41","inputUris":["gs://test-versioned-vertex-model-bucket/inputs/release-41/data1.jsonl"],` +
+			`"outputUri":"gs://test-versioned-vertex-model-bucket/predictions/release-41/","jobName":"111","createdAt":"2026-10-01T00:00:00Z"}]`,
+	}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithMultipleJobs(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	mocks := &AIBatchMocks{t: t}
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			RunIDStrategy:                   gcp.ExplicitRunID("release-42"),
+			Labels:                          map[string]string{"team": "ml"},
+			Jobs: []gcp.BatchJobArgs{
+				{
+					Name:          "reviews",
+					InputDataPath: tempInputDataDir,
+				},
+				{
+					Name:        "tickets",
+					InputURIs:   []string{"gs://support-data/tickets/*.jsonl"},
+					MachineType: pulumi.String("ct5lp-hightpu-4t"),
+					Labels:      map[string]string{"dataset": "tickets"},
+				},
+			},
+		}
+
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-jobs", args)
+		require.NoError(t, err)
+
+		require.NotNil(t, aiBatch.GetModelDeployment(), "Jobs should share one model deployment")
+		assert.Len(t, aiBatch.GetInputBucketIAMMembers(), 1, "Model SA should be granted access to the input bucket of the tickets job")
+		assert.Nil(t, aiBatch.GetBatchJob("unknown"))
+
+		reviewsJob := aiBatch.GetBatchJob("reviews")
+		require.NotNil(t, reviewsJob)
+		assert.Equal(t, reviewsJob, aiBatch.GetBatchPredictionJob(), "The first job should stand for the component")
+
+		reviewsCh := make(chan []interface{}, 1)
+		defer close(reviewsCh)
+		pulumi.All(
+			reviewsJob.Name,
+			reviewsJob.InputConfig.GcsSource().Uris(),
+			reviewsJob.OutputConfig.GcsDestination().OutputUriPrefix(),
+			reviewsJob.DedicatedResources.MachineSpec().MachineType(),
+		).ApplyT(func(values []interface{}) error {
+			reviewsCh <- values
+
+			return nil
+		})
+		reviews := <-reviewsCh
+		assert.Equal(t, "test-jobs-batch-prediction-job-reviews-release-42", reviews[0])
+		assert.Equal(t, []string{"gs://test-jobs-vertex-model-bucket/inputs/reviews/*.jsonl"}, reviews[1],
+			"Inputs should be uploaded under the prefix of the job")
+		assert.Equal(t, "gs://test-jobs-vertex-model-bucket/predictions/reviews/", reviews[2],
+			"Predictions should be written under the prefix of the job")
+		assert.Equal(t, "n1-highmem-4", reviews[3], "Machine type should default to the component machine type")
+
+		require.NotNil(t, aiBatch.GetBatchJob("tickets"))
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks))
+	require.NoError(t, err)
+
+	// the TPU job is managed by the gcp-ai-batch provider
+	requests := mocks.managedJobRequests()
+	require.Len(t, requests, 1, "Only the TPU job should be managed by the gcp-ai-batch provider")
+	tickets := requests[0]
+	assert.Equal(t, map[string]interface{}{
+		"instancesFormat": "jsonl",
+		"gcsSource":       map[string]interface{}{"uris": []interface{}{"gs://support-data/tickets/*.jsonl"}},
+	}, tickets["inputConfig"])
+	assert.Equal(t, "gs://test-jobs-vertex-model-bucket/predictions/tickets/",
+		tickets["outputConfig"].(map[string]interface{})["gcsDestination"].(map[string]interface{})["outputUriPrefix"])
+	assert.Equal(t, map[string]interface{}{
+		"machineType":      "ct5lp-hightpu-4t",
+		"acceleratorType":  "TPU_V5_LITEPOD",
+		"acceleratorCount": float64(4),
+		"tpuTopology":      "2x2",
+	}, tickets["dedicatedResources"].(map[string]interface{})["machineSpec"], "Accelerators should come with the machine type of the job")
+	labels := tickets["labels"].(map[string]interface{})
+	assert.Equal(t, "ml", labels["team"], "Job labels should be merged with the component labels")
+	assert.Equal(t, "tickets", labels["dataset"])
+}
+
+func TestNewAIBatch_WithModelComparison(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			RunIDStrategy:                   gcp.ExplicitRunID("release-42"),
+			CompareModels: []gcp.ComparedModelArgs{
+				{
+					Name:      "gemma",
+					ModelName: "publishers/google/models/gemma-2b-it",
+				},
+				{
+					Name:        "fine-tuned",
+					ModelDir:    tempModelDir,
+					MachineType: pulumi.String("g2-standard-8"),
+				},
+			},
+		}
+
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-compare", args)
+		require.NoError(t, err)
+
+		gardenJob := aiBatch.GetBatchJob("gemma")
+		require.NotNil(t, gardenJob)
+		customJob := aiBatch.GetBatchJob("fine-tuned")
+		require.NotNil(t, customJob)
+
+		jobsCh := make(chan []interface{}, 1)
+		defer close(jobsCh)
+		pulumi.All(
+			gardenJob.Model,
+			gardenJob.InputConfig.GcsSource().Uris(),
+			gardenJob.ServiceAccount,
+			customJob.Model,
+			customJob.InputConfig.GcsSource().Uris(),
+			customJob.ServiceAccount,
+			customJob.DedicatedResources.MachineSpec().MachineType(),
+		).ApplyT(func(values []interface{}) error {
+			jobsCh <- values
+
+			return nil
+		})
+		jobs := <-jobsCh
+		assert.Equal(t, "publishers/google/models/gemma-2b-it", jobs[0], "Garden model should be run as is")
+		assert.Equal(t, []string{"gs://test-compare-vertex-model-bucket/inputs/*.jsonl"}, jobs[1])
+		assert.Empty(t, jobs[2], "Garden model should run with the default agent")
+		assert.Equal(t, "projects/test-project/locations/us-central1/models/test-compare-vertex-model-deployment-fine-tuned", jobs[3],
+			"Custom model should be registered on its own")
+		assert.Equal(t, jobs[1], jobs[4], "Models should score the same inputs")
+		assert.Equal(t, "test-compare-model-account@test-project.iam.gserviceaccount.com", jobs[5])
+		assert.Equal(t, "g2-standard-8", jobs[6])
+
+		outputURIsCh := make(chan map[string]string, 1)
+		defer close(outputURIsCh)
+		aiBatch.GetComparisonOutputURIs().ApplyT(func(uris map[string]string) error {
+			outputURIsCh <- uris
+
+			return nil
+		})
+		assert.Equal(t, map[string]string{
+			"gemma":      "gs://test-compare-vertex-model-bucket/predictions/gemma/",
+			"fine-tuned": "gs://test-compare-vertex-model-bucket/predictions/fine-tuned/",
+		}, <-outputURIsCh, "Predictions of each model should be written under sibling prefixes")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithSharding(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			RunIDStrategy:                   gcp.ExplicitRunID("release-42"),
+			Sharding: &gcp.ShardingArgs{
+				Count: 2,
+			},
+		}
+
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-shards", args)
+		require.NoError(t, err)
+
+		shardJob := aiBatch.GetBatchJob("shard-1")
+		require.NotNil(t, shardJob)
+
+		shardCh := make(chan []interface{}, 1)
+		defer close(shardCh)
+		pulumi.All(
+			shardJob.InputConfig.GcsSource().Uris(),
+			shardJob.OutputConfig.GcsDestination().OutputUriPrefix(),
+			shardJob.DisplayName,
+		).ApplyT(func(values []interface{}) error {
+			shardCh <- values
+
+			return nil
+		})
+		shard := <-shardCh
+		assert.Equal(t, []string{"gs://test-shards-vertex-model-bucket/inputs/shard-1/shard-00001-of-00002.jsonl"}, shard[0],
+			"Each job should read its own shard")
+		assert.Equal(t, "gs://test-shards-vertex-model-bucket/predictions/shard-1/", shard[1])
+		assert.Equal(t, "test-shards-shard-1", shard[2], "Display name should be suffixed with the shard")
+
+		jobNamesCh := make(chan []string, 1)
+		defer close(jobNamesCh)
+		aiBatch.GetShardJobNames().ApplyT(func(names []string) error {
+			jobNamesCh <- names
+
+			return nil
+		})
+		assert.Equal(t, []string{
+			"test-shards-batch-prediction-job-shard-0-release-42",
+			"test-shards-batch-prediction-job-shard-1-release-42",
+		}, <-jobNamesCh)
+
+		outputURIsCh := make(chan []string, 1)
+		defer close(outputURIsCh)
+		aiBatch.GetShardOutputURIs().ApplyT(func(uris []string) error {
+			outputURIsCh <- uris
+
+			return nil
+		})
+		assert.Equal(t, []string{
+			"gs://test-shards-vertex-model-bucket/predictions/shard-0/",
+			"gs://test-shards-vertex-model-bucket/predictions/shard-1/",
+		}, <-outputURIsCh, "Predictions should be listed in shard order")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_ShardsDirectory(t *testing.T) {
+	// Shards are written to the system temporary directory
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	tempModelDir := createTempModelDir(t)
+	inputDataDir := createTempInstancesDir(t, 10)
+
+	runShardedBatch := func() {
+		err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+			args := &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				Region:                          testRegion,
+				ModelDir:                        tempModelDir,
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+				InputDataPath:                   inputDataDir,
+				Sharding:                        &gcp.ShardingArgs{Count: 2},
+			}
+
+			_, err := gcp.NewAIBatch(ctx, "test-shards-dir", args)
+			require.NoError(t, err)
+
+			return nil
+		}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+		require.NoError(t, err)
+	}
+	shardsDirs := func() []string {
+		dirs, err := filepath.Glob(filepath.Join(tempDir, "ai-batch-shards", "*", "*"))
+		require.NoError(t, err)
+
+		return dirs
+	}
+
+	runShardedBatch()
+	firstDirs := shardsDirs()
+	require.Len(t, firstDirs, 1)
+	assert.FileExists(t, filepath.Join(firstDirs[0], "shard-1", "shard-00001-of-00002.jsonl"))
+
+	// complete shards are reused as is
+	shardFile := filepath.Join(firstDirs[0], "shard-1", "shard-00001-of-00002.jsonl")
+	writtenAt := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(shardFile, writtenAt, writtenAt))
+
+	runShardedBatch()
+	assert.Equal(t, firstDirs, shardsDirs(), "Unchanged inputs should be split to the same directory")
+	shardInfo, err := os.Stat(shardFile)
+	require.NoError(t, err)
+	assert.True(t, shardInfo.ModTime().Equal(writtenAt), "Complete shards should not be written again")
+
+	err = os.WriteFile(filepath.Join(inputDataDir, "instances.jsonl"), []byte("{\"id\": 10}\n{\"id\": 11}\n"), 0600)
+	require.NoError(t, err)
+	runShardedBatch()
+	changedDirs := shardsDirs()
+	require.Len(t, changedDirs, 2, "Shards of the previous inputs should be kept for other updates")
+	assert.Contains(t, changedDirs, firstDirs[0])
+	assert.FileExists(t, shardFile, "Shards of the previous inputs should not be removed")
+}
+
+func TestNewAIBatch_WithSchedule(t *testing.T) {
+	t.Parallel()
+
+	tempModelDir := createTempModelDir(t)
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:                         testProjectName,
+			Region:                          testRegion,
+			ModelDir:                        tempModelDir,
+			ModelPredictionInputSchemaPath:  "input_schema.yaml",
+			ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			InputDataPath:                   tempInputDataDir,
+			MachineType:                     pulumi.String("g2-standard-8"),
+			AcceleratorType:                 pulumi.String("NVIDIA_L4"),
+			Spot:                            true,
+			Schedule: &gcp.ScheduleArgs{
+				Cron:      "0 2 * * *",
+				TimeZone:  "Europe/Madrid",
+				InputURIs: []string{"gs://support-data/tickets/{date}/*.jsonl"},
+			},
+		}
+
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-schedule", args)
+		require.NoError(t, err)
+
+		require.NotNil(t, aiBatch.GetScheduleWorkflow())
+		require.NotNil(t, aiBatch.GetScheduler())
+		require.NotNil(t, aiBatch.GetWorkflowServiceAccount())
+		assert.Len(t, aiBatch.GetInputBucketIAMMembers(), 1, "Model SA should be granted access to the bucket of the scheduled inputs")
+
+		scheduler := aiBatch.GetScheduler()
+		schedulerCh := make(chan []interface{}, 1)
+		defer close(schedulerCh)
+		pulumi.All(
+			scheduler.Schedule.Elem(),
+			scheduler.TimeZone.Elem(),
+			scheduler.HttpTarget.Uri().Elem(),
+			scheduler.HttpTarget.OauthToken().ServiceAccountEmail().Elem(),
+			aiBatch.GetScheduleWorkflow().ServiceAccount,
+		).ApplyT(func(values []interface{}) error {
+			schedulerCh <- values
+
+			return nil
+		})
+		schedulerValues := <-schedulerCh
+		assert.Equal(t, "0 2 * * *", schedulerValues[0])
+		assert.Equal(t, "Europe/Madrid", schedulerValues[1])
+		assert.Equal(t, "https://workflowexecutions.googleapis.com/v1/projects/test-project/locations/us-central1/workflows/test-schedule-batch-schedule-workflow/executions",
+			schedulerValues[2])
+		assert.Equal(t, "test-schedule-workflow-account@test-project.iam.gserviceaccount.com", schedulerValues[3])
+		assert.Equal(t, schedulerValues[3], schedulerValues[4], "Scheduler and workflow should share the workflows SA")
+
+		sourceCh := make(chan string, 1)
+		defer close(sourceCh)
+		aiBatch.GetScheduleWorkflow().SourceContents.Elem().ApplyT(func(source string) error {
+			sourceCh <- source
+
+			return nil
+		})
+		var definition struct {
+			Main struct {
+				Params []string                    `yaml:"params"`
+				Steps  []map[string]map[string]any `yaml:"steps"`
+			} `yaml:"main"`
+		}
+		require.NoError(t, yaml.Unmarshal([]byte(<-sourceCh), &definition))
+		require.Len(t, definition.Main.Steps, 3)
+		assert.Equal(t, []string{"args"}, definition.Main.Params)
+		assert.Equal(t, []any{map[string]any{
+			"date": `${default(map.get(args, "date"), text.substring(time.format(sys.now(), "Europe/Madrid"), 0, 10))}`,
+		}}, definition.Main.Steps[0]["init"]["assign"])
+
+		submit := definition.Main.Steps[1]["submit"]
+		assert.Equal(t, "http.post", submit["call"])
+		submitArgs := submit["args"].(map[string]any)
+		assert.Equal(t, "https://us-central1-aiplatform.googleapis.com/v1/projects/test-project/locations/us-central1/batchPredictionJobs", submitArgs["url"])
+
+		body := submitArgs["body"].(map[string]any)
+		assert.Equal(t, `${"test-schedule-" + date}`, body["displayName"])
+		assert.Equal(t, "projects/test-project/locations/us-central1/models/test-schedule-vertex-model-deployment", body["model"],
+			"Scheduled runs should use the model registered by the component")
+		assert.Equal(t, "test-schedule-model-account@test-project.iam.gserviceaccount.com", body["serviceAccount"])
+		assert.Equal(t, map[string]any{
+			"instancesFormat": "jsonl",
+			"gcsSource":       map[string]any{"uris": []any{`${"gs://support-data/tickets/" + date + "/*.jsonl"}`}},
+		}, body["inputConfig"], "Input URIs should be templated with the execution date")
+		assert.Equal(t, map[string]any{
+			"predictionsFormat": "jsonl",
+			"gcsDestination":    map[string]any{"outputUriPrefix": `${"gs://test-schedule-vertex-model-bucket/predictions/" + date + "/"}`},
+		}, body["outputConfig"], "Predictions should be written under a dated prefix")
+		assert.Equal(t, map[string]any{
+			"machineSpec": map[string]any{
+				"machineType":      "g2-standard-8",
+				"acceleratorType":  "NVIDIA_L4",
+				"acceleratorCount": 1,
+			},
+			"startingReplicaCount": 1,
+			"maxReplicaCount":      3,
+			"spot":                 true,
+		}, body["dedicatedResources"], "Scheduled runs should use the machine spec of the component")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithEventTrigger(t *testing.T) {
+	t.Parallel()
+
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:       testProjectName,
+			Region:        testRegion,
+			ModelName:     "publishers/google/models/gemma-2b-it",
+			InputDataPath: tempInputDataDir,
+			Schedule:      &gcp.ScheduleArgs{Cron: "0 2 * * *"},
+			EventTrigger: &gcp.EventTriggerArgs{
+				InputPrefix: "/landing/reviews",
+			},
+		}
+
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-events", args)
+		require.NoError(t, err)
+
+		require.NotNil(t, aiBatch.GetEventWorkflow())
+		require.NotNil(t, aiBatch.GetScheduleWorkflow())
+		eventTrigger := aiBatch.GetEventTrigger()
+		require.NotNil(t, eventTrigger)
+
+		triggerCh := make(chan []interface{}, 1)
+		defer close(triggerCh)
+		pulumi.All(
+			eventTrigger.MatchingCriterias,
+			eventTrigger.Destination.Workflow().Elem(),
+			eventTrigger.ServiceAccount.Elem(),
+			aiBatch.GetEventWorkflow().ServiceAccount,
+			aiBatch.GetScheduleWorkflow().ServiceAccount,
+		).ApplyT(func(values []interface{}) error {
+			triggerCh <- values
+
+			return nil
+		})
+		trigger := <-triggerCh
+		criteria := trigger[0].([]gcpeventarc.TriggerMatchingCriteria)
+		require.Len(t, criteria, 2)
+		assert.Equal(t, "google.cloud.storage.object.v1.finalized", criteria[0].Value)
+		assert.Equal(t, "test-events-vertex-model-bucket", criteria[1].Value, "Trigger should watch the artifacts bucket")
+		assert.Equal(t, "projects/test-project/locations/us-central1/workflows/test-events-batch-events-workflow", trigger[1])
+		assert.Equal(t, "test-events-workflow-account@test-project.iam.gserviceaccount.com", trigger[2])
+		assert.Equal(t, trigger[2], trigger[3], "Trigger and workflow should share the workflows SA")
+		assert.Equal(t, trigger[3], trigger[4], "Workflows should share one SA")
+
+		sourceCh := make(chan string, 1)
+		defer close(sourceCh)
+		aiBatch.GetEventWorkflow().SourceContents.Elem().ApplyT(func(source string) error {
+			sourceCh <- source
+
+			return nil
+		})
+		var definition struct {
+			Main struct {
+				Params []string                    `yaml:"params"`
+				Steps  []map[string]map[string]any `yaml:"steps"`
+			} `yaml:"main"`
+		}
+		require.NoError(t, yaml.Unmarshal([]byte(<-sourceCh), &definition))
+		require.Len(t, definition.Main.Steps, 6)
+		assert.Equal(t, []string{"event"}, definition.Main.Params)
+		assert.Equal(t, []any{map[string]any{
+			"condition": `${not(text.match_regex(object, "^landing/reviews/.*[^/]$"))}`,
+			"return":    `${"skipped " + object}`,
+		}}, definition.Main.Steps[1]["filter"]["switch"], "Objects outside of the input prefix should be skipped")
+		assert.Equal(t, []any{
+			map[string]any{"relative": "${text.substring(object, 16, len(object))}"},
+			map[string]any{"inputURIs": []any{`${"gs://test-events-vertex-model-bucket/" + object}`}},
+		}, definition.Main.Steps[2]["inputs"]["assign"])
+		manifest := definition.Main.Steps[3]["manifest"]["switch"].([]any)[0].(map[string]any)
+		assert.Equal(t, `${text.match_regex(object, "\\.manifest\\.json$")}`, manifest["condition"])
+
+		body := definition.Main.Steps[4]["submit"]["args"].(map[string]any)["body"].(map[string]any)
+		assert.Equal(t, "publishers/google/models/gemma-2b-it", body["model"])
+		assert.NotContains(t, body, "serviceAccount", "Garden models should run with the default agent")
+		assert.Equal(t, map[string]any{
+			"instancesFormat": "jsonl",
+			"gcsSource":       map[string]any{"uris": "${inputURIs}"},
+		}, body["inputConfig"])
+		assert.Equal(t, map[string]any{
+			"predictionsFormat": "jsonl",
+			"gcsDestination":    map[string]any{"outputUriPrefix": `${"gs://test-events-vertex-model-bucket/predictions/events/" + relative + "/"}`},
+		}, body["outputConfig"], "Predictions should be written under the path of the input file")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithNotifications(t *testing.T) {
+	t.Parallel()
+
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:        testProjectName,
+			Region:         testRegion,
+			ModelName:      "publishers/google/models/gemma-2b-it",
+			InputDataPath:  tempInputDataDir,
+			OutputDataPath: pulumi.String("predictions/reviews"),
+			Notifications: &gcp.NotificationsArgs{
+				WebhookURLs: []string{"https://hooks.example.com/batch", "https://alerts.example.com/ai"},
+				RawPayload:  true,
+			},
+		}
+
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-notify", args)
+		require.NoError(t, err)
+
+		topic := aiBatch.GetNotificationsTopic()
+		require.NotNil(t, topic)
+		sink := aiBatch.GetNotificationsSink()
+		require.NotNil(t, sink)
+
+		sinkCh := make(chan []interface{}, 1)
+		defer close(sinkCh)
+		pulumi.All(topic.Name, sink.Destination, sink.Filter.Elem(), sink.UniqueWriterIdentity.Elem()).ApplyT(func(values []interface{}) error {
+			sinkCh <- values
+
+			return nil
+		})
+		sinkValues := <-sinkCh
+		assert.Equal(t, "test-notify-batch-notifications-topic", sinkValues[0])
+		assert.Equal(t, "pubsub.googleapis.com/projects/test-project/topics/test-notify-batch-notifications-topic", sinkValues[1])
+		filter := sinkValues[2].(string)
+		assert.Contains(t, filter, `resource.type="aiplatform.googleapis.com/BatchPredictionJob" AND resource.labels.job_id=("test-notify-batch-prediction-job-`,
+			"Sink should match the state changes of the component jobs")
+		assert.Contains(t, filter, `protoPayload.resourceName:"projects/_/buckets/test-notify-vertex-model-bucket/objects/predictions/reviews/"`,
+			"Sink should match the prediction writes under the output prefix")
+		assert.Equal(t, true, sinkValues[3], "Sink should write as its own identity")
+
+		subscriptions := aiBatch.GetWebhookSubscriptions()
+		require.Len(t, subscriptions, 2)
+		pushCh := make(chan []interface{}, 1)
+		defer close(pushCh)
+		pulumi.All(
+			subscriptions[1].Topic,
+			subscriptions[1].PushConfig.PushEndpoint().Elem(),
+			subscriptions[1].PushConfig.NoWrapper().WriteMetadata().Elem(),
+		).ApplyT(func(values []interface{}) error {
+			pushCh <- values
+
+			return nil
+		})
+		push := <-pushCh
+		assert.Equal(t, "test-notify-batch-notifications-topic", push[0])
+		assert.Equal(t, "https://alerts.example.com/ai", push[1], "Webhooks should get a subscription each, in order")
+		assert.Equal(t, false, push[2], "Raw payloads should not be wrapped")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestNewAIBatch_WithRetentionPolicy(t *testing.T) {
+	t.Parallel()
+
+	tempInputDataDir := createTempInputDataDir(t)
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		args := &gcp.AIBatchArgs{
+			Project:           testProjectName,
+			Region:            testRegion,
+			ModelName:         "publishers/google/models/gemma-2b-it",
+			InputDataPath:     tempInputDataDir,
+			RetainJobOnDelete: true,
+			Labels:            map[string]string{"team": "support"},
+			RetentionPolicy: &gcp.RetentionPolicyArgs{
+				KeepLast:   5,
+				MaxAgeDays: 30,
+			},
+		}
+
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-retention", args)
+		require.NoError(t, err)
+
+		require.NotNil(t, aiBatch.GetRetentionWorkflow())
+		scheduler := aiBatch.GetRetentionScheduler()
+		require.NotNil(t, scheduler)
+
+		jobCh := make(chan []interface{}, 1)
+		defer close(jobCh)
+		pulumi.All(
+			aiBatch.GetBatchPredictionJob().Labels,
+			aiBatch.GetBatchPredictionJob().Name,
+			scheduler.Schedule.Elem(),
+			scheduler.HttpTarget.Uri().Elem(),
+		).ApplyT(func(values []interface{}) error {
+			jobCh <- values
+
+			return nil
+		})
+		jobValues := <-jobCh
+		jobLabels := jobValues[0].(map[string]string)
+		assert.Equal(t, "support", jobLabels["team"])
+		assert.Equal(t, "test-retention-batch-jobs", jobLabels["ai-batch-component"], "Jobs should carry the component label")
+		assert.Len(t, jobLabels["ai-batch-launch-id"], 32, "Jobs should carry their launch ID label")
+		assert.Equal(t, "0 4 * * *", jobValues[2], "Cleanup should run daily by default")
+		assert.Equal(t, "https://workflowexecutions.googleapis.com/v1/projects/test-project/locations/us-central1/workflows/test-retention-batch-retention-workflow/executions",
+			jobValues[3])
+
+		sourceCh := make(chan string, 1)
+		defer close(sourceCh)
+		aiBatch.GetRetentionWorkflow().SourceContents.Elem().ApplyT(func(source string) error {
+			sourceCh <- source
+
+			return nil
+		})
+		type subworkflow struct {
+			Params []string                    `yaml:"params"`
+			Steps  []map[string]map[string]any `yaml:"steps"`
+		}
+		var definition struct {
+			Main         subworkflow `yaml:"main"`
+			DeletePrefix subworkflow `yaml:"deletePrefix"`
+		}
+		require.NoError(t, yaml.Unmarshal([]byte(<-sourceCh), &definition))
+		require.Len(t, definition.Main.Steps, 7)
+		assert.Equal(t, []string{"bucket", "prefix"}, definition.DeletePrefix.Params)
+		assert.Equal(t, jobValues[1], definition.Main.Steps[0]["init"]["assign"].([]any)[0].(map[string]any)["deployedJobs"].([]any)[0],
+			"Jobs of the deployment should never be deleted")
+
+		listArgs := definition.Main.Steps[1]["listJobs"]["args"].(map[string]any)
+		assert.Equal(t, "https://us-central1-aiplatform.googleapis.com/v1/projects/test-project/locations/us-central1/batchPredictionJobs", listArgs["url"])
+		assert.Equal(t, `labels.ai-batch-component="test-retention-batch-jobs"`, listArgs["query"].(map[string]any)["filter"],
+			"Only the jobs of the component should be listed")
+
+		collectJobs := definition.Main.Steps[2]["collectJobs"]["for"].(map[string]any)
+		assert.Equal(t, `${default(map.get(page.body, "batchPredictionJobs"), [])}`, collectJobs["in"],
+			"Jobs should be collected from every page")
+		assert.Equal(t, map[string]any{
+			"collectJob": map[string]any{
+				"assign": []any{map[string]any{"jobs": "${list.concat(jobs, pageJob)}"}},
+			},
+		}, collectJobs["steps"].([]any)[0], "Jobs of the page should be appended one by one")
+		assert.Equal(t, map[string]any{"pageToken": `${default(map.get(page.body, "nextPageToken"), "")}`},
+			definition.Main.Steps[3]["nextPageToken"]["assign"].([]any)[0])
+
+		expireSteps := definition.Main.Steps[5]["expireJobs"]["for"].(map[string]any)["steps"].([]any)
+		retain := expireSteps[2].(map[string]any)["retain"].(map[string]any)["switch"].([]any)
+		assert.Equal(t, "${newerJobs < 5 or createdAt >= sys.now() - 2592000}", retain[0].(map[string]any)["condition"])
+		deletePredictions := expireSteps[3].(map[string]any)["deletePredictions"].(map[string]any)["switch"].([]any)[0].(map[string]any)
+		assert.Equal(t, `${text.match_regex(default(map.get(job, ["outputInfo", "gcsOutputDirectory"]), ""), "^gs://test-retention-vertex-model-bucket/")}`,
+			deletePredictions["condition"], "Only predictions in the artifacts bucket should be deleted")
+		assert.Equal(t, map[string]any{
+			"bucket": "test-retention-vertex-model-bucket",
+			"prefix": `${text.substring(job.outputInfo.gcsOutputDirectory, 40, len(job.outputInfo.gcsOutputDirectory)) + "/"}`,
+		}, deletePredictions["steps"].([]any)[0].(map[string]any)["deletePrefix"].(map[string]any)["args"])
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func createTempInstancesDir(t *testing.T, instances int) string {
+	t.Helper()
+	inputDataDir := t.TempDir()
+
+	var content strings.Builder
+	for instance := range instances {
+		fmt.Fprintf(&content, "{\"id\": %d}\n", instance)
+	}
+	err := os.WriteFile(filepath.Join(inputDataDir, "instances.jsonl"), []byte(content.String()), 0600)
+	require.NoError(t, err)
+
+	return inputDataDir
+}
+
+func TestEstimateCost(t *testing.T) {
+	t.Parallel()
+
+	args := &gcp.AIBatchArgs{
+		InputDataPath:    createTempInstancesDir(t, 7200),
+		MachineType:      pulumi.String("g2-standard-8"),
+		AcceleratorType:  pulumi.String("NVIDIA_L4"),
+		AcceleratorCount: pulumi.Int(1),
+		MaxReplicaCount:  pulumi.Int(2),
+	}
+	estimateArgs := &gcp.CostEstimateArgs{
+		MinInstancesPerSecond: 1,
+		MaxInstancesPerSecond: 2,
+		Prices: &gcp.PriceTable{
+			Accelerators: map[string]float64{"NVIDIA_L4": 0.70},
+		},
+	}
+
+	estimate, err := gcp.EstimateCost(args, estimateArgs)
+	require.NoError(t, err)
+	assert.Equal(t, 7200, estimate.Instances)
+	assert.InDelta(t, 0.4172+0.70, estimate.ReplicaHourlyUSD, 1e-9, "Overridden prices should replace the default prices")
+	assert.InDelta(t, 1.12, estimate.MinUSD, 1e-9, "One replica at 2 instances/s should run for 1 hour")
+	assert.InDelta(t, 4.47, estimate.MaxUSD, 1e-9, "Two replicas billed for 2 hours at 1 instance/s")
+	assert.Equal(t, 1, estimate.Jobs)
+
+	args.Sharding = &gcp.ShardingArgs{Count: 3}
+	estimate, err = gcp.EstimateCost(args, estimateArgs)
+	require.NoError(t, err)
+	assert.Equal(t, 3, estimate.Jobs, "Each shard should be priced as a job")
+	assert.InDelta(t, 1.12, estimate.MinUSD, 1e-9, "Shards should bill the replica hours of a single job")
+	assert.InDelta(t, 4.47, estimate.MaxUSD, 1e-9, "Shards should bill the replica hours of a single job")
+	args.Sharding = nil
+
+	args.MachineType = pulumi.String("n2-standard-4")
+	_, err = gcp.EstimateCost(args, estimateArgs)
+	assert.EqualError(t, err, `no price for machine type "n2-standard-4", add it to the price table`)
+}
+
+func TestNewAIBatch_WithCostEstimate(t *testing.T) {
+	t.Parallel()
+
+	inputDataDir := createTempInstancesDir(t, 7200)
+	newArgs := func(budgetUSD float64) *gcp.AIBatchArgs {
+		return &gcp.AIBatchArgs{
+			Project:         testProjectName,
+			Region:          testRegion,
+			ModelName:       "publishers/google/models/gemma-2b-it",
+			InputDataPath:   inputDataDir,
+			MachineType:     pulumi.String("g2-standard-8"),
+			AcceleratorType: pulumi.String("NVIDIA_L4"),
+			MaxReplicaCount: pulumi.Int(2),
+			CostEstimate: &gcp.CostEstimateArgs{
+				MinInstancesPerSecond: 1,
+				MaxInstancesPerSecond: 2,
+				BudgetUSD:             budgetUSD,
+			},
+		}
+	}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		aiBatch, err := gcp.NewAIBatch(ctx, "test-cost", newArgs(10))
+		require.NoError(t, err)
+
+		estimate := aiBatch.GetCostEstimate()
+		require.NotNil(t, estimate)
+		assert.Equal(t, 7200, estimate.Instances)
+		assert.InDelta(t, 1.09, estimate.MinUSD, 1e-9)
+		assert.InDelta(t, 4.36, estimate.MaxUSD, 1e-9)
+
+		_, err = gcp.NewAIBatch(ctx, "test-over-budget", newArgs(4))
+		assert.EqualError(t, err, "estimated cost of up to $4.36 for 7200 instances exceeds the budget of $4.00",
+			"Deployments over budget should fail before launching the job")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+// fakeQuotaClient serves fixed quotas, and records the accelerator types read.
+type fakeQuotaClient struct {
+	acceleratorQuotas map[string][]gcp.Quota
+	jobsQuota         gcp.Quota
+	err               error
+
+	acceleratorTypes []string
+}
+
+func (c *fakeQuotaClient) AcceleratorQuotas(_ context.Context, _, _, acceleratorType string) ([]gcp.Quota, error) {
+	c.acceleratorTypes = append(c.acceleratorTypes, acceleratorType)
+
+	return c.acceleratorQuotas[acceleratorType], c.err
+}
+
+func (c *fakeQuotaClient) ConcurrentJobsQuota(_ context.Context, _, _ string) (gcp.Quota, error) {
+	return c.jobsQuota, c.err
+}
+
+func TestNewAIBatch_WithQuotaPreflight(t *testing.T) {
+	t.Parallel()
+
+	tempInputDataDir := createTempInputDataDir(t)
+	newArgs := func(preflight *gcp.QuotaPreflightArgs) *gcp.AIBatchArgs {
+		return &gcp.AIBatchArgs{
+			Project:         testProjectName,
+			Region:          testRegion,
+			ModelName:       "publishers/google/models/gemma-2b-it",
+			InputDataPath:   tempInputDataDir,
+			MachineType:     pulumi.String("g2-standard-24"),
+			MaxReplicaCount: pulumi.Int(4),
+			QuotaPreflight:  preflight,
+			BatchJobClient:  &fakeBatchJobClient{},
+		}
+	}
+	newClient := func(l4Usage int64) *fakeQuotaClient {
+		return &fakeQuotaClient{
+			acceleratorQuotas: map[string][]gcp.Quota{
+				"NVIDIA_L4": {
+					{Metric: "aiplatform.googleapis.com/custom_model_serving_nvidia_l4_gpus", Limit: gcp.UnlimitedQuota},
+					{Metric: "NVIDIA_L4_GPUS", Limit: 16, Usage: l4Usage},
+				},
+			},
+			jobsQuota: gcp.Quota{Metric: "aiplatform.googleapis.com/batch_prediction_jobs", Limit: 5, Usage: 4},
+		}
+	}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		// 4 replicas of 2 L4s fit in the 8 left
+		client := newClient(8)
+		_, err := gcp.NewAIBatch(ctx, "test-quota", newArgs(&gcp.QuotaPreflightArgs{Client: client}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"NVIDIA_L4"}, client.acceleratorTypes, "Quotas of the attached accelerators should be read")
+
+		_, err = gcp.NewAIBatch(ctx, "test-quota-exceeded", newArgs(&gcp.QuotaPreflightArgs{Client: newClient(10)}))
+		assert.EqualError(t, err, "failed to deploy AI batch: quota preflight failed: insufficient quota: 8 NVIDIA_L4 accelerators are needed at max replicas, "+
+			"but quota NVIDIA_L4_GPUS has 6 of 16 left in region us-central1")
+
+		_, err = gcp.NewAIBatch(ctx, "test-quota-warning", newArgs(&gcp.QuotaPreflightArgs{Client: newClient(10), WarnOnly: true}))
+		assert.NoError(t, err, "Insufficient quota should only warn with warn only")
+
+		// A re-run with the same job names finds its jobs already counted in the usage
+		rerunArgs := newArgs(&gcp.QuotaPreflightArgs{Client: newClient(16)})
+		rerunArgs.RunIDStrategy = gcp.ExplicitRunID("release-42")
+		rerunArgs.BatchJobClient = &fakeBatchJobClient{launchedJob: "projects/test-project/locations/us-central1/batchPredictionJobs/1"}
+		_, err = gcp.NewAIBatch(ctx, "test-quota-rerun", rerunArgs)
+		assert.NoError(t, err, "Jobs launched by a previous update of the run should not count as demand")
+
+		// Every job counts against the concurrent jobs quota
+		jobsArgs := newArgs(&gcp.QuotaPreflightArgs{Client: newClient(0)})
+		jobsArgs.Jobs = []gcp.BatchJobArgs{
+			{Name: "reviews", InputURIs: []string{"gs://reviews/*.jsonl"}},
+			{Name: "tickets", InputURIs: []string{"gs://tickets/*.jsonl"}, MachineType: pulumi.String("n1-standard-8")},
+		}
+		_, err = gcp.NewAIBatch(ctx, "test-quota-jobs", jobsArgs)
+		assert.EqualError(t, err, "failed to deploy AI batch: quota preflight failed: insufficient quota: 2 batch prediction jobs are launched, "+
+			"but quota aiplatform.googleapis.com/batch_prediction_jobs has 1 of 5 left in region us-central1")
+
+		_, err = gcp.NewAIBatch(ctx, "test-quota-unreadable", newArgs(&gcp.QuotaPreflightArgs{
+			Client: &fakeQuotaClient{err: fmt.Errorf("permission denied")},
+		}))
+		assert.EqualError(t, err, "failed to deploy AI batch: quota preflight failed: failed to read the quotas of accelerator type NVIDIA_L4: permission denied")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", &AIBatchMocks{t: t}))
+
+	if err != nil {
+		t.Fatalf("Pulumi WithMocks failed: %v", err)
+	}
+}
+
+func TestMergeShardPredictions(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"shard-0/prediction-model-2026/prediction.results-00001-of-00002":      {Data: []byte(`{"id": 2}`)},
+		"shard-0/prediction-model-2026/prediction.results-00000-of-00002":      {Data: []byte("{\"id\": 1}\n")},
+		"shard-0/prediction-model-2026/prediction.errors_stats-00000-of-00001": {Data: []byte(`{"errors": 0}`)},
+		"shard-1/prediction-model-2026/prediction.results-00000-of-00001":      {Data: []byte("{\"id\": 3}\n")},
+	}
+
+	var merged strings.Builder
+	err := gcp.MergeShardPredictions(fsys, []string{"shard-0", "shard-1"}, &merged)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\": 1}\n{\"id\": 2}\n{\"id\": 3}\n", merged.String(),
+		"Predictions should be merged in shard order, without error stats")
+
+	err = gcp.MergeShardPredictions(fsys, []string{"shard-2"}, &merged)
+	assert.Error(t, err, "Missing shard predictions should fail the merge")
+}
+
+func TestNewAIBatch_RequiredFields(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		args        *gcp.AIBatchArgs
+		expectedErr string
+	}{
+		{
+			name: "missing project",
+			args: &gcp.AIBatchArgs{
+				Region:                          testRegion,
+				ModelImageURL:                   pulumi.String("gcr.io/test-project/my-model:latest"),
+				MachineType:                     pulumi.String("n1-standard-2"),
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			},
+			expectedErr: "project is required",
+		},
+		{
+			name: "missing region",
+			args: &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				ModelImageURL:                   pulumi.String("gcr.io/test-project/my-model:latest"),
+				MachineType:                     pulumi.String("n1-standard-2"),
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			},
+			expectedErr: "region is required",
+		},
+		{
+			name: "missing both model directory and model name",
+			args: &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				Region:                          testRegion,
+				ModelImageURL:                   pulumi.String("gcr.io/test-project/my-model:latest"),
+				MachineType:                     pulumi.String("n1-standard-2"),
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			},
+			expectedErr: "one of model directory or model name is required",
+		},
+		{
+			name: "missing input schema path when using model directory",
+			args: &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				Region:                          testRegion,
+				ModelImageURL:                   pulumi.String("gcr.io/test-project/my-model:latest"),
+				ModelDir:                        "dummy-model-dir",
+				MachineType:                     pulumi.String("n1-standard-2"),
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+			},
+			expectedErr: "model prediction input schema path is required",
+		},
+		{
+			name: "missing output schema path when using model directory",
+			args: &gcp.AIBatchArgs{
+				Project:                        testProjectName,
+				Region:                         testRegion,
+				ModelImageURL:                  pulumi.String("gcr.io/test-project/my-model:latest"),
+				ModelDir:                       "dummy-model-dir",
+				MachineType:                    pulumi.String("n1-standard-2"),
+				ModelPredictionInputSchemaPath: "input_schema.yaml",
+			},
+			expectedErr: "model prediction output schema path is required",
+		},
+		{
+			name: "missing BigQuery URI when input format is bigquery",
+			args: &gcp.AIBatchArgs{
+				Project:     testProjectName,
+				Region:      testRegion,
+				ModelName:   "publishers/google/models/gemma-2b-it",
+				InputFormat: "bigquery",
+			},
+			expectedErr: "input BigQuery URI is required when input format is bigquery",
+		},
+		{
+			name: "malformed BigQuery URI",
+			args: &gcp.AIBatchArgs{
+				Project:          testProjectName,
+				Region:           testRegion,
+				ModelName:        "publishers/google/models/gemma-2b-it",
+				InputBigQueryURI: "features.customer_reviews",
+			},
+			expectedErr: "invalid input BigQuery URI",
+		},
+		{
+			name: "missing BigQuery dataset when output format is bigquery",
+			args: &gcp.AIBatchArgs{
+				Project:      testProjectName,
+				Region:       testRegion,
+				ModelName:    "publishers/google/models/gemma-2b-it",
+				OutputFormat: pulumi.String("bigquery"),
+			},
+			expectedErr: "output BigQuery dataset is required when output format is bigquery",
+		},
+		{
+			name: "input URI outside of GCS",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				InputURIs: []string{"s3://reviews-pipeline/2025/*.jsonl"},
+			},
+			expectedErr: "invalid input URIs",
+		},
+		{
+			name: "unsupported instance type",
+			args: &gcp.AIBatchArgs{
+				Project:        testProjectName,
+				Region:         testRegion,
+				ModelName:      "publishers/google/models/gemma-2b-it",
+				InstanceConfig: &gcp.InstanceConfigArgs{InstanceType: "tensor"},
+			},
+			expectedErr: "instance type must be \"object\" or \"array\"",
+		},
+		{
+			name: "both included and excluded instance fields",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				InstanceConfig: &gcp.InstanceConfigArgs{
+					IncludedFields: []string{"review"},
+					ExcludedFields: []string{"customer_email"},
+				},
+			},
+			expectedErr: "only one of included fields or excluded fields can be set",
+		},
+		{
+			name: "container spec for a model from the garden",
+			args: &gcp.AIBatchArgs{
+				Project:       testProjectName,
+				Region:        testRegion,
+				ModelName:     "publishers/google/models/gemma-2b-it",
+				ContainerSpec: &gcp.ContainerSpecArgs{PredictRoute: pulumi.String("/predict")},
+			},
+			expectedErr: "container spec is only supported for custom models",
+		},
+		{
+			name: "relative container health route",
+			args: &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				Region:                          testRegion,
+				ModelDir:                        "model",
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+				ContainerSpec:                   &gcp.ContainerSpecArgs{HealthRoute: pulumi.String("healthz")},
+			},
+			expectedErr: `invalid container spec: health route "healthz" must start with /`,
+		},
+		{
+			name: "container port out of range",
+			args: &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				Region:                          testRegion,
+				ModelDir:                        "model",
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+				ContainerSpec:                   &gcp.ContainerSpecArgs{Ports: []int{70000}},
+			},
+			expectedErr: "invalid container spec: port 70000 must be between 1 and 65535",
+		},
+		{
+			name: "startup probe without a check",
+			args: &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				Region:                          testRegion,
+				ModelDir:                        "model",
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+				ContainerSpec:                   &gcp.ContainerSpecArgs{StartupProbe: &gcp.ProbeArgs{PeriodSeconds: 10}},
+			},
+			expectedErr: "invalid container spec: invalid startup probe: exactly one of exec command or HTTP GET path is required",
+		},
+		{
+			name: "explanations for a model from the garden",
+			args: &gcp.AIBatchArgs{
+				Project:             testProjectName,
+				Region:              testRegion,
+				ModelName:           "publishers/google/models/gemma-2b-it",
+				GenerateExplanation: true,
+				ExplanationSpec:     &gcp.ExplanationSpecArgs{Method: gcp.ExplanationMethodXRAI},
+			},
+			expectedErr: "explanations are only supported for custom models",
+		},
+		{
+			name: "missing explanation spec",
+			args: &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				Region:                          testRegion,
+				ModelDir:                        "model",
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+				GenerateExplanation:             true,
+			},
+			expectedErr: "explanation spec is required when generate explanation is set",
+		},
+		{
+			name: "unsupported explanation method",
+			args: &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				Region:                          testRegion,
+				ModelDir:                        "model",
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+				GenerateExplanation:             true,
+				ExplanationSpec:                 &gcp.ExplanationSpecArgs{Method: "lime"},
+			},
+			expectedErr: "explanation method must be one of",
+		},
+		{
+			name: "explanation step count out of range",
+			args: &gcp.AIBatchArgs{
+				Project:                         testProjectName,
+				Region:                          testRegion,
+				ModelDir:                        "model",
+				ModelPredictionInputSchemaPath:  "input_schema.yaml",
+				ModelPredictionOutputSchemaPath: "output_schema.yaml",
+				GenerateExplanation:             true,
+				ExplanationSpec:                 &gcp.ExplanationSpecArgs{Method: gcp.ExplanationMethodIntegratedGradients, StepCount: 200},
+			},
+			expectedErr: "step count must be between 1 and 100",
+		},
+		{
+			name: "malformed KMS key name",
+			args: &gcp.AIBatchArgs{
+				Project:    testProjectName,
+				Region:     testRegion,
+				ModelName:  "publishers/google/models/gemma-2b-it",
+				KmsKeyName: "my-key",
+			},
+			expectedErr: "invalid KMS key name",
+		},
+		{
+			name: "KMS key in another region",
+			args: &gcp.AIBatchArgs{
+				Project:    testProjectName,
+				Region:     testRegion,
+				ModelName:  "publishers/google/models/gemma-2b-it",
+				KmsKeyName: "projects/security-project/locations/europe-west4/keyRings/data/cryptoKeys/vertex",
+			},
+			expectedErr: "KMS key location \"europe-west4\" must match the region",
+		},
+		{
+			name: "both KMS key name and created KMS key",
+			args: &gcp.AIBatchArgs{
+				Project:      testProjectName,
+				Region:       testRegion,
+				ModelName:    "publishers/google/models/gemma-2b-it",
+				KmsKeyName:   "projects/security-project/locations/us-central1/keyRings/data/cryptoKeys/vertex",
+				CreateKmsKey: true,
+			},
+			expectedErr: "only one of KMS key name or create KMS key can be set",
+		},
+		{
+			name: "unsupported reservation affinity",
+			args: &gcp.AIBatchArgs{
+				Project:             testProjectName,
+				Region:              testRegion,
+				ModelName:           "publishers/google/models/gemma-2b-it",
+				ReservationAffinity: &gcp.ReservationAffinityArgs{Type: "ANY"},
+			},
+			expectedErr: "reservation affinity type must be one of",
+		},
+		{
+			name: "specific reservation without a reservation name",
+			args: &gcp.AIBatchArgs{
+				Project:             testProjectName,
+				Region:              testRegion,
+				ModelName:           "publishers/google/models/gemma-2b-it",
+				ReservationAffinity: &gcp.ReservationAffinityArgs{Type: gcp.ReservationAffinitySpecific},
+			},
+			expectedErr: "must be in the form projects/PROJECT/zones/ZONE/reservations/RESERVATION",
+		},
+		{
+			name: "spot replicas consuming a reservation",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				Spot:      true,
+				ReservationAffinity: &gcp.ReservationAffinityArgs{
+					Type:            gcp.ReservationAffinitySpecific,
+					ReservationName: "projects/test-project/zones/us-central1-a/reservations/l4-reservation",
+				},
+			},
+			expectedErr: "spot replicas cannot consume reservations",
+		},
+		{
+			name: "TPU accelerator on a GPU machine",
+			args: &gcp.AIBatchArgs{
+				Project:         testProjectName,
+				Region:          testRegion,
+				ModelName:       "publishers/google/models/gemma-2b-it",
+				MachineType:     pulumi.String("g2-standard-8"),
+				AcceleratorType: pulumi.String("TPU_V5_LITEPOD"),
+			},
+			expectedErr: "accelerator type TPU_V5_LITEPOD requires a TPU machine type",
+		},
+		{
+			name: "GPU accelerator on a TPU machine",
+			args: &gcp.AIBatchArgs{
+				Project:         testProjectName,
+				Region:          testRegion,
+				ModelName:       "publishers/google/models/gemma-2b-it",
+				MachineType:     pulumi.String("ct5lp-hightpu-1t"),
+				AcceleratorType: pulumi.String("NVIDIA_L4"),
+			},
+			expectedErr: "machine type ct5lp-hightpu-1t requires accelerator type TPU_V5_LITEPOD",
+		},
+		{
+			name: "TPU accelerator count not matching the machine chips",
+			args: &gcp.AIBatchArgs{
+				Project:          testProjectName,
+				Region:           testRegion,
+				ModelName:        "publishers/google/models/gemma-2b-it",
+				MachineType:      pulumi.String("ct5lp-hightpu-8t"),
+				AcceleratorCount: pulumi.Int(4),
+			},
+			expectedErr: "machine type ct5lp-hightpu-8t has 8 TPU chips, got accelerator count 4",
+		},
+		{
+			name: "TPU topology not matching the machine",
+			args: &gcp.AIBatchArgs{
+				Project:     testProjectName,
+				Region:      testRegion,
+				ModelName:   "publishers/google/models/gemma-2b-it",
+				MachineType: pulumi.String("ct5lp-hightpu-4t"),
+				TpuTopology: pulumi.String("2x4"),
+			},
+			expectedErr: "machine type ct5lp-hightpu-4t requires TPU topology 2x2, got 2x4",
+		},
+		{
+			name: "TPU topology without a TPU machine",
+			args: &gcp.AIBatchArgs{
+				Project:     testProjectName,
+				Region:      testRegion,
+				ModelName:   "publishers/google/models/gemma-2b-it",
+				TpuTopology: pulumi.String("2x2"),
+			},
+			expectedErr: "TPU topology requires a TPU machine type",
+		},
+		{
+			name: "unsupported TPU machine type",
+			args: &gcp.AIBatchArgs{
+				Project:     testProjectName,
+				Region:      testRegion,
+				ModelName:   "publishers/google/models/gemma-2b-it",
+				MachineType: pulumi.String("ct5lp-hightpu-16t"),
+			},
+			expectedErr: "unsupported TPU machine type \"ct5lp-hightpu-16t\"",
+		},
+		{
+			name: "succeeded job state as retryable",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				RetryPolicy: &gcp.RetryPolicyArgs{
+					RetryableStates: []string{gcp.JobStateSucceeded},
+				},
+			},
+			expectedErr: "retryable state must be one of",
+		},
+		{
+			name: "explicit run ID not usable in resource names",
+			args: &gcp.AIBatchArgs{
+				Project:       testProjectName,
+				Region:        testRegion,
+				ModelName:     "publishers/google/models/gemma-2b-it",
+				RunIDStrategy: gcp.ExplicitRunID("Release 42"),
+			},
+			expectedErr: "run ID \"Release 42\" must be up to 40 lowercase letters",
+		},
+		{
+			name: "versioned run prefixes without run ID",
+			args: &gcp.AIBatchArgs{
+				Project:              testProjectName,
+				Region:               testRegion,
+				ModelName:            "publishers/google/models/gemma-2b-it",
+				InputBigQueryURI:     "bq://features-project.features.customer_reviews",
+				RetryPolicy:          &gcp.RetryPolicyArgs{MaxAttempts: 3},
+				VersionedRunPrefixes: true,
+			},
+			expectedErr: "versioned run prefixes require a run ID strategy",
+		},
+		{
+			name: "jobs with the same name",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				Jobs: []gcp.BatchJobArgs{
+					{Name: "reviews", InputURIs: []string{"gs://support-data/reviews/*.jsonl"}},
+					{Name: "reviews", InputURIs: []string{"gs://support-data/reviews-v2/*.jsonl"}},
+				},
+			},
+			expectedErr: "job name \"reviews\" is used more than once",
+		},
+		{
+			name: "job without inputs",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				Jobs:      []gcp.BatchJobArgs{{Name: "reviews"}},
+			},
+			expectedErr: "job reviews: exactly one of input data path or input URIs is required",
+		},
+		{
+			name: "jobs with retry policy",
+			args: &gcp.AIBatchArgs{
+				Project:     testProjectName,
+				Region:      testRegion,
+				ModelName:   "publishers/google/models/gemma-2b-it",
+				RetryPolicy: &gcp.RetryPolicyArgs{MaxAttempts: 3},
+				Jobs: []gcp.BatchJobArgs{
+					{Name: "reviews", InputURIs: []string{"gs://support-data/reviews/*.jsonl"}},
+				},
+			},
+			expectedErr: "retry policy cannot be combined with jobs",
+		},
+		{
+			name: "model comparison with a single model",
+			args: &gcp.AIBatchArgs{
+				Project: testProjectName,
+				Region:  testRegion,
+				CompareModels: []gcp.ComparedModelArgs{
+					{Name: "gemma", ModelName: "publishers/google/models/gemma-2b-it"},
+				},
+			},
+			expectedErr: "at least two models are required",
+		},
+		{
+			name: "model comparison with the model of the component",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				CompareModels: []gcp.ComparedModelArgs{
+					{Name: "gemma", ModelName: "publishers/google/models/gemma-2b-it"},
+					{Name: "llama", ModelName: "publishers/meta/models/llama3-2@llama-3.2-3b-instruct"},
+				},
+			},
+			expectedErr: "model name and model directory are set per compared model",
+		},
+		{
+			name: "compared custom model without schemas",
+			args: &gcp.AIBatchArgs{
+				Project: testProjectName,
+				Region:  testRegion,
+				CompareModels: []gcp.ComparedModelArgs{
+					{Name: "gemma", ModelName: "publishers/google/models/gemma-2b-it"},
+					{Name: "fine-tuned", ModelDir: "model"},
+				},
+			},
+			expectedErr: "model fine-tuned: model prediction input and output schema paths are required",
+		},
+		{
+			name: "schedule without cron expression",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				Schedule:  &gcp.ScheduleArgs{},
+			},
+			expectedErr: "cron expression is required",
+		},
+		{
+			name: "schedule with a templated input bucket",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				Schedule: &gcp.ScheduleArgs{
+					Cron:      "0 2 * * *",
+					InputURIs: []string{"gs://data-{date}/*.jsonl"},
+				},
+			},
+			expectedErr: `input bucket "data-{date}" cannot be templated`,
+		},
+		{
+			name: "schedule with versioned run prefixes",
+			args: &gcp.AIBatchArgs{
+				Project:              testProjectName,
+				Region:               testRegion,
+				ModelName:            "publishers/google/models/gemma-2b-it",
+				VersionedRunPrefixes: true,
+				Schedule:             &gcp.ScheduleArgs{Cron: "0 2 * * *"},
+			},
+			expectedErr: "versioned run prefixes cannot be combined with schedules",
+		},
+		{
+			name: "event trigger with jobs",
+			args: &gcp.AIBatchArgs{
+				Project:      testProjectName,
+				Region:       testRegion,
+				ModelName:    "publishers/google/models/gemma-2b-it",
+				Jobs:         []gcp.BatchJobArgs{{Name: "reviews", InputDataPath: "reviews"}},
+				EventTrigger: &gcp.EventTriggerArgs{},
+			},
+			expectedErr: "jobs cannot be combined with event triggers",
+		},
+		{
+			name: "event trigger on the whole bucket",
+			args: &gcp.AIBatchArgs{
+				Project:      testProjectName,
+				Region:       testRegion,
+				ModelName:    "publishers/google/models/gemma-2b-it",
+				EventTrigger: &gcp.EventTriggerArgs{InputPrefix: "/"},
+			},
+			expectedErr: "input prefix cannot be the root of the bucket",
+		},
+		{
+			name: "notifications with a plain HTTP webhook",
+			args: &gcp.AIBatchArgs{
+				Project:       testProjectName,
+				Region:        testRegion,
+				ModelName:     "publishers/google/models/gemma-2b-it",
+				Notifications: &gcp.NotificationsArgs{WebhookURLs: []string{"http://hooks.example.com/batch"}},
+			},
+			expectedErr: `webhook URL "http://hooks.example.com/batch" must be an absolute https URL`,
+		},
+		{
+			name: "retention policy without a limit",
+			args: &gcp.AIBatchArgs{
+				Project:         testProjectName,
+				Region:          testRegion,
+				ModelName:       "publishers/google/models/gemma-2b-it",
+				RetentionPolicy: &gcp.RetentionPolicyArgs{},
+			},
+			expectedErr: "keep last or max age days is required",
+		},
+		{
+			name: "cost estimate without a throughput",
+			args: &gcp.AIBatchArgs{
+				Project:      testProjectName,
+				Region:       testRegion,
+				ModelName:    "publishers/google/models/gemma-2b-it",
+				CostEstimate: &gcp.CostEstimateArgs{},
+			},
+			expectedErr: "min instances per second must be positive, got 0",
+		},
+		{
+			name: "machine type with another accelerator",
+			args: &gcp.AIBatchArgs{
+				Project:         testProjectName,
+				Region:          testRegion,
+				ModelName:       "publishers/google/models/gemma-2b-it",
+				MachineType:     pulumi.String("g2-standard-8"),
+				AcceleratorType: pulumi.String("NVIDIA_TESLA_T4"),
+			},
+			expectedErr: "machine type g2-standard-8 requires accelerator type NVIDIA_L4, got NVIDIA_TESLA_T4",
+		},
+		{
+			name: "machine type with another accelerator count",
+			args: &gcp.AIBatchArgs{
+				Project:          testProjectName,
+				Region:           testRegion,
+				ModelName:        "publishers/google/models/gemma-2b-it",
+				MachineType:      pulumi.String("g2-standard-24"),
+				AcceleratorCount: pulumi.Int(1),
+			},
+			expectedErr: "machine type g2-standard-24 has 2 NVIDIA_L4 accelerators, got accelerator count 1",
+		},
+		{
+			name: "accelerator count the machine family does not allow",
+			args: &gcp.AIBatchArgs{
+				Project:          testProjectName,
+				Region:           testRegion,
+				ModelName:        "publishers/google/models/gemma-2b-it",
+				MachineType:      pulumi.String("n1-standard-8"),
+				AcceleratorType:  pulumi.String("NVIDIA_TESLA_V100"),
+				AcceleratorCount: pulumi.Int(3),
+			},
+			expectedErr: "accelerator type NVIDIA_TESLA_V100 can be attached to machine type n1-standard-8 in counts of 1, 2, 4 or 8, got 3",
+		},
+		{
+			name: "accelerator the machine family does not support",
+			args: &gcp.AIBatchArgs{
+				Project:         testProjectName,
+				Region:          testRegion,
+				ModelName:       "publishers/google/models/gemma-2b-it",
+				MachineType:     pulumi.String("n1-standard-8"),
+				AcceleratorType: pulumi.String("NVIDIA_L4"),
+			},
+			expectedErr: "machine type n1-standard-8 supports accelerator types NVIDIA_TESLA_P100, NVIDIA_TESLA_P4, NVIDIA_TESLA_T4, NVIDIA_TESLA_V100, got NVIDIA_L4",
+		},
+		{
+			name: "accelerator on a CPU machine type",
+			args: &gcp.AIBatchArgs{
+				Project:         testProjectName,
+				Region:          testRegion,
+				ModelName:       "publishers/google/models/gemma-2b-it",
+				MachineType:     pulumi.String("e2-standard-4"),
+				AcceleratorType: pulumi.String("NVIDIA_TESLA_T4"),
+			},
+			expectedErr: "machine type e2-standard-4 does not support accelerators, got NVIDIA_TESLA_T4",
+		},
+		{
+			name: "accelerator not offered in the region",
+			args: &gcp.AIBatchArgs{
+				Project:     testProjectName,
+				Region:      "europe-west2",
+				ModelName:   "publishers/google/models/gemma-2b-it",
+				MachineType: pulumi.String("a3-highgpu-8g"),
+			},
+			expectedErr: "accelerator type NVIDIA_H100_80GB is not offered in region europe-west2, only NVIDIA_TESLA_T4, NVIDIA_L4",
+		},
+		{
+			name: "job accelerator not matching the machine type",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				Jobs: []gcp.BatchJobArgs{{
+					Name:            "reviews",
+					InputDataPath:   "reviews",
+					MachineType:     pulumi.String("a2-ultragpu-1g"),
+					AcceleratorType: pulumi.String("NVIDIA_TESLA_A100"),
+				}},
+			},
+			expectedErr: "job reviews: invalid machine spec: machine type a2-ultragpu-1g requires accelerator type NVIDIA_A100_80GB, got NVIDIA_TESLA_A100",
+		},
+		{
+			name: "sharding into a single shard",
+			args: &gcp.AIBatchArgs{
+				Project:       testProjectName,
+				Region:        testRegion,
+				ModelName:     "publishers/google/models/gemma-2b-it",
+				InputDataPath: "data",
+				Sharding:      &gcp.ShardingArgs{Count: 1},
+			},
+			expectedErr: "shard count must be at least 2, got 1",
+		},
+		{
+			name: "sharding existing input URIs",
+			args: &gcp.AIBatchArgs{
+				Project:   testProjectName,
+				Region:    testRegion,
+				ModelName: "publishers/google/models/gemma-2b-it",
+				InputURIs: []string{"gs://support-data/tickets/*.jsonl"},
+				Sharding:  &gcp.ShardingArgs{Count: 4},
+			},
+			expectedErr: "only input data uploaded from the input data path can be sharded",
+		},
+		{
+			name: "sharding by an unknown balance",
+			args: &gcp.AIBatchArgs{
+				Project:       testProjectName,
+				Region:        testRegion,
+				ModelName:     "publishers/google/models/gemma-2b-it",
+				InputDataPath: "data",
+				Sharding:      &gcp.ShardingArgs{Count: 4, By: "files"},
+			},
+			expectedErr: `shards are balanced by "lines" or "bytes", got "files"`,
+		},
+		{
+			name: "sharding a format without one record per line",
+			args: &gcp.AIBatchArgs{
+				Project:       testProjectName,
+				Region:        testRegion,
+				ModelName:     "publishers/google/models/gemma-2b-it",
+				InputDataPath: "data",
+				InputFormat:   "tf-record",
+				Sharding:      &gcp.ShardingArgs{Count: 4},
+			},
+			expectedErr: `input format "tf-record" cannot be sharded, only jsonl and csv`,
 		},
 	}
 
@@ -0,0 +1,201 @@
+package gcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/organizations"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// launchIDLabel is the label identifying a job across updates, so that the jobs launched by previous updates
+// are found by the quota preflight.
+const launchIDLabel = "ai-batch-launch-id"
+
+// BatchJobClient reads the batch prediction jobs launched by previous updates with the Vertex AI API.
+type BatchJobClient interface {
+	// FindBatchPredictionJob returns the resource name of the job of the region with the label value,
+	// or an empty name when there is none.
+	FindBatchPredictionJob(ctx context.Context, project, region, labelKey, labelValue string) (string, error)
+	// GetBatchPredictionJobState returns the state of the job with the resource name, or an empty state
+	// when the job does not exist.
+	GetBatchPredictionJobState(ctx context.Context, region, jobName string) (string, error)
+}
+
+// GoogleBatchJobClient reads, creates and deletes batch prediction jobs, and the models they run, with the
+// Vertex AI REST API.
+type GoogleBatchJobClient struct {
+	// OAuth2 access token of the caller.
+	AccessToken string
+	// Client sending the requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// FindBatchPredictionJob returns the resource name of the first job of the region with the label value.
+func (c *GoogleBatchJobClient) FindBatchPredictionJob(ctx context.Context, project, region, labelKey, labelValue string) (string, error) {
+	query := url.Values{
+		"filter":   {fmt.Sprintf("labels.%s=%q", labelKey, labelValue)},
+		"pageSize": {"1"},
+	}
+	var page struct {
+		BatchPredictionJobs []struct {
+			Name string `json:"name"`
+		} `json:"batchPredictionJobs"`
+	}
+	err := sendGoogleAPIRequest(ctx, c.HTTPClient, c.AccessToken, http.MethodGet,
+		batchPredictionJobsURL(project, region)+"?"+query.Encode(), nil, &page)
+	if err != nil {
+		return "", fmt.Errorf("failed to list the batch prediction jobs of region %s: %w", region, err)
+	}
+	if len(page.BatchPredictionJobs) == 0 {
+		return "", nil
+	}
+
+	return page.BatchPredictionJobs[0].Name, nil
+}
+
+// CreateBatchPredictionJob creates a job in the region and returns its resource name.
+func (c *GoogleBatchJobClient) CreateBatchPredictionJob(ctx context.Context, project, region string, request map[string]interface{}) (string, error) {
+	var job struct {
+		Name string `json:"name"`
+	}
+	err := sendGoogleAPIRequest(ctx, c.HTTPClient, c.AccessToken, http.MethodPost, batchPredictionJobsURL(project, region), request, &job)
+	if err != nil {
+		return "", err
+	}
+
+	return job.Name, nil
+}
+
+// GetBatchPredictionJobState returns the state of the job, or an empty state when the API responds 404.
+func (c *GoogleBatchJobClient) GetBatchPredictionJobState(ctx context.Context, region, jobName string) (string, error) {
+	var job struct {
+		State string `json:"state"`
+	}
+	err := sendGoogleAPIRequest(ctx, c.HTTPClient, c.AccessToken, http.MethodGet, vertexResourceURL(region, jobName), nil, &job)
+	if err != nil {
+		if isGoogleAPINotFound(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return job.State, nil
+}
+
+// CancelBatchPredictionJob requests the cancellation of a job. The job is cancelled asynchronously.
+func (c *GoogleBatchJobClient) CancelBatchPredictionJob(ctx context.Context, region, jobName string) error {
+	return sendGoogleAPIRequest(ctx, c.HTTPClient, c.AccessToken, http.MethodPost, vertexResourceURL(region, jobName)+":cancel", map[string]interface{}{}, nil)
+}
+
+// DeleteBatchPredictionJob deletes a job that is no longer running. A job that does not exist is not an error.
+func (c *GoogleBatchJobClient) DeleteBatchPredictionJob(ctx context.Context, region, jobName string) error {
+	err := sendGoogleAPIRequest(ctx, c.HTTPClient, c.AccessToken, http.MethodDelete, vertexResourceURL(region, jobName), nil, nil)
+	if err != nil && !isGoogleAPINotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// UploadModel uploads a model to the registry of the region, waits for the upload operation, and returns
+// the resource name of the model.
+func (c *GoogleBatchJobClient) UploadModel(ctx context.Context, project, region string, request map[string]interface{}) (string, error) {
+	var operation struct {
+		Name  string `json:"name"`
+		Done  bool   `json:"done"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Response struct {
+			Model string `json:"model"`
+		} `json:"response"`
+	}
+	uploadURL := fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/models:upload",
+		region, url.PathEscape(project), url.PathEscape(region))
+	err := sendGoogleAPIRequest(ctx, c.HTTPClient, c.AccessToken, http.MethodPost, uploadURL, request, &operation)
+	if err != nil {
+		return "", err
+	}
+
+	for !operation.Done {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("timed out waiting for model upload %s", operation.Name)
+		case <-time.After(modelUploadPollInterval):
+		}
+
+		err = sendGoogleAPIRequest(ctx, c.HTTPClient, c.AccessToken, http.MethodGet, vertexResourceURL(region, operation.Name), nil, &operation)
+		if err != nil {
+			return "", fmt.Errorf("failed to get model upload %s: %w", operation.Name, err)
+		}
+	}
+	if operation.Error != nil {
+		return "", fmt.Errorf("model upload %s failed: %s", operation.Name, operation.Error.Message)
+	}
+
+	return operation.Response.Model, nil
+}
+
+// ModelExists returns true unless the API responds 404 for the model.
+func (c *GoogleBatchJobClient) ModelExists(ctx context.Context, region, modelName string) (bool, error) {
+	err := sendGoogleAPIRequest(ctx, c.HTTPClient, c.AccessToken, http.MethodGet, vertexResourceURL(region, modelName), nil, nil)
+	if err != nil {
+		if isGoogleAPINotFound(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DeleteModel deletes a model with all its versions. A model that does not exist is not an error.
+func (c *GoogleBatchJobClient) DeleteModel(ctx context.Context, region, modelName string) error {
+	err := sendGoogleAPIRequest(ctx, c.HTTPClient, c.AccessToken, http.MethodDelete, vertexResourceURL(region, modelName), nil, nil)
+	if err != nil && !isGoogleAPINotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// batchPredictionJobsURL returns the Vertex AI API endpoint of the batch prediction jobs of the region.
+func batchPredictionJobsURL(project, region string) string {
+	return fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/batchPredictionJobs",
+		region, url.PathEscape(project), url.PathEscape(region))
+}
+
+// vertexResourceURL returns the Vertex AI API endpoint of a resource of the region from its full resource name.
+func vertexResourceURL(region, resourceName string) string {
+	return fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/%s", region, resourceName)
+}
+
+// launchID returns the launch ID label value of the job. Job names are only unique within a stack.
+func launchID(ctx *pulumi.Context, jobName string) string {
+	launchHash := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s", ctx.Project(), ctx.Stack(), jobName)))
+
+	return hex.EncodeToString(launchHash[:16])
+}
+
+// jobClient returns the client reading the jobs with the Vertex AI API, defaulting to a
+// GoogleBatchJobClient with the credentials of the gcp provider.
+func (v *AIBatch) jobClient(ctx *pulumi.Context) (BatchJobClient, error) {
+	if v.batchJobClient != nil {
+		return v.batchJobClient, nil
+	}
+
+	clientConfig, err := organizations.GetClientConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the credentials to read batch prediction jobs: %w", err)
+	}
+
+	return &GoogleBatchJobClient{AccessToken: clientConfig.AccessToken}, nil
+}
@@ -0,0 +1,170 @@
+package gcp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/bigquery"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/projects"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	bigQueryFormat    = "bigquery"
+	bigQueryURIPrefix = "bq://"
+)
+
+// bigQueryTableRef identifies a BigQuery table or view.
+type bigQueryTableRef struct {
+	Project string
+	Dataset string
+	Table   string
+}
+
+// parseBigQueryTableURI parses a URI in the form "bq://project.dataset.table".
+func parseBigQueryTableURI(uri string) (bigQueryTableRef, error) {
+	if !strings.HasPrefix(uri, bigQueryURIPrefix) {
+		return bigQueryTableRef{}, fmt.Errorf("BigQuery URI %q must start with %q", uri, bigQueryURIPrefix)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(uri, bigQueryURIPrefix), ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return bigQueryTableRef{}, fmt.Errorf("BigQuery URI %q must be in the form bq://project.dataset.table", uri)
+	}
+
+	return bigQueryTableRef{
+		Project: parts[0],
+		Dataset: parts[1],
+		Table:   parts[2],
+	}, nil
+}
+
+// setupOutputBigQueryDataset creates the dataset for the predictions, unless an existing one is configured.
+// It returns the BigQuery URI of the dataset in the form "bq://project.dataset".
+func (v *AIBatch) setupOutputBigQueryDataset(ctx *pulumi.Context, output *BigQueryOutputArgs, labels map[string]string) (pulumi.StringOutput, error) {
+	if output.DatasetID != "" {
+		return pulumi.Sprintf("%s%s.%s", bigQueryURIPrefix, output.Project, output.DatasetID), nil
+	}
+
+	// Dataset IDs only allow letters, numbers and underscores
+	datasetID := strings.ReplaceAll(v.NewResourceName("predictions", "", 1024), "-", "_")
+
+	datasetLabels := pulumi.StringMap{
+		"purpose": pulumi.String("model-predictions"),
+	}
+	for key, value := range labels {
+		datasetLabels[key] = pulumi.String(value)
+	}
+
+	datasetArgs := &bigquery.DatasetArgs{
+		Project:      pulumi.String(output.Project),
+		DatasetId:    pulumi.String(datasetID),
+		Location:     pulumi.String(output.Location),
+		FriendlyName: pulumi.Sprintf("%s predictions", v.JobDisplayName),
+		Description:  pulumi.String("Predictions written by Vertex AI batch prediction jobs"),
+		// Predictions are part of the pipeline, same as the artifacts bucket, safe to implode.
+		DeleteContentsOnDestroy: pulumi.Bool(true),
+		Labels:                  datasetLabels,
+	}
+	if v.customerManagedEncryption {
+		// Prediction tables are encrypted with the customer-managed key by default
+		datasetArgs.DefaultEncryptionConfiguration = &bigquery.DatasetDefaultEncryptionConfigurationArgs{
+			KmsKeyName: v.KmsKeyName,
+		}
+	}
+
+	dataset, err := bigquery.NewDataset(ctx, v.NewResourceName("predictions", "dataset", 63), datasetArgs,
+		pulumi.Parent(v),
+		pulumi.DependsOn(v.kmsKeyDependencies()),
+	)
+	if err != nil {
+		return pulumi.StringOutput{}, fmt.Errorf("failed to create predictions dataset: %w", err)
+	}
+	v.outputBigQueryDataset = dataset
+
+	return pulumi.Sprintf("%s%s.%s", bigQueryURIPrefix, dataset.Project, dataset.DatasetId), nil
+}
+
+// grantBigQueryIAMAccess grants the member read access to the dataset of the input table, write access
+// to the predictions dataset, and the project roles required to use them from the batch prediction job.
+// The grantee names the bindings of the member, e.g., "model-sa".
+func (v *AIBatch) grantBigQueryIAMAccess(ctx *pulumi.Context, grantee string, member pulumi.StringOutput, args *AIBatchArgs) ([]*bigquery.DatasetIamMember, []*projects.IAMMember, error) {
+	var datasetMembers []*bigquery.DatasetIamMember
+
+	// Reading and writing tables is done through jobs billed to the job project
+	var roles []string
+
+	if args.InputFormat == bigQueryFormat {
+		tableRef, err := parseBigQueryTableURI(args.InputBigQueryURI)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		datasetMember, err := bigquery.NewDatasetIamMember(ctx, v.NewResourceName(grantee+"-bq-input", "iam-member", 63), &bigquery.DatasetIamMemberArgs{
+			Project:   pulumi.String(tableRef.Project),
+			DatasetId: pulumi.String(tableRef.Dataset),
+			Role:      pulumi.String("roles/bigquery.dataViewer"),
+			Member:    member,
+		}, pulumi.Parent(v))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to grant BigQuery input dataset access: %w", err)
+		}
+		datasetMembers = append(datasetMembers, datasetMember)
+
+		roles = append(roles, "roles/bigquery.jobUser", "roles/bigquery.readSessionUser")
+	}
+
+	if args.OutputBigQuery != nil {
+		datasetID := pulumi.String(args.OutputBigQuery.DatasetID).ToStringOutput()
+		if v.outputBigQueryDataset != nil {
+			datasetID = v.outputBigQueryDataset.DatasetId
+		}
+
+		datasetMember, err := bigquery.NewDatasetIamMember(ctx, v.NewResourceName(grantee+"-bq-output", "iam-member", 63), &bigquery.DatasetIamMemberArgs{
+			Project:   pulumi.String(args.OutputBigQuery.Project),
+			DatasetId: datasetID,
+			Role:      pulumi.String("roles/bigquery.dataEditor"),
+			Member:    member,
+		}, pulumi.Parent(v))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to grant BigQuery output dataset access: %w", err)
+		}
+		datasetMembers = append(datasetMembers, datasetMember)
+
+		if !slices.Contains(roles, "roles/bigquery.jobUser") {
+			roles = append(roles, "roles/bigquery.jobUser")
+		}
+	}
+
+	iamMembers := make([]*projects.IAMMember, len(roles))
+	for roleIndex, role := range roles {
+		bindingName := v.NewResourceName(fmt.Sprintf("%s-iam-%s", grantee, role), "", 63)
+		projectMember, err := projects.NewIAMMember(ctx, bindingName, &projects.IAMMemberArgs{
+			Project: pulumi.String(v.Project),
+			Role:    pulumi.String(role),
+			Member:  member,
+		}, pulumi.Parent(v))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create IAM member for role %s: %w", role, err)
+		}
+		iamMembers[roleIndex] = projectMember
+	}
+
+	return datasetMembers, iamMembers, nil
+}
+
+// bigQueryOutputTable returns the fully qualified name of the predictions table of a job, as "project.dataset.table",
+// from the output dataset and table reported by the job.
+func bigQueryOutputTable(outputDataset, outputTable pulumi.StringOutput) pulumi.StringOutput {
+	return pulumi.All(outputDataset, outputTable).ApplyT(func(values []interface{}) string {
+		dataset := strings.TrimPrefix(values[0].(string), bigQueryURIPrefix)
+		table := values[1].(string)
+		if dataset == "" || table == "" {
+			// Only known once the job starts writing predictions
+			return ""
+		}
+
+		return fmt.Sprintf("%s.%s", dataset, table)
+	}).(pulumi.StringOutput)
+}
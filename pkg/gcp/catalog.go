@@ -0,0 +1,178 @@
+package gcp
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// attachedAccelerator is the accelerator a machine type comes with.
+type attachedAccelerator struct {
+	AcceleratorType string
+	Count           int
+}
+
+// machineFamily describes the accelerators the machine types of a family can use.
+// Families with neither optional nor attached accelerators run on CPUs only.
+type machineFamily struct {
+	// Accelerator types that can be added to the machine types, and their valid counts.
+	optionalAccelerators map[string][]int
+	// Accelerators of each machine type of the family.
+	attachedAccelerators map[string]attachedAccelerator
+}
+
+// machineFamilies lists the machine families supported for predictions, by machine type prefix.
+// TPU machine types are described by tpuMachineSpecs. Machine types of other families are not checked.
+// See: https://cloud.google.com/vertex-ai/docs/predictions/configure-compute
+var machineFamilies = map[string]machineFamily{
+	"n1": {optionalAccelerators: map[string][]int{
+		"NVIDIA_TESLA_T4":   {1, 2, 4},
+		"NVIDIA_TESLA_P4":   {1, 2, 4},
+		"NVIDIA_TESLA_P100": {1, 2, 4},
+		"NVIDIA_TESLA_V100": {1, 2, 4, 8},
+	}},
+	"g2": {attachedAccelerators: map[string]attachedAccelerator{
+		"g2-standard-4":  {AcceleratorType: "NVIDIA_L4", Count: 1},
+		"g2-standard-8":  {AcceleratorType: "NVIDIA_L4", Count: 1},
+		"g2-standard-12": {AcceleratorType: "NVIDIA_L4", Count: 1},
+		"g2-standard-16": {AcceleratorType: "NVIDIA_L4", Count: 1},
+		"g2-standard-24": {AcceleratorType: "NVIDIA_L4", Count: 2},
+		"g2-standard-32": {AcceleratorType: "NVIDIA_L4", Count: 1},
+		"g2-standard-48": {AcceleratorType: "NVIDIA_L4", Count: 4},
+		"g2-standard-96": {AcceleratorType: "NVIDIA_L4", Count: 8},
+	}},
+	"a2": {attachedAccelerators: map[string]attachedAccelerator{
+		"a2-highgpu-1g":  {AcceleratorType: "NVIDIA_TESLA_A100", Count: 1},
+		"a2-highgpu-2g":  {AcceleratorType: "NVIDIA_TESLA_A100", Count: 2},
+		"a2-highgpu-4g":  {AcceleratorType: "NVIDIA_TESLA_A100", Count: 4},
+		"a2-highgpu-8g":  {AcceleratorType: "NVIDIA_TESLA_A100", Count: 8},
+		"a2-megagpu-16g": {AcceleratorType: "NVIDIA_TESLA_A100", Count: 16},
+		"a2-ultragpu-1g": {AcceleratorType: "NVIDIA_A100_80GB", Count: 1},
+		"a2-ultragpu-2g": {AcceleratorType: "NVIDIA_A100_80GB", Count: 2},
+		"a2-ultragpu-4g": {AcceleratorType: "NVIDIA_A100_80GB", Count: 4},
+		"a2-ultragpu-8g": {AcceleratorType: "NVIDIA_A100_80GB", Count: 8},
+	}},
+	"a3": {attachedAccelerators: map[string]attachedAccelerator{
+		"a3-highgpu-1g": {AcceleratorType: "NVIDIA_H100_80GB", Count: 1},
+		"a3-highgpu-2g": {AcceleratorType: "NVIDIA_H100_80GB", Count: 2},
+		"a3-highgpu-4g": {AcceleratorType: "NVIDIA_H100_80GB", Count: 4},
+		"a3-highgpu-8g": {AcceleratorType: "NVIDIA_H100_80GB", Count: 8},
+		"a3-megagpu-8g": {AcceleratorType: "NVIDIA_H100_MEGA_80GB", Count: 8},
+	}},
+	"e2":  {},
+	"n2":  {},
+	"n2d": {},
+	"c2":  {},
+	"c2d": {},
+	"c3":  {},
+	"m1":  {},
+}
+
+// regionAccelerators lists the accelerator types offered for predictions in each region.
+// Regions not listed are not checked.
+// See: https://cloud.google.com/vertex-ai/docs/general/locations#accelerators
+var regionAccelerators = map[string][]string{
+	"us-central1": {"NVIDIA_TESLA_T4", "NVIDIA_TESLA_P4", "NVIDIA_TESLA_P100", "NVIDIA_TESLA_V100", "NVIDIA_L4",
+		"NVIDIA_TESLA_A100", "NVIDIA_A100_80GB", "NVIDIA_H100_80GB", "NVIDIA_H100_MEGA_80GB", "TPU_V5_LITEPOD"},
+	"us-east1":        {"NVIDIA_TESLA_T4", "NVIDIA_TESLA_P100", "NVIDIA_TESLA_V100", "NVIDIA_L4", "NVIDIA_A100_80GB", "TPU_V6E"},
+	"us-east4":        {"NVIDIA_TESLA_T4", "NVIDIA_TESLA_P4", "NVIDIA_L4", "NVIDIA_A100_80GB", "NVIDIA_H100_80GB", "NVIDIA_H100_MEGA_80GB"},
+	"us-east5":        {"NVIDIA_H100_80GB", "TPU_V6E"},
+	"us-south1":       {"TPU_V6E"},
+	"us-west1":        {"NVIDIA_TESLA_T4", "NVIDIA_TESLA_P100", "NVIDIA_TESLA_V100", "NVIDIA_L4", "NVIDIA_TESLA_A100", "TPU_V5_LITEPOD"},
+	"us-west4":        {"NVIDIA_TESLA_T4", "NVIDIA_TESLA_P4", "NVIDIA_L4", "NVIDIA_TESLA_A100", "NVIDIA_H100_80GB", "TPU_V5_LITEPOD"},
+	"europe-west1":    {"NVIDIA_TESLA_T4", "NVIDIA_TESLA_P100", "NVIDIA_L4"},
+	"europe-west2":    {"NVIDIA_TESLA_T4", "NVIDIA_L4"},
+	"europe-west3":    {"NVIDIA_L4"},
+	"europe-west4":    {"NVIDIA_TESLA_T4", "NVIDIA_TESLA_P4", "NVIDIA_TESLA_V100", "NVIDIA_L4", "NVIDIA_TESLA_A100", "NVIDIA_A100_80GB", "NVIDIA_H100_80GB", "TPU_V5_LITEPOD", "TPU_V6E"},
+	"asia-east1":      {"NVIDIA_TESLA_T4", "NVIDIA_TESLA_P100", "NVIDIA_L4", "NVIDIA_TESLA_A100"},
+	"asia-northeast1": {"NVIDIA_TESLA_T4", "NVIDIA_L4", "NVIDIA_TESLA_A100", "NVIDIA_A100_80GB", "TPU_V6E"},
+	"asia-southeast1": {"NVIDIA_TESLA_T4", "NVIDIA_TESLA_P4", "NVIDIA_L4", "NVIDIA_TESLA_A100", "NVIDIA_A100_80GB", "NVIDIA_H100_80GB"},
+}
+
+// machineFamilyName returns the family of the machine type, e.g., "g2" for "g2-standard-8".
+func machineFamilyName(machineType string) string {
+	family, _, _ := strings.Cut(machineType, "-")
+
+	return family
+}
+
+// resolveMachineCatalogSpec checks the machine type, accelerator type and accelerator count against the machine
+// families, and the accelerator type against the accelerators of the region. The accelerators of machine types
+// that come with them are filled in when not set. Values only known at deployment time are not checked.
+func resolveMachineCatalogSpec(args *AIBatchArgs) error {
+	machineType, machineTypeKnown := plainString(args.MachineType)
+	if args.MachineType == nil {
+		machineType, machineTypeKnown = defaultMachineType, true
+	}
+	acceleratorType, acceleratorTypeKnown := plainString(args.AcceleratorType)
+	if args.AcceleratorType == nil {
+		acceleratorType, acceleratorTypeKnown = defaultAcceleratorType, true
+	}
+	hasAccelerator := acceleratorType != defaultAcceleratorType
+	acceleratorCount, acceleratorCountKnown := plainInt(args.AcceleratorCount)
+	if args.AcceleratorCount == nil {
+		acceleratorCount, acceleratorCountKnown = 1, true
+	}
+
+	if family, ok := machineFamilies[machineFamilyName(machineType)]; machineTypeKnown && ok {
+		switch {
+		case family.attachedAccelerators != nil:
+			attached, ok := family.attachedAccelerators[machineType]
+			if !ok {
+				return fmt.Errorf("unsupported machine type %q", machineType)
+			}
+			if !hasAccelerator {
+				args.AcceleratorType = pulumi.String(attached.AcceleratorType)
+				acceleratorType, hasAccelerator = attached.AcceleratorType, true
+			} else if acceleratorTypeKnown && acceleratorType != attached.AcceleratorType {
+				return fmt.Errorf("machine type %s requires accelerator type %s, got %s", machineType, attached.AcceleratorType, acceleratorType)
+			}
+			if args.AcceleratorCount == nil {
+				args.AcceleratorCount = pulumi.Int(attached.Count)
+			} else if acceleratorCountKnown && acceleratorCount != attached.Count {
+				return fmt.Errorf("machine type %s has %d %s accelerators, got accelerator count %d",
+					machineType, attached.Count, attached.AcceleratorType, acceleratorCount)
+			}
+		case family.optionalAccelerators != nil:
+			if !hasAccelerator || !acceleratorTypeKnown {
+				break
+			}
+			counts, ok := family.optionalAccelerators[acceleratorType]
+			if !ok {
+				return fmt.Errorf("machine type %s supports accelerator types %s, got %s",
+					machineType, strings.Join(slices.Sorted(maps.Keys(family.optionalAccelerators)), ", "), acceleratorType)
+			}
+			if acceleratorCountKnown && !slices.Contains(counts, acceleratorCount) {
+				return fmt.Errorf("accelerator type %s can be attached to machine type %s in counts of %s, got %d",
+					acceleratorType, machineType, joinInts(counts), acceleratorCount)
+			}
+		default:
+			if hasAccelerator && acceleratorTypeKnown {
+				return fmt.Errorf("machine type %s does not support accelerators, got %s", machineType, acceleratorType)
+			}
+		}
+	}
+
+	if offered, ok := regionAccelerators[args.Region]; ok && hasAccelerator && acceleratorTypeKnown && !slices.Contains(offered, acceleratorType) {
+		return fmt.Errorf("accelerator type %s is not offered in region %s, only %s",
+			acceleratorType, args.Region, strings.Join(offered, ", "))
+	}
+
+	return nil
+}
+
+// joinInts returns the numbers as a list, e.g., "1, 2 or 4".
+func joinInts(numbers []int) string {
+	terms := make([]string, len(numbers))
+	for numberIndex, number := range numbers {
+		terms[numberIndex] = fmt.Sprint(number)
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+
+	return strings.Join(terms[:len(terms)-1], ", ") + " or " + terms[len(terms)-1]
+}
@@ -0,0 +1,203 @@
+package gcp
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// validateComparison checks the compared models and the component fields they cannot be combined with.
+// Schema paths default to the component ones, and TPU machine specs are resolved in place.
+func validateComparison(args *AIBatchArgs) error {
+	switch {
+	case args.ModelName != "" || args.ModelDir != "":
+		return fmt.Errorf("model name and model directory are set per compared model")
+	case len(args.CompareModels) < 2:
+		return fmt.Errorf("at least two models are required")
+	case len(args.Jobs) > 0:
+		return fmt.Errorf("jobs cannot be combined with model comparisons")
+	case args.RetryPolicy != nil:
+		return fmt.Errorf("retry policy cannot be combined with model comparisons")
+	case args.VersionedRunPrefixes:
+		return fmt.Errorf("versioned run prefixes cannot be combined with model comparisons")
+	case args.GenerateExplanation:
+		return fmt.Errorf("explanations cannot be combined with model comparisons")
+	}
+
+	modelNames := map[string]bool{}
+	for modelIndex := range args.CompareModels {
+		model := &args.CompareModels[modelIndex]
+
+		if !batchJobNamePattern.MatchString(model.Name) {
+			return fmt.Errorf("compared model name %q must be up to 20 lowercase letters, digits or hyphens, starting with a letter", model.Name)
+		}
+		if modelNames[model.Name] {
+			return fmt.Errorf("compared model name %q is used more than once", model.Name)
+		}
+		modelNames[model.Name] = true
+
+		if (model.ModelName == "") == (model.ModelDir == "") {
+			return fmt.Errorf("model %s: exactly one of model name or model directory is required", model.Name)
+		}
+		if model.ModelDir != "" {
+			if model.ModelPredictionInputSchemaPath == "" {
+				model.ModelPredictionInputSchemaPath = args.ModelPredictionInputSchemaPath
+			}
+			if model.ModelPredictionOutputSchemaPath == "" {
+				model.ModelPredictionOutputSchemaPath = args.ModelPredictionOutputSchemaPath
+			}
+			if model.ModelPredictionBehaviorSchemaPath == "" {
+				model.ModelPredictionBehaviorSchemaPath = args.ModelPredictionBehaviorSchemaPath
+			}
+			if model.ModelPredictionInputSchemaPath == "" || model.ModelPredictionOutputSchemaPath == "" {
+				return fmt.Errorf("model %s: model prediction input and output schema paths are required", model.Name)
+			}
+		}
+		if model.ContainerSpec != nil {
+			if model.ModelDir == "" {
+				return fmt.Errorf("model %s: container spec is only supported for custom models", model.Name)
+			}
+			if err := validateContainerSpec(model.ContainerSpec); err != nil {
+				return fmt.Errorf("model %s: invalid container spec: %w", model.Name, err)
+			}
+		}
+
+		acceleratorType, acceleratorCount, err := resolveJobMachineSpec(args.Region, model.MachineType, model.AcceleratorType, model.AcceleratorCount)
+		if err != nil {
+			return fmt.Errorf("model %s: %w", model.Name, err)
+		}
+		model.AcceleratorType = acceleratorType
+		model.AcceleratorCount = acceleratorCount
+	}
+
+	return nil
+}
+
+// comparisonJobSpecs returns one job per compared model, all reading the inputs of the component.
+// Predictions of each model are written under a sibling prefix named after the model.
+func (v *AIBatch) comparisonJobSpecs(models []ComparedModelArgs) []*batchJobSpec {
+	specs := make([]*batchJobSpec, len(models))
+	for modelIndex, model := range models {
+		spec := v.defaultBatchJobSpec()
+		spec.name = model.Name
+		spec.displayName = pulumi.Sprintf("%s-%s", v.JobDisplayName, model.Name)
+		spec.outputDataPath = v.OutputDataPath.ApplyT(func(prefix string) string {
+			return subPrefix(prefix, model.Name)
+		}).(pulumi.StringOutput)
+		spec.setMachineSpec(model.MachineType, model.AcceleratorType, model.AcceleratorCount)
+
+		spec.gardenModelName = model.ModelName
+		if model.ModelDir != "" {
+			imageURL := v.ModelImageURL
+			if model.ModelImageURL != nil {
+				imageURL = model.ModelImageURL.ToStringOutput()
+			}
+			predictRoute, healthRoute := containerRoutes(model.ContainerSpec, v.PredictRoute, v.HealthRoute)
+			spec.customModel = &customModel{
+				name:               model.Name,
+				dir:                model.ModelDir,
+				bucketBasePath:     path.Join(v.ModelBucketBasePath, model.Name),
+				imageURL:           imageURL,
+				predictRoute:       predictRoute,
+				healthRoute:        healthRoute,
+				container:          mergeContainerSpec(model.ContainerSpec, v.ContainerSpec),
+				inputSchemaPath:    model.ModelPredictionInputSchemaPath,
+				outputSchemaPath:   model.ModelPredictionOutputSchemaPath,
+				behaviorSchemaPath: model.ModelPredictionBehaviorSchemaPath,
+			}
+		}
+
+		specs[modelIndex] = spec
+	}
+
+	return specs
+}
+
+// deployComparedModels uploads and registers the custom models of the comparison.
+// It returns the uploaded model artifacts.
+func (v *AIBatch) deployComparedModels(ctx *pulumi.Context, serviceAccountEmail pulumi.StringOutput) ([]pulumi.Resource, error) {
+	var uploadedModelArtifacts []pulumi.Resource
+	for _, spec := range v.jobSpecs {
+		model := spec.customModel
+		if model == nil {
+			continue
+		}
+
+		uploadedObjects, err := v.uploadDirectoryToBucket(ctx, model.dir, model.bucketBasePath, suffixedResourceName("file", model.name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload artifacts of model %s: %w", model.name, err)
+		}
+		uploadedModelArtifacts = append(uploadedModelArtifacts, uploadedObjects...)
+
+		modelArtifactsURI := pulumi.Sprintf("gs://%s/%s", v.artifactsBucket.Name, model.bucketBasePath)
+		registeredModel, err := v.registerModel(ctx, model, modelArtifactsURI, serviceAccountEmail, uploadedObjects)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deploy model %s: %w", model.name, err)
+		}
+		spec.registeredModel = registeredModel
+	}
+
+	return uploadedModelArtifacts, nil
+}
+
+// comparisonOutputURIs maps each compared model to the URI of its predictions: a GCS prefix, or
+// the predictions table of the finished job when the predictions go to BigQuery.
+func (v *AIBatch) comparisonOutputURIs() pulumi.StringMapOutput {
+	outputURIs := pulumi.StringMap{}
+	for _, spec := range v.jobSpecs {
+		if v.OutputBigQuery == nil {
+			outputURIs[spec.name] = spec.runOutputURI
+
+			continue
+		}
+		if spec.waiter == nil {
+			// the table is only named once the job starts writing
+			outputURIs[spec.name] = pulumi.String("")
+
+			continue
+		}
+		outputURIs[spec.name] = spec.waiter.OutputBigQueryTable.ApplyT(func(table string) string {
+			if table == "" {
+				return ""
+			}
+
+			return bigQueryURIPrefix + table
+		}).(pulumi.StringOutput)
+	}
+
+	return outputURIs.ToStringMapOutput()
+}
+
+// comparisonModels maps each compared model to the model resource run by its job.
+func (v *AIBatch) comparisonModels() pulumi.StringMapOutput {
+	models := pulumi.StringMap{}
+	for _, spec := range v.jobSpecs {
+		models[spec.name] = spec.job.Model
+	}
+
+	return models.ToStringMapOutput()
+}
+
+// comparisonSettings collects the settings of the compared models that change the predictions.
+func comparisonSettings(models []ComparedModelArgs) []map[string]interface{} {
+	settings := make([]map[string]interface{}, len(models))
+	for modelIndex, model := range models {
+		settings[modelIndex] = map[string]interface{}{
+			"name":             model.Name,
+			"modelName":        model.ModelName,
+			"modelImageURL":    plainStringSetting(model.ModelImageURL),
+			"inputSchema":      model.ModelPredictionInputSchemaPath,
+			"outputSchema":     model.ModelPredictionOutputSchemaPath,
+			"behaviorSchema":   model.ModelPredictionBehaviorSchemaPath,
+			"machineType":      plainStringSetting(model.MachineType),
+			"acceleratorType":  plainStringSetting(model.AcceleratorType),
+			"acceleratorCount": plainIntSetting(model.AcceleratorCount),
+		}
+		if model.ContainerSpec != nil {
+			settings[modelIndex]["containerSpec"] = containerSpecSetting(model.ContainerSpec)
+		}
+	}
+
+	return settings
+}
@@ -4,6 +4,7 @@ package config
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
@@ -11,34 +12,113 @@ import (
 	"github.com/davidmontoyago/pulumi-gcp-ai-batch/pkg/gcp"
 )
 
+// Defaults of the machine spec and replica settings, left to the garden model profile or the TPU machine type
+// when they apply
+const (
+	defaultMachineType          = "n1-standard-2"
+	defaultAcceleratorType      = "ACCELERATOR_TYPE_UNSPECIFIED"
+	defaultAcceleratorCount     = 1
+	defaultStartingReplicaCount = 1
+	defaultMaxReplicaCount      = 3
+)
+
 // Config allows setting the vertex batch prediction job configuration via environment variables
 type Config struct {
-	GCPProject                        string `envconfig:"GCP_PROJECT" required:"true"`
-	GCPRegion                         string `envconfig:"GCP_REGION" required:"true"`
-	ModelDir                          string `envconfig:"MODEL_DIR" required:"false"`
-	ModelName                         string `envconfig:"MODEL_NAME" required:"false"`
-	ModelPredictionInputSchemaPath    string `envconfig:"MODEL_PREDICTION_INPUT_SCHEMA_PATH" required:"false"`
-	ModelPredictionOutputSchemaPath   string `envconfig:"MODEL_PREDICTION_OUTPUT_SCHEMA_PATH" required:"false"`
-	ModelPredictionBehaviorSchemaPath string `envconfig:"MODEL_PREDICTION_BEHAVIOR_SCHEMA_PATH" default:""`
-	ModelBucketBasePath               string `envconfig:"MODEL_BUCKET_BASE_PATH" default:"model/"`
-	ModelImageURL                     string `envconfig:"MODEL_IMAGE_URL" default:"us-docker.pkg.dev/vertex-ai/prediction/tf2-cpu.2-15:latest"`
-	EnablePrivateRegistryAccess       bool   `envconfig:"ENABLE_PRIVATE_REGISTRY_ACCESS" default:"false"`
-	MachineType                       string `envconfig:"MACHINE_TYPE" default:"n1-standard-2"`
-	JobDisplayName                    string `envconfig:"JOB_DISPLAY_NAME" default:""`
-	ModelDisplayName                  string `envconfig:"MODEL_DISPLAY_NAME" default:""`
+	GCPProject                        string            `envconfig:"GCP_PROJECT" required:"true"`
+	GCPRegion                         string            `envconfig:"GCP_REGION" required:"true"`
+	ModelDir                          string            `envconfig:"MODEL_DIR" required:"false"`
+	ModelName                         string            `envconfig:"MODEL_NAME" required:"false"`
+	ModelPredictionInputSchemaPath    string            `envconfig:"MODEL_PREDICTION_INPUT_SCHEMA_PATH" required:"false"`
+	ModelPredictionOutputSchemaPath   string            `envconfig:"MODEL_PREDICTION_OUTPUT_SCHEMA_PATH" required:"false"`
+	ModelPredictionBehaviorSchemaPath string            `envconfig:"MODEL_PREDICTION_BEHAVIOR_SCHEMA_PATH" default:""`
+	ModelBucketBasePath               string            `envconfig:"MODEL_BUCKET_BASE_PATH" default:"model/"`
+	ModelImageURL                     string            `envconfig:"MODEL_IMAGE_URL" default:"us-docker.pkg.dev/vertex-ai/prediction/tf2-cpu.2-15:latest"`
+	ModelPredictRoute                 string            `envconfig:"MODEL_PREDICT_ROUTE" default:""`
+	ModelHealthRoute                  string            `envconfig:"MODEL_HEALTH_ROUTE" default:""`
+	ModelEnv                          map[string]string `envconfig:"MODEL_ENV"`
+	ModelPorts                        []int             `envconfig:"MODEL_PORTS"`
+	ModelCommand                      []string          `envconfig:"MODEL_COMMAND"`
+	ModelArgs                         []string          `envconfig:"MODEL_ARGS"`
+	ModelSharedMemorySizeMB           int               `envconfig:"MODEL_SHARED_MEMORY_SIZE_MB" default:"0"`
+	ModelStartupProbePath             string            `envconfig:"MODEL_STARTUP_PROBE_PATH" default:""`
+	EnablePrivateRegistryAccess       bool              `envconfig:"ENABLE_PRIVATE_REGISTRY_ACCESS" default:"false"`
+	MachineType                       string            `envconfig:"MACHINE_TYPE" default:"n1-standard-2"`
+	JobDisplayName                    string            `envconfig:"JOB_DISPLAY_NAME" default:""`
+	ModelDisplayName                  string            `envconfig:"MODEL_DISPLAY_NAME" default:""`
 
 	// Batch prediction job specific configuration
-	InputDataURI         string `envconfig:"INPUT_DATA_URI" default:"inputs/"`
-	InputFileName        string `envconfig:"INPUT_FILE_NAME" default:"*.jsonl"`
-	InputFormat          string `envconfig:"INPUT_FORMAT" default:"jsonl"`
-	OutputDataURIPrefix  string `envconfig:"OUTPUT_DATA_URI_PREFIX" default:"predictions/"`
-	OutputFormat         string `envconfig:"OUTPUT_FORMAT" default:"jsonl"`
-	StartingReplicaCount int    `envconfig:"STARTING_REPLICA_COUNT" default:"1"`
-	MaxReplicaCount      int    `envconfig:"MAX_REPLICA_COUNT" default:"3"`
-	BatchSize            int    `envconfig:"BATCH_SIZE" default:"0"`
-	AcceleratorType      string `envconfig:"ACCELERATOR_TYPE" default:"ACCELERATOR_TYPE_UNSPECIFIED"`
-	AcceleratorCount     int    `envconfig:"ACCELERATOR_COUNT" default:"1"`
-	RetainJobOnDelete    bool   `envconfig:"RETAIN_JOB_ON_DELETE" default:"false"`
+	InputDataURI          string   `envconfig:"INPUT_DATA_URI" default:"inputs/"`
+	InputFileName         string   `envconfig:"INPUT_FILE_NAME" default:"*.jsonl"`
+	InputFormat           string   `envconfig:"INPUT_FORMAT" default:"jsonl"`
+	InputBigQueryURI      string   `envconfig:"INPUT_BIGQUERY_URI" default:""`
+	InputURIs             []string `envconfig:"INPUT_URIS" default:""`
+	OutputDataURIPrefix   string   `envconfig:"OUTPUT_DATA_URI_PREFIX" default:"predictions/"`
+	OutputFormat          string   `envconfig:"OUTPUT_FORMAT" default:"jsonl"`
+	OutputBigQueryDataset string   `envconfig:"OUTPUT_BIGQUERY_DATASET" default:""`
+	StartingReplicaCount  int      `envconfig:"STARTING_REPLICA_COUNT" default:"1"`
+	MaxReplicaCount       int      `envconfig:"MAX_REPLICA_COUNT" default:"3"`
+	BatchSize             int      `envconfig:"BATCH_SIZE" default:"0"`
+	AcceleratorType       string   `envconfig:"ACCELERATOR_TYPE" default:"ACCELERATOR_TYPE_UNSPECIFIED"`
+	AcceleratorCount      int      `envconfig:"ACCELERATOR_COUNT" default:"1"`
+	TpuTopology           string   `envconfig:"TPU_TOPOLOGY" default:""`
+	RetainJobOnDelete     bool     `envconfig:"RETAIN_JOB_ON_DELETE" default:"false"`
+	RunIDStrategy         string   `envconfig:"RUN_ID_STRATEGY" default:""`
+	RunID                 string   `envconfig:"RUN_ID" default:""`
+	VersionedRunPrefixes  bool     `envconfig:"VERSIONED_RUN_PREFIXES" default:"false"`
+
+	// Capacity provisioning
+	Spot                bool   `envconfig:"SPOT" default:"false"`
+	ReservationAffinity string `envconfig:"RESERVATION_AFFINITY" default:""`
+	ReservationName     string `envconfig:"RESERVATION_NAME" default:""`
+
+	// Wait for the job to finish, e.g., to gate CI pipelines on the job result
+	WaitForCompletion        bool          `envconfig:"WAIT_FOR_COMPLETION" default:"false"`
+	WaitForCompletionTimeout time.Duration `envconfig:"WAIT_FOR_COMPLETION_TIMEOUT" default:"24h"`
+	FailOnUnsuccessfulJob    bool          `envconfig:"FAIL_ON_UNSUCCESSFUL_JOB" default:"false"`
+
+	// Resubmit failed or cancelled jobs on the next update. 0 disables retries
+	MaxJobAttempts int `envconfig:"MAX_JOB_ATTEMPTS" default:"0"`
+
+	// Recurring runs on dated prefixes. An empty cron expression disables the schedule
+	ScheduleCron            string   `envconfig:"SCHEDULE_CRON" default:""`
+	ScheduleTimeZone        string   `envconfig:"SCHEDULE_TIME_ZONE" default:"Etc/UTC"`
+	ScheduleInputURIs       []string `envconfig:"SCHEDULE_INPUT_URIS" default:""`
+	ScheduleOutputURIPrefix string   `envconfig:"SCHEDULE_OUTPUT_URI_PREFIX" default:""`
+
+	// Launch a job for every input file landing under a prefix of the artifacts bucket
+	EventTrigger        bool   `envconfig:"EVENT_TRIGGER" default:"false"`
+	EventInputPrefix    string `envconfig:"EVENT_INPUT_PREFIX" default:"inputs/events/"`
+	EventManifestSuffix string `envconfig:"EVENT_MANIFEST_SUFFIX" default:".manifest.json"`
+
+	// Publish job state changes and prediction writes to a topic, and push them to webhooks
+	Notifications           bool     `envconfig:"NOTIFICATIONS" default:"false"`
+	NotificationWebhookURLs []string `envconfig:"NOTIFICATION_WEBHOOK_URLS" default:""`
+	NotificationRawPayload  bool     `envconfig:"NOTIFICATION_RAW_PAYLOAD" default:"false"`
+
+	// Delete old jobs and their predictions on a schedule. 0 for both disables the retention policy
+	RetentionKeepLast   int    `envconfig:"RETENTION_KEEP_LAST" default:"0"`
+	RetentionMaxAgeDays int    `envconfig:"RETENTION_MAX_AGE_DAYS" default:"0"`
+	RetentionCron       string `envconfig:"RETENTION_CRON" default:"0 4 * * *"`
+	RetentionTimeZone   string `envconfig:"RETENTION_TIME_ZONE" default:"Etc/UTC"`
+
+	// Estimate the job cost from the local inputs. 0 instances per second disables the estimate
+	CostMinInstancesPerSecond float64            `envconfig:"COST_MIN_INSTANCES_PER_SECOND" default:"0"`
+	CostMaxInstancesPerSecond float64            `envconfig:"COST_MAX_INSTANCES_PER_SECOND" default:"0"`
+	CostMachinePrices         map[string]float64 `envconfig:"COST_MACHINE_PRICES" default:""`
+	CostAcceleratorPrices     map[string]float64 `envconfig:"COST_ACCELERATOR_PRICES" default:""`
+	CostBudgetUSD             float64            `envconfig:"COST_BUDGET_USD" default:"0"`
+
+	// Check the accelerator and concurrent job quotas before creating any resource
+	QuotaPreflight         bool `envconfig:"QUOTA_PREFLIGHT" default:"false"`
+	QuotaPreflightWarnOnly bool `envconfig:"QUOTA_PREFLIGHT_WARN_ONLY" default:"false"`
+
+	// Split the input data across parallel jobs. 0 disables sharding
+	ShardCount int    `envconfig:"SHARD_COUNT" default:"0"`
+	ShardBy    string `envconfig:"SHARD_BY" default:"lines"`
+
+	// Encryption configuration
+	KmsKeyName   string `envconfig:"KMS_KEY_NAME" default:""`
+	CreateKmsKey bool   `envconfig:"CREATE_KMS_KEY" default:"false"`
 }
 
 // LoadConfig loads configuration from environment variables
@@ -61,6 +141,14 @@ func LoadConfig() (*Config, error) {
 	log.Printf("  Model Prediction Behavior Schema Path: %s", config.ModelPredictionBehaviorSchemaPath)
 	log.Printf("  Model Bucket Base Path: %s", config.ModelBucketBasePath)
 	log.Printf("  Model Image URL: %s", config.ModelImageURL)
+	log.Printf("  Model Predict Route: %s", config.ModelPredictRoute)
+	log.Printf("  Model Health Route: %s", config.ModelHealthRoute)
+	log.Printf("  Model Env: %v", config.ModelEnv)
+	log.Printf("  Model Ports: %v", config.ModelPorts)
+	log.Printf("  Model Command: %v", config.ModelCommand)
+	log.Printf("  Model Args: %v", config.ModelArgs)
+	log.Printf("  Model Shared Memory Size MB: %d", config.ModelSharedMemorySizeMB)
+	log.Printf("  Model Startup Probe Path: %s", config.ModelStartupProbePath)
 	log.Printf("  Enable Private Registry Access: %t", config.EnablePrivateRegistryAccess)
 	log.Printf("  Machine Type: %s", config.MachineType)
 	log.Printf("  Job Display Name: %s", config.JobDisplayName)
@@ -68,14 +156,53 @@ func LoadConfig() (*Config, error) {
 	log.Printf("  Input Data URI: %s", config.InputDataURI)
 	log.Printf("  Input File Name: %s", config.InputFileName)
 	log.Printf("  Input Format: %s", config.InputFormat)
+	log.Printf("  Input BigQuery URI: %s", config.InputBigQueryURI)
+	log.Printf("  Input URIs: %v", config.InputURIs)
 	log.Printf("  Output Data URI Prefix: %s", config.OutputDataURIPrefix)
 	log.Printf("  Output Format: %s", config.OutputFormat)
+	log.Printf("  Output BigQuery Dataset: %s", config.OutputBigQueryDataset)
 	log.Printf("  Starting Replica Count: %d", config.StartingReplicaCount)
 	log.Printf("  Max Replica Count: %d", config.MaxReplicaCount)
 	log.Printf("  Batch Size: %d", config.BatchSize)
 	log.Printf("  Accelerator Type: %s", config.AcceleratorType)
 	log.Printf("  Accelerator Count: %d", config.AcceleratorCount)
+	log.Printf("  TPU Topology: %s", config.TpuTopology)
 	log.Printf("  Retain Job On Delete: %t", config.RetainJobOnDelete)
+	log.Printf("  Run ID Strategy: %s", config.RunIDStrategy)
+	log.Printf("  Run ID: %s", config.RunID)
+	log.Printf("  Versioned Run Prefixes: %t", config.VersionedRunPrefixes)
+	log.Printf("  Wait For Completion: %t", config.WaitForCompletion)
+	log.Printf("  Wait For Completion Timeout: %s", config.WaitForCompletionTimeout)
+	log.Printf("  Fail On Unsuccessful Job: %t", config.FailOnUnsuccessfulJob)
+	log.Printf("  Max Job Attempts: %d", config.MaxJobAttempts)
+	log.Printf("  Schedule Cron: %s", config.ScheduleCron)
+	log.Printf("  Schedule Time Zone: %s", config.ScheduleTimeZone)
+	log.Printf("  Schedule Input URIs: %v", config.ScheduleInputURIs)
+	log.Printf("  Schedule Output URI Prefix: %s", config.ScheduleOutputURIPrefix)
+	log.Printf("  Event Trigger: %t", config.EventTrigger)
+	log.Printf("  Event Input Prefix: %s", config.EventInputPrefix)
+	log.Printf("  Event Manifest Suffix: %s", config.EventManifestSuffix)
+	log.Printf("  Notifications: %t", config.Notifications)
+	log.Printf("  Notification Webhook URLs: %v", config.NotificationWebhookURLs)
+	log.Printf("  Notification Raw Payload: %t", config.NotificationRawPayload)
+	log.Printf("  Retention Keep Last: %d", config.RetentionKeepLast)
+	log.Printf("  Retention Max Age Days: %d", config.RetentionMaxAgeDays)
+	log.Printf("  Retention Cron: %s", config.RetentionCron)
+	log.Printf("  Retention Time Zone: %s", config.RetentionTimeZone)
+	log.Printf("  Cost Min Instances Per Second: %g", config.CostMinInstancesPerSecond)
+	log.Printf("  Cost Max Instances Per Second: %g", config.CostMaxInstancesPerSecond)
+	log.Printf("  Cost Machine Prices: %v", config.CostMachinePrices)
+	log.Printf("  Cost Accelerator Prices: %v", config.CostAcceleratorPrices)
+	log.Printf("  Cost Budget USD: %g", config.CostBudgetUSD)
+	log.Printf("  Quota Preflight: %t", config.QuotaPreflight)
+	log.Printf("  Quota Preflight Warn Only: %t", config.QuotaPreflightWarnOnly)
+	log.Printf("  Shard Count: %d", config.ShardCount)
+	log.Printf("  Shard By: %s", config.ShardBy)
+	log.Printf("  Spot: %t", config.Spot)
+	log.Printf("  Reservation Affinity: %s", config.ReservationAffinity)
+	log.Printf("  Reservation Name: %s", config.ReservationName)
+	log.Printf("  KMS Key Name: %s", config.KmsKeyName)
+	log.Printf("  Create KMS Key: %t", config.CreateKmsKey)
 
 	return &config, nil
 }
@@ -91,21 +218,23 @@ func (c *Config) ToAIBatchArgs() *gcp.AIBatchArgs {
 		ModelPredictionOutputSchemaPath: c.ModelPredictionOutputSchemaPath,
 		ModelBucketBasePath:             c.ModelBucketBasePath,
 		ModelImageURL:                   pulumi.String(c.ModelImageURL),
-		MachineType:                     pulumi.String(c.MachineType),
 		EnablePrivateRegistryAccess:     c.EnablePrivateRegistryAccess,
 
 		// Batch prediction job specific fields
 		InputDataPath:        c.InputDataURI,
 		InputFormat:          c.InputFormat,
 		InputFileName:        c.InputFileName,
+		InputURIs:            c.InputURIs,
 		OutputDataPath:       pulumi.String(c.OutputDataURIPrefix),
 		OutputFormat:         pulumi.String(c.OutputFormat),
-		StartingReplicaCount: pulumi.Int(c.StartingReplicaCount),
-		MaxReplicaCount:      pulumi.Int(c.MaxReplicaCount),
 		BatchSize:            pulumi.Int(c.BatchSize),
-		AcceleratorType:      pulumi.String(c.AcceleratorType),
-		AcceleratorCount:     pulumi.Int(c.AcceleratorCount),
 		RetainJobOnDelete:    c.RetainJobOnDelete,
+		VersionedRunPrefixes: c.VersionedRunPrefixes,
+		Spot:                 c.Spot,
+
+		// Encryption
+		KmsKeyName:   c.KmsKeyName,
+		CreateKmsKey: c.CreateKmsKey,
 	}
 
 	// Set optional fields only if provided
@@ -115,9 +244,150 @@ func (c *Config) ToAIBatchArgs() *gcp.AIBatchArgs {
 	if c.ModelDisplayName != "" {
 		args.ModelDisplayName = pulumi.String(c.ModelDisplayName)
 	}
+	// The default machine spec is left unset when the garden model profile or the TPU machine type derive it
+	_, hasGardenProfile := gcp.GardenModelProfiles[c.ModelName]
+	derivesMachineType := hasGardenProfile && c.MachineType == defaultMachineType
+	if c.MachineType != "" && !derivesMachineType {
+		args.MachineType = pulumi.String(c.MachineType)
+	}
+	derivesAccelerators := derivesMachineType || gcp.IsTPUMachineType(c.MachineType)
+	if c.AcceleratorType != "" && !(derivesAccelerators && c.AcceleratorType == defaultAcceleratorType) {
+		args.AcceleratorType = pulumi.String(c.AcceleratorType)
+	}
+	if c.AcceleratorCount > 0 && !(derivesAccelerators && c.AcceleratorCount == defaultAcceleratorCount) {
+		args.AcceleratorCount = pulumi.Int(c.AcceleratorCount)
+	}
+	// The default replica counts are left unset when the garden model profile derives them
+	if c.StartingReplicaCount > 0 && !(hasGardenProfile && c.StartingReplicaCount == defaultStartingReplicaCount) {
+		args.StartingReplicaCount = pulumi.Int(c.StartingReplicaCount)
+	}
+	if c.MaxReplicaCount > 0 && !(hasGardenProfile && c.MaxReplicaCount == defaultMaxReplicaCount) {
+		args.MaxReplicaCount = pulumi.Int(c.MaxReplicaCount)
+	}
 	if c.ModelPredictionBehaviorSchemaPath != "" {
 		args.ModelPredictionBehaviorSchemaPath = c.ModelPredictionBehaviorSchemaPath
 	}
+	args.ContainerSpec = c.containerSpec()
+	if c.InputBigQueryURI != "" {
+		// Instances are read from BigQuery regardless of the default file format
+		args.InputBigQueryURI = c.InputBigQueryURI
+		args.InputFormat = "bigquery"
+	}
+	if c.WaitForCompletion {
+		args.WaitForCompletion = &gcp.WaitForCompletionArgs{
+			Timeout:               c.WaitForCompletionTimeout,
+			FailOnUnsuccessfulJob: c.FailOnUnsuccessfulJob,
+		}
+	}
+	switch {
+	case c.RunID != "" || c.RunIDStrategy == "explicit":
+		args.RunIDStrategy = gcp.ExplicitRunID(c.RunID)
+	case c.RunIDStrategy == "content-hash":
+		args.RunIDStrategy = gcp.ContentHashRunID{}
+	case c.RunIDStrategy == "timestamp":
+		args.RunIDStrategy = gcp.TimestampRunID{}
+	}
+	if c.MaxJobAttempts > 0 {
+		args.RetryPolicy = &gcp.RetryPolicyArgs{
+			MaxAttempts: c.MaxJobAttempts,
+		}
+	}
+	if c.ScheduleCron != "" {
+		args.Schedule = &gcp.ScheduleArgs{
+			Cron:            c.ScheduleCron,
+			TimeZone:        c.ScheduleTimeZone,
+			InputURIs:       c.ScheduleInputURIs,
+			OutputURIPrefix: c.ScheduleOutputURIPrefix,
+		}
+	}
+	if c.EventTrigger {
+		args.EventTrigger = &gcp.EventTriggerArgs{
+			InputPrefix:    c.EventInputPrefix,
+			ManifestSuffix: c.EventManifestSuffix,
+		}
+	}
+	if c.Notifications {
+		args.Notifications = &gcp.NotificationsArgs{
+			WebhookURLs: c.NotificationWebhookURLs,
+			RawPayload:  c.NotificationRawPayload,
+		}
+	}
+	if c.RetentionKeepLast > 0 || c.RetentionMaxAgeDays > 0 {
+		args.RetentionPolicy = &gcp.RetentionPolicyArgs{
+			KeepLast:   c.RetentionKeepLast,
+			MaxAgeDays: c.RetentionMaxAgeDays,
+			Cron:       c.RetentionCron,
+			TimeZone:   c.RetentionTimeZone,
+		}
+	}
+	if c.CostMinInstancesPerSecond > 0 {
+		args.CostEstimate = &gcp.CostEstimateArgs{
+			MinInstancesPerSecond: c.CostMinInstancesPerSecond,
+			MaxInstancesPerSecond: c.CostMaxInstancesPerSecond,
+			Prices: &gcp.PriceTable{
+				MachineTypes: c.CostMachinePrices,
+				Accelerators: c.CostAcceleratorPrices,
+			},
+			BudgetUSD: c.CostBudgetUSD,
+		}
+	}
+	if c.QuotaPreflight {
+		args.QuotaPreflight = &gcp.QuotaPreflightArgs{
+			WarnOnly: c.QuotaPreflightWarnOnly,
+		}
+	}
+	if c.ShardCount > 0 {
+		args.Sharding = &gcp.ShardingArgs{
+			Count: c.ShardCount,
+			By:    c.ShardBy,
+		}
+	}
+	if c.TpuTopology != "" {
+		args.TpuTopology = pulumi.String(c.TpuTopology)
+	}
+	if c.ReservationAffinity != "" {
+		args.ReservationAffinity = &gcp.ReservationAffinityArgs{
+			Type:            c.ReservationAffinity,
+			ReservationName: c.ReservationName,
+		}
+	}
+	if c.OutputFormat == "bigquery" {
+		// An empty dataset lets the component create one
+		args.OutputBigQuery = &gcp.BigQueryOutputArgs{
+			DatasetID: c.OutputBigQueryDataset,
+		}
+	}
 
 	return args
 }
+
+// containerSpec returns the container spec of the custom model, or nil when every setting is taken from the image
+// and the routes default to the ones of the component.
+func (c *Config) containerSpec() *gcp.ContainerSpecArgs {
+	containerSpec := &gcp.ContainerSpecArgs{
+		Env:                c.ModelEnv,
+		Ports:              c.ModelPorts,
+		Command:            c.ModelCommand,
+		Args:               c.ModelArgs,
+		SharedMemorySizeMB: c.ModelSharedMemorySizeMB,
+	}
+	isSet := len(c.ModelEnv) > 0 || len(c.ModelPorts) > 0 || len(c.ModelCommand) > 0 || len(c.ModelArgs) > 0 ||
+		c.ModelSharedMemorySizeMB > 0
+	if c.ModelPredictRoute != "" {
+		containerSpec.PredictRoute = pulumi.String(c.ModelPredictRoute)
+		isSet = true
+	}
+	if c.ModelHealthRoute != "" {
+		containerSpec.HealthRoute = pulumi.String(c.ModelHealthRoute)
+		isSet = true
+	}
+	if c.ModelStartupProbePath != "" {
+		containerSpec.StartupProbe = &gcp.ProbeArgs{HTTPGetPath: c.ModelStartupProbePath}
+		isSet = true
+	}
+	if !isSet {
+		return nil
+	}
+
+	return containerSpec
+}
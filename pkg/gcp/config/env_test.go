@@ -4,9 +4,11 @@ import (
 	"os"
 	"testing"
 
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/davidmontoyago/pulumi-gcp-ai-batch/pkg/gcp"
 	"github.com/davidmontoyago/pulumi-gcp-ai-batch/pkg/gcp/config"
 )
 
@@ -263,3 +265,365 @@ func TestToAIBatchArgs(t *testing.T) {
 	require.NotNil(t, args.AcceleratorCount)
 	assert.True(t, args.RetainJobOnDelete)
 }
+
+func TestToAIBatchArgs_WithBigQueryInput(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:       "test-project",
+		GCPRegion:        "us-central1",
+		ModelName:        "publishers/google/models/gemma2@gemma-2-2b-it",
+		InputFormat:      "jsonl",
+		InputBigQueryURI: "bq://test-project.features.reviews",
+	}
+
+	args := cfg.ToAIBatchArgs()
+	require.NotNil(t, args)
+
+	assert.Equal(t, "bq://test-project.features.reviews", args.InputBigQueryURI)
+	assert.Equal(t, "bigquery", args.InputFormat, "Input format should switch to bigquery when a BigQuery URI is set")
+	assert.Nil(t, args.OutputBigQuery, "Predictions should still be written to GCS")
+}
+
+func TestToAIBatchArgs_WithBigQueryOutput(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:            "test-project",
+		GCPRegion:             "us-central1",
+		ModelName:             "publishers/google/models/gemma2@gemma-2-2b-it",
+		OutputFormat:          "bigquery",
+		OutputBigQueryDataset: "predictions",
+	}
+
+	args := cfg.ToAIBatchArgs()
+	require.NotNil(t, args)
+	require.NotNil(t, args.OutputBigQuery)
+
+	assert.Equal(t, "predictions", args.OutputBigQuery.DatasetID)
+}
+
+func TestToAIBatchArgs_WithRunIDStrategy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		runIDStrategy    string
+		runID            string
+		expectedStrategy gcp.RunIDStrategy
+	}{
+		{
+			name:             "default strategy",
+			expectedStrategy: nil,
+		},
+		{
+			name:             "content hash",
+			runIDStrategy:    "content-hash",
+			expectedStrategy: gcp.ContentHashRunID{},
+		},
+		{
+			name:             "explicit run ID implies the explicit strategy",
+			runID:            "release-42",
+			expectedStrategy: gcp.ExplicitRunID("release-42"),
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config.Config{
+				GCPProject:    "test-project",
+				GCPRegion:     "us-central1",
+				ModelName:     "publishers/google/models/gemma2@gemma-2-2b-it",
+				RunIDStrategy: testCase.runIDStrategy,
+				RunID:         testCase.runID,
+			}
+
+			args := cfg.ToAIBatchArgs()
+			require.NotNil(t, args)
+
+			assert.Equal(t, testCase.expectedStrategy, args.RunIDStrategy)
+		})
+	}
+}
+
+func TestToAIBatchArgs_WithSharding(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:    "test-project",
+		GCPRegion:     "us-central1",
+		ModelName:     "publishers/google/models/gemma2@gemma-2-2b-it",
+		InputDataURI:  "inputs/",
+		ShardCount:    4,
+		ShardBy:       "bytes",
+		OutputFormat:  "jsonl",
+		InputFormat:   "jsonl",
+		InputFileName: "*.jsonl",
+	}
+
+	args := cfg.ToAIBatchArgs()
+	require.NotNil(t, args.Sharding)
+
+	assert.Equal(t, 4, args.Sharding.Count)
+	assert.Equal(t, gcp.ShardByBytes, args.Sharding.By)
+
+	cfg.ShardCount = 0
+	assert.Nil(t, cfg.ToAIBatchArgs().Sharding, "Sharding should be disabled by default")
+}
+
+func TestToAIBatchArgs_WithSchedule(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:        "test-project",
+		GCPRegion:         "us-central1",
+		ModelName:         "publishers/google/models/gemma2@gemma-2-2b-it",
+		ScheduleCron:      "0 2 * * *",
+		ScheduleTimeZone:  "Etc/UTC",
+		ScheduleInputURIs: []string{"gs://my-data/reviews/{date}/*.jsonl"},
+	}
+
+	args := cfg.ToAIBatchArgs()
+	require.NotNil(t, args.Schedule)
+
+	assert.Equal(t, "0 2 * * *", args.Schedule.Cron)
+	assert.Equal(t, "Etc/UTC", args.Schedule.TimeZone)
+	assert.Equal(t, []string{"gs://my-data/reviews/{date}/*.jsonl"}, args.Schedule.InputURIs)
+	assert.Empty(t, args.Schedule.OutputURIPrefix)
+
+	cfg.ScheduleCron = ""
+	assert.Nil(t, cfg.ToAIBatchArgs().Schedule, "Schedule should be disabled by default")
+}
+
+func TestToAIBatchArgs_WithEventTrigger(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:          "test-project",
+		GCPRegion:           "us-central1",
+		ModelName:           "publishers/google/models/gemma2@gemma-2-2b-it",
+		EventTrigger:        true,
+		EventInputPrefix:    "landing/",
+		EventManifestSuffix: ".manifest.json",
+	}
+
+	args := cfg.ToAIBatchArgs()
+	require.NotNil(t, args.EventTrigger)
+
+	assert.Equal(t, "landing/", args.EventTrigger.InputPrefix)
+	assert.Equal(t, ".manifest.json", args.EventTrigger.ManifestSuffix)
+
+	cfg.EventTrigger = false
+	assert.Nil(t, cfg.ToAIBatchArgs().EventTrigger, "Event trigger should be disabled by default")
+}
+
+func TestToAIBatchArgs_WithNotifications(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:              "test-project",
+		GCPRegion:               "us-central1",
+		ModelName:               "publishers/google/models/gemma2@gemma-2-2b-it",
+		Notifications:           true,
+		NotificationWebhookURLs: []string{"https://hooks.example.com/batch"},
+		NotificationRawPayload:  true,
+	}
+
+	args := cfg.ToAIBatchArgs()
+	require.NotNil(t, args.Notifications)
+
+	assert.Equal(t, []string{"https://hooks.example.com/batch"}, args.Notifications.WebhookURLs)
+	assert.True(t, args.Notifications.RawPayload)
+
+	cfg.Notifications = false
+	assert.Nil(t, cfg.ToAIBatchArgs().Notifications, "Notifications should be disabled by default")
+}
+
+func TestToAIBatchArgs_WithRetentionPolicy(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:          "test-project",
+		GCPRegion:           "us-central1",
+		ModelName:           "publishers/google/models/gemma2@gemma-2-2b-it",
+		RetainJobOnDelete:   true,
+		RetentionMaxAgeDays: 30,
+		RetentionCron:       "0 4 * * 0",
+		RetentionTimeZone:   "Europe/Madrid",
+	}
+
+	args := cfg.ToAIBatchArgs()
+	require.NotNil(t, args.RetentionPolicy)
+
+	assert.Equal(t, 0, args.RetentionPolicy.KeepLast)
+	assert.Equal(t, 30, args.RetentionPolicy.MaxAgeDays)
+	assert.Equal(t, "0 4 * * 0", args.RetentionPolicy.Cron)
+	assert.Equal(t, "Europe/Madrid", args.RetentionPolicy.TimeZone)
+
+	cfg.RetentionMaxAgeDays = 0
+	assert.Nil(t, cfg.ToAIBatchArgs().RetentionPolicy, "Retention policy should be disabled by default")
+}
+
+func TestToAIBatchArgs_WithCostEstimate(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:                "test-project",
+		GCPRegion:                 "us-central1",
+		ModelName:                 "publishers/google/models/gemma2@gemma-2-2b-it",
+		CostMinInstancesPerSecond: 5,
+		CostMaxInstancesPerSecond: 20,
+		CostAcceleratorPrices:     map[string]float64{"NVIDIA_L4": 0.7},
+		CostBudgetUSD:             50,
+	}
+
+	args := cfg.ToAIBatchArgs()
+	require.NotNil(t, args.CostEstimate)
+
+	assert.InDelta(t, 5, args.CostEstimate.MinInstancesPerSecond, 1e-9)
+	assert.InDelta(t, 20, args.CostEstimate.MaxInstancesPerSecond, 1e-9)
+	assert.Equal(t, map[string]float64{"NVIDIA_L4": 0.7}, args.CostEstimate.Prices.Accelerators)
+	assert.InDelta(t, 50, args.CostEstimate.BudgetUSD, 1e-9)
+
+	cfg.CostMinInstancesPerSecond = 0
+	assert.Nil(t, cfg.ToAIBatchArgs().CostEstimate, "Cost estimate should be disabled by default")
+}
+
+func TestToAIBatchArgs_WithQuotaPreflight(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:             "test-project",
+		GCPRegion:              "us-central1",
+		ModelName:              "publishers/google/models/gemma2@gemma-2-2b-it",
+		QuotaPreflight:         true,
+		QuotaPreflightWarnOnly: true,
+	}
+
+	args := cfg.ToAIBatchArgs()
+	require.NotNil(t, args.QuotaPreflight)
+	assert.True(t, args.QuotaPreflight.WarnOnly)
+	assert.Nil(t, args.QuotaPreflight.Client, "Quotas should be read with the default client")
+
+	cfg.QuotaPreflight = false
+	assert.Nil(t, cfg.ToAIBatchArgs().QuotaPreflight, "Quota preflight should be disabled by default")
+}
+
+func TestToAIBatchArgs_WithContainerSpec(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:        "test-project",
+		GCPRegion:         "us-central1",
+		ModelDir:          "./model",
+		ModelPredictRoute: "/v1/models/sentiment:predict",
+	}
+
+	args := cfg.ToAIBatchArgs()
+	require.NotNil(t, args.ContainerSpec)
+	assert.Equal(t, pulumi.String("/v1/models/sentiment:predict"), args.ContainerSpec.PredictRoute)
+	assert.Nil(t, args.ContainerSpec.HealthRoute, "Health route should default to the one of the component")
+
+	cfg.ModelPredictRoute = ""
+	assert.Nil(t, cfg.ToAIBatchArgs().ContainerSpec, "Container spec should default to the one of the image")
+
+	cfg.ModelEnv = map[string]string{"HF_HOME": "/tmp/huggingface"}
+	cfg.ModelPorts = []int{7080}
+	cfg.ModelSharedMemorySizeMB = 1024
+	cfg.ModelStartupProbePath = "/ready"
+	args = cfg.ToAIBatchArgs()
+	require.NotNil(t, args.ContainerSpec)
+	assert.Equal(t, map[string]string{"HF_HOME": "/tmp/huggingface"}, args.ContainerSpec.Env)
+	assert.Equal(t, []int{7080}, args.ContainerSpec.Ports)
+	assert.Equal(t, 1024, args.ContainerSpec.SharedMemorySizeMB)
+	assert.Equal(t, &gcp.ProbeArgs{HTTPGetPath: "/ready"}, args.ContainerSpec.StartupProbe)
+	assert.Nil(t, args.ContainerSpec.PredictRoute, "Predict route should default to the one of the component")
+}
+
+func TestToAIBatchArgs_WithTPUMachineType(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:  "test-project",
+		GCPRegion:   "us-central1",
+		ModelDir:    "./model",
+		MachineType: "ct5lp-hightpu-4t",
+	}
+
+	args := cfg.ToAIBatchArgs()
+	assert.Equal(t, pulumi.String("ct5lp-hightpu-4t"), args.MachineType)
+	assert.Nil(t, args.AcceleratorType, "TPU accelerator type should be derived from the machine type")
+	assert.Nil(t, args.AcceleratorCount, "TPU chip count should be derived from the machine type")
+
+	cfg.MachineType = ""
+	assert.Nil(t, cfg.ToAIBatchArgs().MachineType, "Machine type should default to the one of the component")
+
+	cfg.MachineType = "g2-standard-24"
+	cfg.AcceleratorType = "NVIDIA_L4"
+	cfg.AcceleratorCount = 2
+	args = cfg.ToAIBatchArgs()
+	assert.Equal(t, pulumi.String("NVIDIA_L4"), args.AcceleratorType)
+	assert.Equal(t, pulumi.Int(2), args.AcceleratorCount)
+}
+
+func TestToAIBatchArgs_MachineSpecDefaults(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:       "test-project",
+		GCPRegion:        "us-central1",
+		ModelDir:         "./model",
+		MachineType:      "n1-standard-2",
+		AcceleratorType:  "ACCELERATOR_TYPE_UNSPECIFIED",
+		AcceleratorCount: 1,
+	}
+
+	args := cfg.ToAIBatchArgs()
+	assert.Equal(t, pulumi.String("n1-standard-2"), args.MachineType, "Machine type should keep the env default")
+	assert.Equal(t, pulumi.String("ACCELERATOR_TYPE_UNSPECIFIED"), args.AcceleratorType)
+	assert.Equal(t, pulumi.Int(1), args.AcceleratorCount)
+
+	cfg.MachineType = "ct5lp-hightpu-4t"
+	args = cfg.ToAIBatchArgs()
+	assert.Nil(t, args.AcceleratorType, "TPU accelerator type should be derived from the machine type")
+	assert.Nil(t, args.AcceleratorCount, "TPU chip count should be derived from the machine type")
+
+	cfg.ModelDir = ""
+	cfg.ModelName = "publishers/meta/models/llama3-2@llama-3.2-3b-instruct"
+	cfg.MachineType = "n1-standard-2"
+	args = cfg.ToAIBatchArgs()
+	assert.Nil(t, args.MachineType, "Machine type should be derived from the garden model profile")
+	assert.Nil(t, args.AcceleratorType, "Accelerator type should be derived from the garden model profile")
+	assert.Nil(t, args.AcceleratorCount, "Accelerator count should be derived from the garden model profile")
+
+	cfg.ModelName = "publishers/google/models/gemma-2b-it"
+	assert.Equal(t, pulumi.String("n1-standard-2"), cfg.ToAIBatchArgs().MachineType,
+		"Machine type should keep the env default for garden models without a profile")
+}
+
+func TestToAIBatchArgs_ReplicaCountDefaults(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		GCPProject:           "test-project",
+		GCPRegion:            "us-central1",
+		ModelDir:             "./model",
+		StartingReplicaCount: 1,
+		MaxReplicaCount:      3,
+	}
+
+	args := cfg.ToAIBatchArgs()
+	assert.Equal(t, pulumi.Int(1), args.StartingReplicaCount, "Starting replica count should keep the env default")
+	assert.Equal(t, pulumi.Int(3), args.MaxReplicaCount, "Max replica count should keep the env default")
+
+	cfg.ModelDir = ""
+	cfg.ModelName = "publishers/meta/models/llama3-2@llama-3.2-3b-instruct"
+	args = cfg.ToAIBatchArgs()
+	assert.Nil(t, args.StartingReplicaCount, "Starting replica count should be derived from the garden model profile")
+	assert.Nil(t, args.MaxReplicaCount, "Max replica count should be derived from the garden model profile")
+
+	cfg.MaxReplicaCount = 2
+	assert.Equal(t, pulumi.Int(2), cfg.ToAIBatchArgs().MaxReplicaCount, "Replica counts set in the env should override the profile")
+}
@@ -0,0 +1,293 @@
+package gcp
+
+import (
+	"fmt"
+	"maps"
+	"math"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Defaults of the machine spec of the batch replicas.
+const (
+	defaultMachineType          = "n1-highmem-4"
+	defaultAcceleratorType      = "ACCELERATOR_TYPE_UNSPECIFIED"
+	defaultStartingReplicaCount = 1
+	defaultMaxReplicaCount      = 3
+)
+
+// countableFormats are the input formats made of one instance per line.
+var countableFormats = map[string]bool{
+	"jsonl":     true,
+	"csv":       true,
+	"file-list": true,
+}
+
+// PriceTable lists the hourly prices in USD of the batch prediction replicas.
+type PriceTable struct {
+	// Price of a replica machine per hour, by machine type (e.g., "n1-highmem-4").
+	MachineTypes map[string]float64
+	// Price of an accelerator per hour, by accelerator type (e.g., "NVIDIA_L4"). TPUs are priced by chip.
+	Accelerators map[string]float64
+}
+
+// DefaultPriceTable lists approximate on-demand prices of Vertex AI predictions in us-central1.
+// Prices vary by region and change over time, override them with CostEstimateArgs.Prices.
+// See: https://cloud.google.com/vertex-ai/pricing#prediction-prices
+var DefaultPriceTable = PriceTable{
+	MachineTypes: map[string]float64{
+		"n1-standard-2":  0.1093,
+		"n1-standard-4":  0.2186,
+		"n1-standard-8":  0.4372,
+		"n1-standard-16": 0.8744,
+		"n1-standard-32": 1.7488,
+		"n1-highmem-2":   0.1362,
+		"n1-highmem-4":   0.2724,
+		"n1-highmem-8":   0.5448,
+		"n1-highmem-16":  1.0896,
+		"n1-highmem-32":  2.1792,
+		"n1-highcpu-4":   0.1629,
+		"n1-highcpu-8":   0.3258,
+		"n1-highcpu-16":  0.6516,
+		"e2-standard-2":  0.0771,
+		"e2-standard-4":  0.1541,
+		"e2-standard-8":  0.3082,
+		"e2-standard-16": 0.6165,
+		"g2-standard-4":  0.2540,
+		"g2-standard-8":  0.4172,
+		"g2-standard-12": 0.5804,
+		"g2-standard-16": 0.7436,
+		"g2-standard-24": 1.1609,
+		"g2-standard-48": 2.3218,
+		"a2-highgpu-1g":  0.8487,
+		"a2-highgpu-2g":  1.6974,
+		"a2-highgpu-4g":  3.3948,
+		"a2-highgpu-8g":  6.7896,
+		"a3-highgpu-8g":  20.3510,
+		// TPU hosts, the chips are priced as accelerators
+		"ct5lp-hightpu-1t": 0,
+		"ct5lp-hightpu-4t": 0,
+		"ct5lp-hightpu-8t": 0,
+		"ct6e-standard-1t": 0,
+		"ct6e-standard-4t": 0,
+		"ct6e-standard-8t": 0,
+	},
+	Accelerators: map[string]float64{
+		"NVIDIA_TESLA_T4":   0.4025,
+		"NVIDIA_TESLA_P4":   0.6900,
+		"NVIDIA_TESLA_P100": 1.6790,
+		"NVIDIA_TESLA_V100": 2.8520,
+		"NVIDIA_L4":         0.6729,
+		"NVIDIA_TESLA_A100": 3.3741,
+		"NVIDIA_A100_80GB":  4.5173,
+		"NVIDIA_H100_80GB":  11.2600,
+		"TPU_V5_LITEPOD":    1.3800,
+		"TPU_V6E":           3.2200,
+	},
+}
+
+// CostEstimate is the estimated cost of the batch prediction jobs of the component.
+type CostEstimate struct {
+	// Number of instances in the local input files.
+	Instances int
+	// Number of jobs the instances are split into, one per shard. 1 without sharding.
+	Jobs int
+	// Price of a replica per hour, machine and accelerators.
+	ReplicaHourlyUSD float64
+	// Estimated cost at the highest throughput, with the starting replicas.
+	MinUSD float64
+	// Estimated cost at the lowest throughput, billing the max replicas for the whole job.
+	MaxUSD float64
+}
+
+// EstimateCost estimates the cost of the batch prediction job of the component from the instances in the
+// local input files, the machine spec and replica counts of args, and the throughput assumed by estimate.
+//
+// The job takes instances / (throughput * StartingReplicaCount) to run. The low end of the range bills the
+// starting replicas at the highest throughput. The high end bills MaxReplicaCount replicas at the lowest
+// throughput, as if the job scaled out without speeding up. Only the machine spec of the component is priced,
+// so jobs and model comparisons are not supported, and neither are inputs outside of the input data path.
+//
+// Sharded inputs are priced as one job per shard, each running its share of the instances on the replicas of
+// the component. The shards run concurrently, so the jobs are shorter but bill the same replica hours.
+func EstimateCost(args *AIBatchArgs, estimate *CostEstimateArgs) (*CostEstimate, error) {
+	switch {
+	case len(args.Jobs) > 0:
+		return nil, fmt.Errorf("costs of several jobs cannot be estimated")
+	case len(args.CompareModels) > 0:
+		return nil, fmt.Errorf("costs of model comparisons cannot be estimated")
+	case args.InputBigQueryURI != "" || len(args.InputURIs) > 0:
+		return nil, fmt.Errorf("only the costs of input data uploaded from the input data path can be estimated")
+	case estimate.MinInstancesPerSecond <= 0:
+		return nil, fmt.Errorf("min instances per second must be positive, got %g", estimate.MinInstancesPerSecond)
+	case estimate.MaxInstancesPerSecond != 0 && estimate.MaxInstancesPerSecond < estimate.MinInstancesPerSecond:
+		return nil, fmt.Errorf("max instances per second must be at least the min instances per second, got %g", estimate.MaxInstancesPerSecond)
+	case estimate.BudgetUSD < 0:
+		return nil, fmt.Errorf("budget must not be negative, got %g", estimate.BudgetUSD)
+	}
+
+	inputFormat := stringOrDefault(args.InputFormat, "jsonl")
+	if !countableFormats[inputFormat] {
+		return nil, fmt.Errorf("instances of input format %q cannot be counted, only jsonl, csv and file-list", inputFormat)
+	}
+	instances, err := countInstances(stringOrDefault(args.InputDataPath, "inputs"), stringOrDefault(args.InputFileName, "*.jsonl"), inputFormat)
+	if err != nil {
+		return nil, err
+	}
+	jobs := 1
+	if args.Sharding != nil {
+		// shards are capped to the number of records
+		jobs = min(args.Sharding.Count, instances)
+	}
+
+	machineType, err := knownString(args.MachineType, defaultMachineType)
+	if err != nil {
+		return nil, fmt.Errorf("machine type %w", err)
+	}
+	acceleratorType, err := knownString(args.AcceleratorType, defaultAcceleratorType)
+	if err != nil {
+		return nil, fmt.Errorf("accelerator type %w", err)
+	}
+	acceleratorCount, err := knownInt(args.AcceleratorCount, 1)
+	if err != nil {
+		return nil, fmt.Errorf("accelerator count %w", err)
+	}
+	startingReplicaCount, err := knownInt(args.StartingReplicaCount, defaultStartingReplicaCount)
+	if err != nil {
+		return nil, fmt.Errorf("starting replica count %w", err)
+	}
+	maxReplicaCount, err := knownInt(args.MaxReplicaCount, defaultMaxReplicaCount)
+	if err != nil {
+		return nil, fmt.Errorf("max replica count %w", err)
+	}
+
+	prices := estimate.priceTable()
+	replicaHourlyUSD, ok := prices.MachineTypes[machineType]
+	if !ok {
+		return nil, fmt.Errorf("no price for machine type %q, add it to the price table", machineType)
+	}
+	if acceleratorType != defaultAcceleratorType {
+		acceleratorHourlyUSD, ok := prices.Accelerators[acceleratorType]
+		if !ok {
+			return nil, fmt.Errorf("no price for accelerator type %q, add it to the price table", acceleratorType)
+		}
+		replicaHourlyUSD += float64(acceleratorCount) * acceleratorHourlyUSD
+	}
+
+	maxInstancesPerSecond := estimate.MaxInstancesPerSecond
+	if maxInstancesPerSecond == 0 {
+		maxInstancesPerSecond = estimate.MinInstancesPerSecond
+	}
+	// hours of each job, running its share of the instances
+	jobInstances := float64(instances) / float64(jobs)
+	minHours := jobInstances / (maxInstancesPerSecond * float64(startingReplicaCount)) / 3600
+	maxHours := jobInstances / (estimate.MinInstancesPerSecond * float64(startingReplicaCount)) / 3600
+
+	return &CostEstimate{
+		Instances:        instances,
+		Jobs:             jobs,
+		ReplicaHourlyUSD: replicaHourlyUSD,
+		MinUSD:           roundCents(float64(jobs) * minHours * float64(startingReplicaCount) * replicaHourlyUSD),
+		MaxUSD:           roundCents(float64(jobs) * maxHours * float64(max(maxReplicaCount, startingReplicaCount)) * replicaHourlyUSD),
+	}, nil
+}
+
+// checkBudget fails when the high end of the estimate exceeds the budget, if any.
+func checkBudget(costEstimate *CostEstimate, budgetUSD float64) error {
+	if budgetUSD > 0 && costEstimate.MaxUSD > budgetUSD {
+		return fmt.Errorf("estimated cost of up to $%.2f for %d instances exceeds the budget of $%.2f",
+			costEstimate.MaxUSD, costEstimate.Instances, budgetUSD)
+	}
+
+	return nil
+}
+
+// priceTable returns the default price table with the prices of the estimate args overriding it.
+func (estimate *CostEstimateArgs) priceTable() PriceTable {
+	prices := PriceTable{
+		MachineTypes: maps.Clone(DefaultPriceTable.MachineTypes),
+		Accelerators: maps.Clone(DefaultPriceTable.Accelerators),
+	}
+	if estimate.Prices != nil {
+		maps.Copy(prices.MachineTypes, estimate.Prices.MachineTypes)
+		maps.Copy(prices.Accelerators, estimate.Prices.Accelerators)
+	}
+
+	return prices
+}
+
+// countInstances counts the records of the input files matching the file name pattern.
+func countInstances(inputDir, fileNamePattern, format string) (int, error) {
+	inputFiles, err := shardInputFiles(inputDir, fileNamePattern)
+	if err != nil {
+		return 0, err
+	}
+	if len(inputFiles) == 0 {
+		return 0, fmt.Errorf("no input files matching %s in %s", fileNamePattern, inputDir)
+	}
+
+	instances := 0
+	err = readRecords(inputFiles, format, func(_ string, _ string) error {
+		instances++
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return instances, nil
+}
+
+// outputs returns the estimate as stack outputs.
+func (estimate *CostEstimate) outputs() pulumi.Map {
+	return pulumi.Map{
+		"instances":          pulumi.Int(estimate.Instances),
+		"jobs":               pulumi.Int(estimate.Jobs),
+		"replica_hourly_usd": pulumi.Float64(estimate.ReplicaHourlyUSD),
+		"min_usd":            pulumi.Float64(estimate.MinUSD),
+		"max_usd":            pulumi.Float64(estimate.MaxUSD),
+	}
+}
+
+// roundCents rounds a price to the cent.
+func roundCents(usd float64) float64 {
+	return math.Round(usd*100) / 100
+}
+
+// stringOrDefault returns the value, or the default value when it is empty.
+func stringOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+// knownString returns the value of the input, or the default value when it is not set.
+// It fails when the value is only known at deployment time.
+func knownString(input pulumi.StringInput, defaultValue string) (string, error) {
+	if input == nil {
+		return defaultValue, nil
+	}
+	value, ok := plainString(input)
+	if !ok {
+		return "", fmt.Errorf("must be known before deployment to estimate costs")
+	}
+
+	return value, nil
+}
+
+// knownInt returns the value of the input, or the default value when it is not set.
+// It fails when the value is only known at deployment time.
+func knownInt(input pulumi.IntInput, defaultValue int) (int, error) {
+	if input == nil {
+		return defaultValue, nil
+	}
+	value, ok := plainInt(input)
+	if !ok {
+		return 0, fmt.Errorf("must be known before deployment to estimate costs")
+	}
+
+	return value, nil
+}
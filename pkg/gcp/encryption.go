@@ -0,0 +1,112 @@
+package gcp
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/bigquery"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/kms"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/storage"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// kmsKeyNamePattern matches "projects/{project}/locations/{location}/keyRings/{keyRing}/cryptoKeys/{key}".
+var kmsKeyNamePattern = regexp.MustCompile(`^projects/[^/]+/locations/([^/]+)/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+// validateKmsKeyName checks the key name format and that the key is in the same region as the component.
+// Vertex AI and regional buckets only accept keys from their own location.
+func validateKmsKeyName(kmsKeyName, region string) error {
+	matches := kmsKeyNamePattern.FindStringSubmatch(kmsKeyName)
+	if matches == nil {
+		return fmt.Errorf("KMS key name %q must be in the form projects/PROJECT/locations/LOCATION/keyRings/KEY_RING/cryptoKeys/KEY", kmsKeyName)
+	}
+	if matches[1] != region {
+		return fmt.Errorf("KMS key location %q must match the region %q", matches[1], region)
+	}
+
+	return nil
+}
+
+// setupEncryption creates the KMS key when requested, and grants the service agents of the
+// encrypted data stores access to the key. It returns the name of the key.
+func (v *AIBatch) setupEncryption(ctx *pulumi.Context, args *AIBatchArgs) (pulumi.StringOutput, error) {
+	kmsKeyName := pulumi.String(args.KmsKeyName).ToStringOutput()
+
+	if args.CreateKmsKey {
+		// Key rings and keys cannot be deleted in GCP. On destroy, they are only removed from the
+		// stack and the key versions are scheduled for destruction.
+		keyRing, err := kms.NewKeyRing(ctx, v.NewResourceName("vertex-batch", "keyring", 63), &kms.KeyRingArgs{
+			Name:     pulumi.String(v.NewResourceName("vertex-batch", "keyring", 63)),
+			Location: pulumi.String(v.Region),
+			Project:  pulumi.String(v.Project),
+		}, pulumi.Parent(v))
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("failed to create KMS key ring: %w", err)
+		}
+
+		cryptoKey, err := kms.NewCryptoKey(ctx, v.NewResourceName("vertex-batch", "key", 63), &kms.CryptoKeyArgs{
+			Name:           pulumi.String(v.NewResourceName("vertex-batch", "key", 63)),
+			KeyRing:        keyRing.ID(),
+			Purpose:        pulumi.String("ENCRYPT_DECRYPT"),
+			RotationPeriod: pulumi.String("7776000s"), // 90 days
+			Labels:         pulumi.ToStringMap(args.Labels),
+		}, pulumi.Parent(v))
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("failed to create KMS key: %w", err)
+		}
+		v.kmsCryptoKey = cryptoKey
+
+		kmsKeyName = cryptoKey.ID().ToStringOutput()
+	}
+
+	// Service agents encrypt and decrypt on behalf of the bucket, the job and the predictions dataset
+	storageAgent := storage.GetProjectServiceAccountOutput(ctx, storage.GetProjectServiceAccountOutputArgs{
+		Project: pulumi.String(v.Project),
+	}, pulumi.Parent(v))
+
+	vertexAgent, err := v.vertexServiceAgent(ctx)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	agentMembers := map[string]pulumi.StringOutput{
+		"storage-agent": storageAgent.Member(),
+		"vertex-agent":  vertexAgent.Member,
+	}
+	if args.OutputBigQuery != nil && args.OutputBigQuery.DatasetID == "" {
+		bigQueryAgent := bigquery.GetDefaultServiceAccountOutput(ctx, bigquery.GetDefaultServiceAccountOutputArgs{
+			Project: pulumi.String(v.Project),
+		}, pulumi.Parent(v))
+		agentMembers["bigquery-agent"] = bigQueryAgent.Member()
+	}
+
+	// Sorted for a stable resource order
+	for _, agent := range []string{"bigquery-agent", "storage-agent", "vertex-agent"} {
+		member, ok := agentMembers[agent]
+		if !ok {
+			continue
+		}
+
+		keyMember, err := kms.NewCryptoKeyIAMMember(ctx, v.NewResourceName(agent, "kms-iam-member", 63), &kms.CryptoKeyIAMMemberArgs{
+			CryptoKeyId: kmsKeyName,
+			Role:        pulumi.String("roles/cloudkms.cryptoKeyEncrypterDecrypter"),
+			Member:      member,
+		}, pulumi.Parent(v))
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("failed to grant %s access to the KMS key: %w", agent, err)
+		}
+		v.kmsKeyIamMembers = append(v.kmsKeyIamMembers, keyMember)
+	}
+
+	return kmsKeyName, nil
+}
+
+// kmsKeyDependencies returns the KMS key IAM bindings as dependencies for the encrypted resources.
+func (v *AIBatch) kmsKeyDependencies() []pulumi.Resource {
+	dependencies := make([]pulumi.Resource, len(v.kmsKeyIamMembers))
+	for i, member := range v.kmsKeyIamMembers {
+		dependencies[i] = member
+	}
+
+	return dependencies
+}
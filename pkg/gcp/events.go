@@ -0,0 +1,198 @@
+package gcp
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/eventarc"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/projects"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/storage"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// objectFinalizedEventType is the Eventarc event of a new object in a bucket.
+const objectFinalizedEventType = "google.cloud.storage.object.v1.finalized"
+
+// validateEventTrigger checks the event trigger and the component fields it cannot be combined with.
+func validateEventTrigger(args *AIBatchArgs) error {
+	switch {
+	case len(args.Jobs) > 0:
+		return fmt.Errorf("jobs cannot be combined with event triggers")
+	case len(args.CompareModels) > 0:
+		return fmt.Errorf("model comparisons cannot be combined with event triggers")
+	case args.Sharding != nil:
+		return fmt.Errorf("sharding cannot be combined with event triggers")
+	case args.VersionedRunPrefixes:
+		return fmt.Errorf("versioned run prefixes cannot be combined with event triggers, triggered runs are versioned by input file")
+	case args.GenerateExplanation:
+		return fmt.Errorf("explanations cannot be combined with event triggers")
+	case args.InputBigQueryURI != "":
+		return fmt.Errorf("BigQuery inputs cannot be triggered by new files")
+	}
+
+	if args.EventTrigger.InputPrefix == "" {
+		args.EventTrigger.InputPrefix = "inputs/events/"
+	}
+	args.EventTrigger.InputPrefix = strings.Trim(args.EventTrigger.InputPrefix, "/") + "/"
+	if args.EventTrigger.InputPrefix == "/" {
+		return fmt.Errorf("input prefix cannot be the root of the bucket")
+	}
+	if args.EventTrigger.ManifestSuffix == "" {
+		args.EventTrigger.ManifestSuffix = ".manifest.json"
+	}
+
+	return nil
+}
+
+// createEventTrigger deploys a workflow submitting a batch prediction job like the job of the spec for every
+// new input file or manifest under the input prefix of the artifacts bucket, and the Eventarc trigger starting it.
+func (v *AIBatch) createEventTrigger(ctx *pulumi.Context, spec *batchJobSpec, trigger *EventTriggerArgs) error {
+	serviceAccountEmail, err := v.setupWorkflowServiceAccount(ctx)
+	if err != nil {
+		return err
+	}
+	// The trigger delivers the events as the workflows Service Account
+	err = v.grantWorkflowRoles(ctx, "roles/eventarc.eventReceiver")
+	if err != nil {
+		return err
+	}
+	// which reads the manifests
+	manifestReader, err := storage.NewBucketIAMMember(ctx, v.NewResourceName("workflow-sa-manifests", "iam-member", 63), &storage.BucketIAMMemberArgs{
+		Bucket: v.artifactsBucket.Name,
+		Role:   pulumi.String("roles/storage.objectViewer"),
+		Member: pulumi.Sprintf("serviceAccount:%s", serviceAccountEmail),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return fmt.Errorf("failed to grant workflows access to the manifests: %w", err)
+	}
+	v.workflowDependencies = append(v.workflowDependencies, manifestReader)
+
+	// Cloud Storage publishes the bucket events to the Pub/Sub transport of the trigger
+	storageAgent := storage.GetProjectServiceAccountOutput(ctx, storage.GetProjectServiceAccountOutputArgs{
+		Project: pulumi.String(v.Project),
+	}, pulumi.Parent(v))
+	storageAgentPublisher, err := projects.NewIAMMember(ctx, v.NewResourceName("storage-agent-pubsub-publisher", "iam-member", 63), &projects.IAMMemberArgs{
+		Project: pulumi.String(v.Project),
+		Role:    pulumi.String("roles/pubsub.publisher"),
+		Member:  storageAgent.Member(),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return fmt.Errorf("failed to grant the storage service agent access to Pub/Sub: %w", err)
+	}
+
+	inputFileURI := pulumi.Sprintf("gs://%s/{object}", v.artifactsBucket.Name).ApplyT(func(template string) []string {
+		return []string{workflowExpression(template, "object")}
+	}).(pulumi.StringArrayOutput)
+	outputURIPrefix := pulumi.All(v.artifactsBucket.Name, spec.outputDataPath).ApplyT(func(values []interface{}) string {
+		return workflowExpression(fmt.Sprintf("gs://%s/%s", values[0], subPrefix(subPrefix(values[1].(string), "events"), "{relative}")), "relative")
+	}).(pulumi.StringOutput)
+	displayName := spec.displayName.ApplyT(func(displayName string) string {
+		return workflowExpression(displayName+"-{relative}", "relative")
+	}).(pulumi.StringOutput)
+
+	// Eventarc cannot filter the events of a bucket by prefix, the workflow skips the other objects
+	inputPattern := "^" + regexp.QuoteMeta(trigger.InputPrefix) + ".*[^/]$"
+	manifestPattern := regexp.QuoteMeta(trigger.ManifestSuffix) + "$"
+
+	definition := pulumi.Map{
+		"main": pulumi.Map{
+			"params": pulumi.ToStringArray([]string{"event"}),
+			"steps": pulumi.Array{
+				pulumi.Map{"init": pulumi.Map{
+					"assign": pulumi.Array{
+						pulumi.Map{"object": pulumi.String("${event.data.name}")},
+					},
+				}},
+				pulumi.Map{"filter": pulumi.Map{
+					"switch": pulumi.Array{
+						pulumi.Map{
+							"condition": pulumi.String(fmt.Sprintf("${not(text.match_regex(object, %s))}", strconv.Quote(inputPattern))),
+							"return":    pulumi.String(`${"skipped " + object}`),
+						},
+					},
+				}},
+				// the new file is the input, unless it is a manifest listing the inputs
+				pulumi.Map{"inputs": pulumi.Map{
+					"assign": pulumi.Array{
+						pulumi.Map{"relative": pulumi.String(fmt.Sprintf("${text.substring(object, %d, len(object))}", len(trigger.InputPrefix)))},
+						pulumi.Map{"inputURIs": inputFileURI},
+					},
+				}},
+				pulumi.Map{"manifest": pulumi.Map{
+					"switch": pulumi.Array{
+						pulumi.Map{
+							"condition": pulumi.String(fmt.Sprintf("${text.match_regex(object, %s)}", strconv.Quote(manifestPattern))),
+							"steps": pulumi.Array{
+								pulumi.Map{"read": pulumi.Map{
+									"call": pulumi.String("googleapis.storage.v1.objects.get"),
+									"args": pulumi.Map{
+										"bucket": pulumi.String("${event.data.bucket}"),
+										"object": pulumi.String("${text.url_encode(object)}"),
+										"alt":    pulumi.String("media"),
+									},
+									"result": pulumi.String("manifestContent"),
+								}},
+								pulumi.Map{"useManifest": pulumi.Map{
+									"assign": pulumi.Array{
+										pulumi.Map{"inputURIs": pulumi.String("${manifestContent.uris}")},
+									},
+								}},
+							},
+						},
+					},
+				}},
+				pulumi.Map{"submit": pulumi.Map{
+					"call": pulumi.String("http.post"),
+					"args": pulumi.Map{
+						"url":  pulumi.String(v.batchJobsURL()),
+						"auth": pulumi.Map{"type": pulumi.String("OAuth2")},
+						"body": v.batchJobRequest(spec, v.modelServiceAccountEmail, displayName,
+							pulumi.Map{"gcsSource": pulumi.Map{"uris": pulumi.String("${inputURIs}")}}, outputURIPrefix),
+					},
+					"result": pulumi.String("job"),
+				}},
+				pulumi.Map{"done": pulumi.Map{
+					"return": pulumi.String("${job.body.name}"),
+				}},
+			},
+		},
+	}
+
+	workflow, err := v.createWorkflow(ctx, "batch-events", "Submits batch prediction jobs for new input files", definition)
+	if err != nil {
+		return err
+	}
+	v.eventWorkflow = workflow
+
+	eventTrigger, err := eventarc.NewTrigger(ctx, v.NewResourceName("batch-events", "trigger", 63), &eventarc.TriggerArgs{
+		Project:  pulumi.String(v.Project),
+		Location: pulumi.String(v.Region), // the artifacts bucket is in Region
+		Name:     pulumi.String(v.NewResourceName("batch-events", "trigger", 63)),
+		MatchingCriterias: eventarc.TriggerMatchingCriteriaArray{
+			&eventarc.TriggerMatchingCriteriaArgs{
+				Attribute: pulumi.String("type"),
+				Value:     pulumi.String(objectFinalizedEventType),
+			},
+			&eventarc.TriggerMatchingCriteriaArgs{
+				Attribute: pulumi.String("bucket"),
+				Value:     v.artifactsBucket.Name,
+			},
+		},
+		Destination: &eventarc.TriggerDestinationArgs{
+			Workflow: v.workflowResourceName(workflow),
+		},
+		ServiceAccount: serviceAccountEmail,
+		Labels:         pulumi.ToStringMap(v.Labels),
+	},
+		pulumi.Parent(v),
+		pulumi.DependsOn([]pulumi.Resource{workflow, storageAgentPublisher}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create event trigger: %w", err)
+	}
+	v.eventarcTrigger = eventTrigger
+
+	return nil
+}
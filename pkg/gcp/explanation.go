@@ -0,0 +1,155 @@
+package gcp
+
+import (
+	"fmt"
+
+	v1 "github.com/pulumi/pulumi-google-native/sdk/go/google/aiplatform/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Feature attribution methods supported for batch explanations.
+const (
+	ExplanationMethodSampledShapley      = "sampled-shapley"
+	ExplanationMethodIntegratedGradients = "integrated-gradients"
+	ExplanationMethodXRAI                = "xrai"
+)
+
+// validateExplanationSpec checks the explanation spec and fills in the method defaults.
+func validateExplanationSpec(spec *ExplanationSpecArgs) error {
+	switch spec.Method {
+	case ExplanationMethodSampledShapley:
+		if spec.PathCount == 0 {
+			spec.PathCount = 10
+		}
+		if spec.PathCount < 1 || spec.PathCount > 50 {
+			return fmt.Errorf("path count must be between 1 and 50, got %d", spec.PathCount)
+		}
+	case ExplanationMethodIntegratedGradients, ExplanationMethodXRAI:
+		if spec.StepCount == 0 {
+			spec.StepCount = 50
+		}
+		if spec.StepCount < 1 || spec.StepCount > 100 {
+			return fmt.Errorf("step count must be between 1 and 100, got %d", spec.StepCount)
+		}
+	default:
+		return fmt.Errorf("explanation method must be one of %q, %q or %q, got %q",
+			ExplanationMethodSampledShapley, ExplanationMethodIntegratedGradients, ExplanationMethodXRAI, spec.Method)
+	}
+
+	if spec.Metadata != nil && len(spec.Metadata.Outputs) > 1 {
+		return fmt.Errorf("only one explanation output can be set, got %d", len(spec.Metadata.Outputs))
+	}
+
+	return nil
+}
+
+// toExplanationSpecArgs maps the explanation spec to the batch prediction job config.
+// The SDK does not model the input and output metadata: jobs with them are managed by the gcp-ai-batch
+// provider with explanationSpecRequest.
+func toExplanationSpecArgs(spec *ExplanationSpecArgs, modelArtifactsURI pulumi.StringOutput) *v1.GoogleCloudAiplatformV1ExplanationSpecArgs {
+	parameters := v1.GoogleCloudAiplatformV1ExplanationParametersArgs{}
+	switch spec.Method {
+	case ExplanationMethodSampledShapley:
+		parameters.SampledShapleyAttribution = &v1.GoogleCloudAiplatformV1SampledShapleyAttributionArgs{
+			PathCount: pulumi.Int(spec.PathCount),
+		}
+	case ExplanationMethodIntegratedGradients:
+		parameters.IntegratedGradientsAttribution = &v1.GoogleCloudAiplatformV1IntegratedGradientsAttributionArgs{
+			StepCount: pulumi.Int(spec.StepCount),
+		}
+	case ExplanationMethodXRAI:
+		parameters.XraiAttribution = &v1.GoogleCloudAiplatformV1XraiAttributionArgs{
+			StepCount: pulumi.Int(spec.StepCount),
+		}
+	}
+	if spec.TopK > 0 {
+		parameters.TopK = pulumi.Int(spec.TopK)
+	}
+
+	explanationSpec := &v1.GoogleCloudAiplatformV1ExplanationSpecArgs{
+		Parameters: parameters,
+	}
+
+	if spec.Metadata != nil {
+		metadata := &v1.GoogleCloudAiplatformV1ExplanationMetadataArgs{}
+		if spec.Metadata.FeatureAttributionsSchemaPath != "" {
+			metadata.FeatureAttributionsSchemaUri = pulumi.Sprintf("%s/%s", modelArtifactsURI, spec.Metadata.FeatureAttributionsSchemaPath)
+		}
+		if spec.Metadata.LatentSpaceSource != "" {
+			metadata.LatentSpaceSource = pulumi.String(spec.Metadata.LatentSpaceSource)
+		}
+		explanationSpec.Metadata = metadata
+	}
+
+	return explanationSpec
+}
+
+// explanationSpecRequest maps the explanation spec to the body of a Vertex AI request, with the input and
+// output metadata the google-native SDK types as string maps while the API expects objects.
+func explanationSpecRequest(spec *ExplanationSpecArgs, modelArtifactsURI pulumi.StringOutput) pulumi.Map {
+	parameters := pulumi.Map{}
+	switch spec.Method {
+	case ExplanationMethodSampledShapley:
+		parameters["sampledShapleyAttribution"] = pulumi.Map{"pathCount": pulumi.Int(spec.PathCount)}
+	case ExplanationMethodIntegratedGradients:
+		parameters["integratedGradientsAttribution"] = pulumi.Map{"stepCount": pulumi.Int(spec.StepCount)}
+	case ExplanationMethodXRAI:
+		parameters["xraiAttribution"] = pulumi.Map{"stepCount": pulumi.Int(spec.StepCount)}
+	}
+	if spec.TopK > 0 {
+		parameters["topK"] = pulumi.Int(spec.TopK)
+	}
+
+	explanationSpec := pulumi.Map{
+		"parameters": parameters,
+	}
+	if spec.Metadata == nil {
+		return explanationSpec
+	}
+
+	inputs := pulumi.Map{}
+	for feature, input := range spec.Metadata.Inputs {
+		inputMetadata := pulumi.Map{}
+		if input.InputTensorName != "" {
+			inputMetadata["inputTensorName"] = pulumi.String(input.InputTensorName)
+		}
+		if input.Encoding != "" {
+			inputMetadata["encoding"] = pulumi.String(input.Encoding)
+		}
+		if input.Modality != "" {
+			inputMetadata["modality"] = pulumi.String(input.Modality)
+		}
+		inputs[feature] = inputMetadata
+	}
+
+	outputs := pulumi.Map{}
+	for name, output := range spec.Metadata.Outputs {
+		outputMetadata := pulumi.Map{}
+		if output.OutputTensorName != "" {
+			outputMetadata["outputTensorName"] = pulumi.String(output.OutputTensorName)
+		}
+		if output.DisplayNameMappingKey != "" {
+			outputMetadata["displayNameMappingKey"] = pulumi.String(output.DisplayNameMappingKey)
+		}
+		outputs[name] = outputMetadata
+	}
+
+	metadata := pulumi.Map{
+		"inputs":  inputs,
+		"outputs": outputs,
+	}
+	if spec.Metadata.FeatureAttributionsSchemaPath != "" {
+		metadata["featureAttributionsSchemaUri"] = pulumi.Sprintf("%s/%s", modelArtifactsURI, spec.Metadata.FeatureAttributionsSchemaPath)
+	}
+	if spec.Metadata.LatentSpaceSource != "" {
+		metadata["latentSpaceSource"] = pulumi.String(spec.Metadata.LatentSpaceSource)
+	}
+	explanationSpec["metadata"] = metadata
+
+	return explanationSpec
+}
+
+// hasExplanationMetadataFeatures returns true if the explanation spec describes model inputs or outputs.
+func hasExplanationMetadataFeatures(spec *ExplanationSpecArgs) bool {
+	return spec != nil && spec.Metadata != nil && (len(spec.Metadata.Inputs) > 0 || len(spec.Metadata.Outputs) > 0)
+}
@@ -0,0 +1,72 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// googleAPIError is an unsuccessful response of a Google Cloud REST API.
+type googleAPIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *googleAPIError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
+// isGoogleAPINotFound returns true if the error is a 404 response of a Google Cloud REST API.
+func isGoogleAPINotFound(err error) bool {
+	var apiErr *googleAPIError
+
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// sendGoogleAPIRequest sends an authenticated request with the JSON body, if any, and decodes the JSON
+// response into result, if any. Unsuccessful responses are returned as a *googleAPIError.
+func sendGoogleAPIRequest(ctx context.Context, httpClient *http.Client, accessToken, method, requestURL string, body, result any) error {
+	var requestBody io.Reader
+	if body != nil {
+		encodedBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		requestBody = bytes.NewReader(encodedBody)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, requestURL, requestBody)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+
+		return &googleAPIError{StatusCode: response.StatusCode, Body: strings.TrimSpace(string(responseBody))}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
@@ -0,0 +1,67 @@
+package gcp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/storage"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const gcsURIPrefix = "gs://"
+
+// parseGCSBucketName returns the bucket of a URI in the form "gs://bucket/path/to/objects*".
+func parseGCSBucketName(uri string) (string, error) {
+	if !strings.HasPrefix(uri, gcsURIPrefix) {
+		return "", fmt.Errorf("GCS URI %q must start with %q", uri, gcsURIPrefix)
+	}
+
+	bucketName := strings.SplitN(strings.TrimPrefix(uri, gcsURIPrefix), "/", 2)[0]
+	if bucketName == "" || strings.ContainsAny(bucketName, "*?[") {
+		return "", fmt.Errorf("GCS URI %q must point to a bucket by name", uri)
+	}
+
+	return bucketName, nil
+}
+
+// inputBucketNames returns the unique source buckets of the input URIs, in order of appearance.
+func inputBucketNames(uris []string) ([]string, error) {
+	var bucketNames []string
+	for _, uri := range uris {
+		bucketName, err := parseGCSBucketName(uri)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(bucketNames, bucketName) {
+			bucketNames = append(bucketNames, bucketName)
+		}
+	}
+
+	return bucketNames, nil
+}
+
+// grantInputBucketsIAMAccess grants the member read access to the buckets holding existing input data.
+// The grantee names the bindings of the member, e.g., "model-sa".
+func (v *AIBatch) grantInputBucketsIAMAccess(ctx *pulumi.Context, grantee string, member pulumi.StringOutput, inputURIs []string) ([]*storage.BucketIAMMember, error) {
+	bucketNames, err := inputBucketNames(inputURIs)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketMembers := make([]*storage.BucketIAMMember, len(bucketNames))
+	for bucketIndex, bucketName := range bucketNames {
+		bindingName := v.NewResourceName(fmt.Sprintf("%s-input-%s", grantee, bucketName), "iam-member", 63)
+		bucketMember, err := storage.NewBucketIAMMember(ctx, bindingName, &storage.BucketIAMMemberArgs{
+			Bucket: pulumi.String(bucketName),
+			Role:   pulumi.String("roles/storage.objectViewer"),
+			Member: member,
+		}, pulumi.Parent(v))
+		if err != nil {
+			return nil, fmt.Errorf("failed to grant read access to input bucket %s: %w", bucketName, err)
+		}
+		bucketMembers[bucketIndex] = bucketMember
+	}
+
+	return bucketMembers, nil
+}
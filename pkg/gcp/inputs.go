@@ -2,6 +2,8 @@
 package gcp
 
 import (
+	"time"
+
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
@@ -18,10 +20,14 @@ type AIBatchArgs struct {
 	// Defaults to Google's TensorFlow 2.15 CPU prediction container.
 	// Example: "gcr.io/my-project/my-model:latest"
 	ModelImageURL pulumi.StringInput
+	// Container spec of the custom model: routes, environment, ports, command, args, shared memory size and
+	// startup probe. Optional, only used when ModelDir is set.
+	ContainerSpec *ContainerSpecArgs
 	// Path to the model artifacts for deployment, including the schemas. Required if ModelName is not set.
 	ModelDir string
 	// Name of the model from the garden. Required if ModelDir is not set.
 	// E.g.: publishers/google/models/gemma2@gemma-2-2b-it
+	// Models of GardenModelProfiles default to the machine spec and replica counts of their profile.
 	ModelName string
 	// Path to the YAML file within ModelDir with the model prediction input schema. Required if ModelDir is set.
 	ModelPredictionInputSchemaPath string
@@ -44,9 +50,57 @@ type AIBatchArgs struct {
 	EnablePrivateRegistryAccess bool
 	// Every pulumi up operation is a new job launch with a unique name.
 	// Set this to true to retain jobs in between runs, and ensure old jobs are
-	// eventually cleaned up.
+	// eventually cleaned up, e.g., with a RetentionPolicy.
 	// If not set, the job will be replaced regardless of the state.
 	RetainJobOnDelete bool
+	// Generates the run ID that makes the job unique. The job is relaunched whenever the run ID changes.
+	// Use ContentHashRunID to relaunch only when inputs, model or settings change, or ExplicitRunID to
+	// control launches from the caller. Defaults to TimestampRunID, a new job on every update,
+	// or to no run ID when RetryPolicy is set.
+	RunIDStrategy RunIDStrategy
+	// If true, the inputs and predictions of each run are kept under "<prefix>/<run-id>/" instead of
+	// overwriting the previous run, and the runs are listed in "runs/index.json" in the artifacts bucket.
+	// Requires a run ID, see RunIDStrategy.
+	VersionedRunPrefixes bool
+	// Resubmit the job on the next update when the previous attempt failed or was cancelled. Optional.
+	// When set, job names are stable and suffixed with the attempt number instead of unique per update,
+	// so a succeeded or running job is left alone.
+	RetryPolicy *RetryPolicyArgs
+	// Block the deployment until the job succeeds, fails or is cancelled. Optional.
+	// By default, the deployment completes as soon as the job is submitted.
+	WaitForCompletion *WaitForCompletionArgs
+	// Launch recurring runs on fresh data from a Cloud Scheduler job, without a deployment. Optional.
+	// Runs are submitted by a Cloud Workflows workflow, with the model, machine spec and Service Account
+	// of the job above. The job of the component is launched on updates as usual.
+	// Cannot be combined with Jobs, CompareModels, Sharding, VersionedRunPrefixes or BigQuery inputs.
+	Schedule *ScheduleArgs
+	// Launch a job for every input file or manifest landing under a prefix of the artifacts bucket. Optional.
+	// An Eventarc trigger starts a Cloud Workflows workflow submitting the jobs, with the model, machine spec
+	// and Service Account of the job above. Can be combined with Schedule.
+	// Cannot be combined with Jobs, CompareModels, Sharding, VersionedRunPrefixes or BigQuery inputs.
+	EventTrigger *EventTriggerArgs
+	// Publish the job state changes and the prediction writes to a Pub/Sub topic, and optionally push
+	// them to webhooks. Optional.
+	Notifications *NotificationsArgs
+	// Delete the jobs of the component that fall outside of the policy, and their predictions, on a schedule.
+	// Jobs are labeled with the component to be found. Optional.
+	RetentionPolicy *RetentionPolicyArgs
+	// Estimate the cost of the job from the local input files before launching it, and optionally fail the
+	// deployment above a budget. The estimated range is exported as an output. Optional.
+	CostEstimate *CostEstimateArgs
+	// Check the accelerator and concurrent job quotas of the region before creating the jobs, and fail or
+	// warn when the max replicas of the jobs would exceed them. Optional.
+	QuotaPreflight *QuotaPreflightArgs
+
+	// Parameters that govern the predictions, set once for the whole job instead of in every instance.
+	// For custom models, parameters are validated against ModelPredictionBehaviorSchemaPath when set.
+	ModelParameters *ModelParametersArgs
+	// If true, the job writes feature attributions next to the predictions. Requires ExplanationSpec.
+	// Only supported for custom models.
+	GenerateExplanation bool
+	// Attribution method and metadata used to explain the predictions. Jobs with input or output metadata
+	// are managed by the gcp-ai-batch provider, as the google-native provider does not model them.
+	ExplanationSpec *ExplanationSpecArgs
 
 	// --- Input data configuration ---
 	// Path to the local directory containing input data files (e.g., "data/inputs/")
@@ -55,10 +109,29 @@ type AIBatchArgs struct {
 	// bucket separately from model artifacts. Defaults to "inputs".
 	// Input data files will be uploaded to the bucket under the "inputs" directory.
 	InputDataPath string
-	// Format of input data ("jsonl", "csv", "bigquery", etc.). Defaults to "jsonl"
+	// Format of input data ("jsonl", "csv", "bigquery", etc.). Defaults to "jsonl",
+	// or to "bigquery" when InputBigQueryURI is set.
 	InputFormat string
+	// BigQuery table or view to read the instances from (e.g., "bq://my-project.my_dataset.my_table").
+	// Required when InputFormat is "bigquery". Nothing is uploaded from InputDataPath in this mode,
+	// and the model Service Account, or the Vertex AI service agent for models from the garden, is granted
+	// read access to the dataset.
+	InputBigQueryURI string
 	// Name of the input data file. Defaults to "*.jsonl"
 	InputFileName string
+	// Existing GCS URIs or globs to read the instances from (e.g., "gs://my-data/reviews/*.jsonl").
+	// URIs may point to buckets outside of the component. When set, nothing is uploaded from
+	// InputDataPath, and the model Service Account, or the Vertex AI service agent for models from the
+	// garden, is granted read access to the source buckets.
+	InputURIs []string
+	// Split the local input files into shards in NewAIBatch, before they are uploaded, and launch one job per
+	// shard. The shards are written to the system temporary directory. Optional.
+	// Works around the replica and quota limits of a single job. Only for "jsonl" and "csv" inputs
+	// uploaded from InputDataPath. Cannot be combined with Jobs, CompareModels, RetryPolicy or VersionedRunPrefixes.
+	Sharding *ShardingArgs
+	// Controls how each input row or record is converted into the instance sent to the model.
+	// Optional. Vertex AI defaults apply when not set.
+	InstanceConfig *InstanceConfigArgs
 
 	// --- Output data configuration ---
 	// Path to the directory within the bucket where the output data will be stored.
@@ -66,6 +139,11 @@ type AIBatchArgs struct {
 	OutputDataPath pulumi.StringInput
 	// Format of output data ("jsonl", "csv", "bigquery"). Defaults to "jsonl"
 	OutputFormat pulumi.StringInput
+	// Write the predictions to a BigQuery dataset instead of the artifacts bucket.
+	// When set, OutputFormat is "bigquery" and OutputDataPath is ignored. Each job creates its own predictions
+	// table, which is only exported with WaitForCompletion. The model Service Account, or the Vertex AI service
+	// agent for models from the garden, is granted write access to the dataset.
+	OutputBigQuery *BigQueryOutputArgs
 
 	// Resource allocation for batch job
 	// Starting number of replica nodes. Defaults to 1
@@ -76,13 +154,378 @@ type AIBatchArgs struct {
 	BatchSize pulumi.IntInput
 
 	// Compute resource specifications
-	// Type of accelerator (e.g., "NVIDIA_TESLA_T4"). Optional.
-	// Defaults to "ACCELERATOR_TYPE_UNSPECIFIED"
+	// Type of accelerator (e.g., "NVIDIA_TESLA_T4", "TPU_V5_LITEPOD"). Optional.
+	// Defaults to "ACCELERATOR_TYPE_UNSPECIFIED", or to the accelerator of machine types that come with one
+	// (e.g., "NVIDIA_L4" for "g2-standard-8", "TPU_V5_LITEPOD" for "ct5lp-hightpu-4t").
+	// Checked against the machine type and the accelerators offered in Region.
 	AcceleratorType pulumi.StringInput
-	// Number of accelerators. Defaults to 1
+	// Number of accelerators. Defaults to 1, or to the number of GPUs or TPU chips of machine types that come with them.
 	AcceleratorCount pulumi.IntInput
+	// Topology of the TPU slice (e.g., "2x2"). Only for TPU machine types.
+	// Defaults to the topology of the machine type.
+	TpuTopology pulumi.StringInput
+
+	// Capacity provisioning
+	// If true, replicas run on Spot VMs. Spot capacity is cheaper but can be preempted at any time.
+	// Cannot be combined with reservations.
+	Spot bool
+	// Controls whether replicas consume reserved Compute Engine capacity. Optional.
+	// Vertex AI defaults apply when not set.
+	ReservationAffinity *ReservationAffinityArgs
+	// Client reading the jobs launched by previous updates, for the retry policy and the quota preflight.
+	// Defaults to a GoogleBatchJobClient with the credentials of the gcp provider.
+	BatchJobClient BatchJobClient
+
+	// Encryption
+	// Customer-managed KMS key used to encrypt the artifacts bucket, the batch prediction job and
+	// the created predictions dataset (e.g., "projects/my-project/locations/us-central1/keyRings/my-ring/cryptoKeys/my-key").
+	// The key must be in Region. The Cloud Storage, Vertex AI and BigQuery service agents are granted access to it.
+	// Optional. Google-managed keys are used if not set. Custom models are then encrypted with the key too,
+	// and uploaded by the gcp-ai-batch provider, as the model deployment resource does not support an encryption spec.
+	KmsKeyName string
+	// If true, a key ring and key are created in Region and used instead of KmsKeyName.
+	CreateKmsKey bool
+
+	// Several jobs against the same model
+	// Launch one job per spec instead of the single job described above. Jobs share the model, the
+	// artifacts bucket and the model Service Account. Fields not set in a spec default to the fields
+	// above. Cannot be combined with InputBigQueryURI, InputURIs, RetryPolicy or VersionedRunPrefixes.
+	Jobs []BatchJobArgs
+	// Run the same inputs through several models, garden or custom, in parallel jobs. Optional.
+	// Predictions of each model are written under "<OutputDataPath>/<name>/". Replaces ModelName and
+	// ModelDir. Cannot be combined with Jobs, RetryPolicy, VersionedRunPrefixes or GenerateExplanation.
+	CompareModels []ComparedModelArgs
 
 	// Additional configuration
 	// Additional labels to apply to resources
 	Labels map[string]string
 }
+
+// BatchJobArgs configures one of several batch prediction jobs scoring a dataset with the model of the component.
+type BatchJobArgs struct {
+	// Name of the job, unique within the component (e.g., "reviews"). Used in resource names
+	// and bucket prefixes. Up to 20 lowercase letters, digits or hyphens, starting with a letter.
+	Name string
+	// Display name of the job. Defaults to the component job display name + "-" + Name.
+	JobDisplayName pulumi.StringInput
+
+	// Path to the local directory with the input data of the job. Uploaded under "inputs/<name>".
+	// One of InputDataPath or InputURIs is required.
+	InputDataPath string
+	// Name of the input data file. Defaults to the component InputFileName.
+	InputFileName string
+	// Format of the input data. Defaults to the component InputFormat.
+	InputFormat string
+	// Existing GCS URIs or globs to read the instances from. The model Service Account, or the
+	// Vertex AI service agent for models from the garden, is granted read access to the source buckets.
+	InputURIs []string
+
+	// Path to the directory within the bucket where the predictions are stored.
+	// Defaults to "<component OutputDataPath>/<name>/". Ignored when OutputBigQuery is set,
+	// each job writes a table of its own in the predictions dataset.
+	OutputDataPath pulumi.StringInput
+	// Format of the predictions. Defaults to the component OutputFormat.
+	OutputFormat pulumi.StringInput
+
+	// Machine type of the replicas. Defaults to the component MachineType, along with its accelerators.
+	MachineType pulumi.StringInput
+	// Type of accelerator. Defaults to "ACCELERATOR_TYPE_UNSPECIFIED", or to the TPU type of TPU machine types.
+	// Only used when MachineType is set.
+	AcceleratorType pulumi.StringInput
+	// Number of accelerators. Defaults to 1, or to the number of TPU chips of TPU machine types.
+	// Only used when MachineType is set.
+	AcceleratorCount pulumi.IntInput
+	// Starting number of replica nodes. Defaults to the component StartingReplicaCount.
+	StartingReplicaCount pulumi.IntInput
+	// Maximum number of replica nodes. Defaults to the component MaxReplicaCount.
+	MaxReplicaCount pulumi.IntInput
+	// Number of instances processed per batch. Defaults to the component BatchSize.
+	BatchSize pulumi.IntInput
+
+	// Labels of the job, merged with the component Labels.
+	Labels map[string]string
+}
+
+// ShardingArgs configures how the local input files are split across parallel jobs.
+// Records are never split: a record is a line of a JSONL file, or a row of a CSV file without
+// line breaks in quoted values. CSV headers are repeated at the top of every shard.
+type ShardingArgs struct {
+	// Number of shards, and of jobs. At least 2. Capped to the number of records.
+	Count int
+	// Balance the shards by "lines" or by "bytes". Defaults to "lines".
+	By string
+}
+
+// BigQueryOutputArgs configures the BigQuery dataset where the predictions table is written.
+type BigQueryOutputArgs struct {
+	// ID of an existing dataset to write the predictions to (e.g., "my_dataset").
+	// If not set, a dataset is created for the component.
+	DatasetID string
+	// Project of the dataset. Defaults to the component Project.
+	Project string
+	// Location of the created dataset. Defaults to the component Region.
+	Location string
+}
+
+// InstanceConfigArgs configures how the batch input is converted into prediction instances.
+// See: https://cloud.google.com/vertex-ai/docs/reference/rest/v1/projects.locations.batchPredictionJobs#instanceconfig
+type InstanceConfigArgs struct {
+	// Format of the instance sent to the model: "object" or "array".
+	// Use "object" to send CSV or BigQuery rows as JSON objects keyed by column name.
+	InstanceType string
+	// Name of the field to carry over to the output as the key of each prediction (e.g., a row ID).
+	// The key field is not sent to the model.
+	KeyField string
+	// Fields to send to the model. When InstanceType is "array", this also sets the order of the values.
+	// Cannot be combined with ExcludedFields.
+	IncludedFields []string
+	// Fields to drop before the instance reaches the model (e.g., PII columns).
+	// Cannot be combined with IncludedFields.
+	ExcludedFields []string
+}
+
+// ModelParametersArgs configures the parameters sent to the model along with every batch of instances.
+// Typed parameters cover the common sampling knobs of garden LLMs.
+type ModelParametersArgs struct {
+	// Sampling temperature. Lower is more deterministic.
+	Temperature *float64
+	// Maximum number of tokens generated per prediction.
+	MaxOutputTokens *int
+	// Nucleus sampling probability mass.
+	TopP *float64
+	// Number of highest probability tokens to sample from.
+	TopK *int
+	// Sequences that stop the generation when produced.
+	StopSequences []string
+	// Free-form parameters, typically for custom models (e.g., {"batch_size": 8}).
+	// Merged with the typed parameters above; keys cannot overlap.
+	Custom map[string]interface{}
+}
+
+// ComparedModelArgs is a model scoring the same inputs as the other models of a comparison.
+type ComparedModelArgs struct {
+	// Name of the model in the comparison (e.g., "gemma", "fine-tuned"). Used in resource names,
+	// output prefixes and the job getter. Up to 20 lowercase letters, digits or hyphens, starting with a letter.
+	Name string
+	// Name of the model from the garden. One of ModelName or ModelDir is required.
+	ModelName string
+	// Path to the artifacts of a custom model, uploaded under "<ModelBucketBasePath>/<name>".
+	// One of ModelName or ModelDir is required.
+	ModelDir string
+	// Container image URL serving the custom model. Defaults to the component ModelImageURL.
+	ModelImageURL pulumi.StringInput
+	// Container spec of the custom model. Each setting defaults to the one of the component ContainerSpec.
+	ContainerSpec *ContainerSpecArgs
+	// Paths to the YAML schemas within ModelDir. Default to the component schema paths.
+	ModelPredictionInputSchemaPath    string
+	ModelPredictionOutputSchemaPath   string
+	ModelPredictionBehaviorSchemaPath string
+
+	// Machine type of the replicas. Defaults to the component MachineType, along with its accelerators.
+	MachineType pulumi.StringInput
+	// Type of accelerator. Only used when MachineType is set. See BatchJobArgs.
+	AcceleratorType pulumi.StringInput
+	// Number of accelerators. Only used when MachineType is set. See BatchJobArgs.
+	AcceleratorCount pulumi.IntInput
+}
+
+// ContainerSpecArgs configures the container serving a custom model, e.g., to serve another predictor of the
+// same image without a rebuild. Settings left empty are taken from the image.
+// Models with more than routes are uploaded by the gcp-ai-batch provider, as the model deployment resource
+// only supports the routes. See ResourceProviderName.
+// See: https://cloud.google.com/vertex-ai/docs/predictions/custom-container-requirements
+type ContainerSpecArgs struct {
+	// HTTP path the prediction requests are sent to. Defaults to "/predict".
+	PredictRoute pulumi.StringInput
+	// HTTP path of the health checks. Defaults to "/health".
+	HealthRoute pulumi.StringInput
+	// Environment variables of the container (e.g., {"HF_HOME": "/tmp/huggingface"}).
+	Env map[string]string
+	// Ports the container listens on. Vertex AI sends the requests to the first one. Defaults to 8080.
+	Ports []int
+	// Entrypoint of the container, replacing the ENTRYPOINT of the image.
+	Command []string
+	// Arguments of the entrypoint, replacing the CMD of the image.
+	Args []string
+	// Size of the shared memory volume mounted at /dev/shm, in MB.
+	SharedMemorySizeMB int
+	// Probe checking that the container started, before the health checks begin.
+	StartupProbe *ProbeArgs
+}
+
+// ProbeArgs checks a container with a command or an HTTP GET request. Exactly one of ExecCommand or
+// HTTPGetPath is required.
+type ProbeArgs struct {
+	// Command run in the container. The check succeeds when it exits with 0.
+	ExecCommand []string
+	// HTTP path requested on the container. The check succeeds on a 2xx or 3xx response.
+	HTTPGetPath string
+	// Port of HTTPGetPath. Defaults to the first port of the container.
+	HTTPGetPort int
+	// Seconds between checks. Defaults to 10.
+	PeriodSeconds int
+	// Seconds before a check times out. Defaults to 1.
+	TimeoutSeconds int
+	// Consecutive failed checks before the container is restarted. Defaults to 3.
+	FailureThreshold int
+	// Seconds before the first check. Defaults to 0.
+	InitialDelaySeconds int
+}
+
+// ExplanationSpecArgs configures how feature attributions are computed for each prediction.
+// See: https://cloud.google.com/vertex-ai/docs/explainable-ai/overview
+type ExplanationSpecArgs struct {
+	// Attribution method: "sampled-shapley", "integrated-gradients" or "xrai".
+	Method string
+	// Number of feature permutations for "sampled-shapley", between 1 and 50. Defaults to 10.
+	PathCount int
+	// Number of steps approximating the path integral for "integrated-gradients" and "xrai",
+	// between 1 and 100. Defaults to 50.
+	StepCount int
+	// Number of top attributed outputs to explain. Optional, all outputs are explained if not set.
+	TopK int
+	// Describes the model inputs and outputs to attribute. Optional for container models.
+	Metadata *ExplanationMetadataArgs
+}
+
+// ExplanationMetadataArgs describes the inputs and outputs of the model for the explanations.
+type ExplanationMetadataArgs struct {
+	// Model inputs to attribute, keyed by feature name.
+	Inputs map[string]ExplanationInputArgs
+	// Model output to explain, keyed by output name. Only one output is supported.
+	Outputs map[string]ExplanationOutputArgs
+	// Path to the YAML file within ModelDir with the schema of the attributions. Optional.
+	FeatureAttributionsSchemaPath string
+	// Name of the source to generate embeddings for example based explanations. Optional.
+	LatentSpaceSource string
+}
+
+// ExplanationInputArgs describes a model input feature.
+type ExplanationInputArgs struct {
+	// Name of the input tensor. Required for TensorFlow models.
+	InputTensorName string
+	// Encoding of the input tensor (e.g., "IDENTITY", "BAG_OF_FEATURES"). Defaults to "IDENTITY".
+	Encoding string
+	// Modality of the feature (e.g., "numeric", "image", "categorical", "text").
+	Modality string
+}
+
+// ExplanationOutputArgs describes a model output.
+type ExplanationOutputArgs struct {
+	// Name of the output tensor. Required for TensorFlow models.
+	OutputTensorName string
+	// Field of the prediction that maps the output index to a display name. Optional.
+	DisplayNameMappingKey string
+}
+
+// WaitForCompletionArgs configures how the deployment waits for the batch prediction job to finish.
+type WaitForCompletionArgs struct {
+	// Maximum time to wait for the job. Defaults to 24 hours.
+	Timeout time.Duration
+	// Time between job state checks. Defaults to 1 minute.
+	PollInterval time.Duration
+	// If true, the deployment fails when the job ends in any state other than succeeded.
+	// Useful to gate CI pipelines on the job result.
+	FailOnUnsuccessfulJob bool
+}
+
+// ScheduleArgs configures recurring batch prediction runs. Each run reads and writes prefixes templated
+// with the execution date: "{date}" is replaced with the date as YYYY-MM-DD in the schedule time zone.
+type ScheduleArgs struct {
+	// Cron expression of the runs (e.g., "0 2 * * *" for every night at 2am).
+	Cron string
+	// Time zone of the cron expression and of the execution date (e.g., "Europe/Madrid"). Defaults to "Etc/UTC".
+	TimeZone string
+	// GCS URIs or globs read by each run (e.g., "gs://my-data/reviews/{date}/*.jsonl").
+	// Defaults to "gs://<artifacts bucket>/inputs/{date}/<InputFileName>".
+	// The model Service Account, or the Vertex AI service agent for models from the garden, is granted
+	// read access to the source buckets.
+	InputURIs []string
+	// GCS prefix the predictions of each run are written to (e.g., "gs://my-data/scores/{date}/").
+	// Defaults to "gs://<artifacts bucket>/<OutputDataPath>/{date}/". Ignored when OutputBigQuery is set,
+	// each run writes a table of its own in the predictions dataset.
+	OutputURIPrefix string
+}
+
+// EventTriggerArgs configures the jobs launched when input data lands in the artifacts bucket.
+// Predictions of each file or manifest are written under "<OutputDataPath>/events/<path within InputPrefix>/".
+type EventTriggerArgs struct {
+	// Prefix of the artifacts bucket watched for new input files. Defaults to "inputs/events/".
+	InputPrefix string
+	// Suffix of the manifest files. Defaults to ".manifest.json". A manifest is a JSON object listing
+	// the input URIs of a single job, e.g., {"uris": ["gs://my-data/reviews/part-1.jsonl"]}.
+	// Upload manifests with the "application/json" content type, and once their inputs are in place.
+	ManifestSuffix string
+}
+
+// NotificationsArgs configures the notifications of the component. A log sink publishes the log entries
+// of the job state changes and of the prediction writes to a topic, exported as an output.
+// Prediction writes are only logged when the data write audit logs of Cloud Storage are enabled.
+type NotificationsArgs struct {
+	// HTTPS endpoints every notification is pushed to, one subscription each (e.g., an incident tool webhook).
+	WebhookURLs []string
+	// If true, webhooks receive the log entry as the request body instead of the Pub/Sub push envelope.
+	RawPayload bool
+}
+
+// CostEstimateArgs configures the cost estimate of the job. See EstimateCost.
+type CostEstimateArgs struct {
+	// Lowest number of instances a replica is assumed to score per second. Required.
+	MinInstancesPerSecond float64
+	// Highest number of instances a replica is assumed to score per second. Defaults to MinInstancesPerSecond.
+	MaxInstancesPerSecond float64
+	// Prices overriding the prices of DefaultPriceTable, e.g., for another region or negotiated prices.
+	Prices *PriceTable
+	// Fail the deployment when the high end of the estimate exceeds this amount in USD. 0 disables the ceiling.
+	BudgetUSD float64
+}
+
+// QuotaPreflightArgs configures the quota check of the deployment. The accelerators needed are
+// MaxReplicaCount × AcceleratorCount of every job launched by the deployment, and each job counts
+// against the concurrent jobs quota. Jobs launched by a previous update of the run, found by their
+// launch ID label, already count in the usage and are left out. Values only known at deployment time
+// are not checked.
+type QuotaPreflightArgs struct {
+	// Client reading the quotas. Defaults to a GoogleQuotaClient with the credentials of the gcp provider.
+	Client QuotaClient
+	// If true, insufficient quota is logged as a warning instead of failing the deployment.
+	WarnOnly bool
+}
+
+// RetentionPolicyArgs configures the cleanup of the jobs of the component. A job is retained when it is one
+// of the last KeepLast jobs, or when it is newer than MaxAgeDays. Other finished jobs are deleted along with
+// their predictions in the artifacts bucket. Predictions written elsewhere, e.g., to BigQuery, are kept.
+// Only jobs carrying the "ai-batch-component" label of the component are considered, including the jobs
+// launched by schedules and event triggers, and the jobs of the current deployment are never deleted.
+type RetentionPolicyArgs struct {
+	// Number of most recent jobs to retain. 0 retains jobs by age only.
+	KeepLast int
+	// Retain jobs created in the last MaxAgeDays days. 0 retains jobs by count only.
+	MaxAgeDays int
+	// Cron expression of the cleanup runs. Defaults to "0 4 * * *", every day at 4am.
+	Cron string
+	// Time zone of the cron expression. Defaults to "Etc/UTC".
+	TimeZone string
+}
+
+// RetryPolicyArgs configures when a batch prediction job is resubmitted.
+// The attempt history is kept in the artifacts bucket between updates.
+type RetryPolicyArgs struct {
+	// Maximum number of jobs launched, including the first one. Defaults to 3.
+	MaxAttempts int
+	// Final states of the previous attempt that trigger a new one.
+	// Any of "JOB_STATE_FAILED", "JOB_STATE_CANCELLED" or "JOB_STATE_EXPIRED".
+	// Defaults to "JOB_STATE_FAILED" and "JOB_STATE_CANCELLED".
+	RetryableStates []string
+}
+
+// ReservationAffinityArgs configures which Compute Engine reservations the batch replicas can consume.
+// See: https://cloud.google.com/vertex-ai/docs/predictions/use-reservations
+type ReservationAffinityArgs struct {
+	// One of "NO_RESERVATION", "ANY_RESERVATION" or "SPECIFIC_RESERVATION".
+	Type string
+	// Reservation to consume when Type is "SPECIFIC_RESERVATION"
+	// (e.g., "projects/my-project/zones/us-central1-a/reservations/my-l4-reservation").
+	// The reservation must be shared with Vertex AI and match the machine spec of the job.
+	ReservationName string
+}
@@ -0,0 +1,68 @@
+package gcp
+
+import (
+	"fmt"
+
+	v1 "github.com/pulumi/pulumi-google-native/sdk/go/google/aiplatform/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+const (
+	instanceTypeObject = "object"
+	instanceTypeArray  = "array"
+)
+
+// validateInstanceConfig checks the instance config for combinations rejected by Vertex AI.
+func validateInstanceConfig(config *InstanceConfigArgs) error {
+	switch config.InstanceType {
+	case "", instanceTypeObject, instanceTypeArray:
+	default:
+		return fmt.Errorf("instance type must be %q or %q, got %q", instanceTypeObject, instanceTypeArray, config.InstanceType)
+	}
+
+	if len(config.IncludedFields) > 0 && len(config.ExcludedFields) > 0 {
+		return fmt.Errorf("only one of included fields or excluded fields can be set")
+	}
+
+	return nil
+}
+
+// toInstanceConfigArgs maps the instance config to the batch prediction job config.
+func toInstanceConfigArgs(config *InstanceConfigArgs) *v1.GoogleCloudAiplatformV1BatchPredictionJobInstanceConfigArgs {
+	instanceConfig := &v1.GoogleCloudAiplatformV1BatchPredictionJobInstanceConfigArgs{}
+
+	if config.InstanceType != "" {
+		instanceConfig.InstanceType = pulumi.String(config.InstanceType)
+	}
+	if config.KeyField != "" {
+		instanceConfig.KeyField = pulumi.String(config.KeyField)
+	}
+	if len(config.IncludedFields) > 0 {
+		instanceConfig.IncludedFields = pulumi.ToStringArray(config.IncludedFields)
+	}
+	if len(config.ExcludedFields) > 0 {
+		instanceConfig.ExcludedFields = pulumi.ToStringArray(config.ExcludedFields)
+	}
+
+	return instanceConfig
+}
+
+// toInstanceConfigRequest maps the instance config to the body of a Vertex AI API request.
+func toInstanceConfigRequest(config *InstanceConfigArgs) pulumi.Map {
+	instanceConfig := pulumi.Map{}
+
+	if config.InstanceType != "" {
+		instanceConfig["instanceType"] = pulumi.String(config.InstanceType)
+	}
+	if config.KeyField != "" {
+		instanceConfig["keyField"] = pulumi.String(config.KeyField)
+	}
+	if len(config.IncludedFields) > 0 {
+		instanceConfig["includedFields"] = pulumi.ToStringArray(config.IncludedFields)
+	}
+	if len(config.ExcludedFields) > 0 {
+		instanceConfig["excludedFields"] = pulumi.ToStringArray(config.ExcludedFields)
+	}
+
+	return instanceConfig
+}
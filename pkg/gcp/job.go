@@ -2,92 +2,179 @@ package gcp
 
 import (
 	"fmt"
-	"time"
+	"maps"
 
-	vertexmodeldeployment "github.com/davidmontoyago/pulumi-gcp-vertex-model-deployment/sdk/go/pulumi-gcp-vertex-model-deployment/resources"
 	v1 "github.com/pulumi/pulumi-google-native/sdk/go/google/aiplatform/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
-// createBatchPredictionJob creates a Vertex AI Batch Prediction Job.
+// batchJobName returns the resource name of the job of the spec, with a new launch whenever the run ID
+// changes, by default on every pulumi up operation, and when the previous attempt of the run is retried.
+func (v *AIBatch) batchJobName(spec *batchJobSpec) string {
+	jobName := v.NewResourceName(spec.jobResourceName("batch-prediction-job"), "", 63)
+	if v.RunID != "" {
+		jobName = fmt.Sprintf("%s-%s", jobName, v.RunID)
+	}
+	if v.retryPolicy != nil {
+		jobName = fmt.Sprintf("%s-attempt-%d", jobName, v.jobAttempt)
+	}
+
+	return jobName
+}
+
+// createBatchPredictionJob creates a Vertex AI Batch Prediction Job from the job spec.
 func (v *AIBatch) createBatchPredictionJob(ctx *pulumi.Context,
-	modelDeployment *vertexmodeldeployment.VertexModelDeployment,
-	inputDataBucketURI pulumi.StringOutput,
+	spec *batchJobSpec,
 	serviceAccountEmail pulumi.StringOutput) (*v1.BatchPredictionJob, error) {
 
 	dependencies := []pulumi.Resource{v.artifactsBucket}
-	var modelName pulumi.StringOutput
+	// the deployed model name or the name of a model from the garden
+	modelName := spec.modelName()
 
-	isCustomModel := modelDeployment != nil
+	isCustomModel := spec.registeredModel != nil
 
 	if isCustomModel {
-		dependencies = append(dependencies, modelDeployment)
-		modelName = modelDeployment.ModelName
-	} else {
-		// if no model deployment, it's a model from the garden
-		modelName = pulumi.String(v.ModelName).ToStringOutput()
+		dependencies = append(dependencies, spec.registeredModel.resource())
 	}
 
 	if v.repoIamMember != nil {
 		// wait for IAM binding to access a private registry
 		dependencies = append(dependencies, v.repoIamMember)
 	}
+	for _, member := range v.bigQueryIamMembers {
+		// wait for IAM bindings to access the BigQuery datasets
+		dependencies = append(dependencies, member)
+	}
+	for _, member := range v.inputBucketMembers {
+		// wait for IAM bindings to read existing input data
+		dependencies = append(dependencies, member)
+	}
+	// wait for IAM bindings to use the customer-managed key
+	dependencies = append(dependencies, v.kmsKeyDependencies()...)
 
 	// Construct the input config
 	inputConfig := &v1.GoogleCloudAiplatformV1BatchPredictionJobInputConfigArgs{
-		InstancesFormat: v.InputFormat,
-		GcsSource: &v1.GoogleCloudAiplatformV1GcsSourceArgs{
-			Uris: pulumi.StringArray{
+		InstancesFormat: spec.inputFormat,
+	}
+	var inputURIs pulumi.StringArray
+	if spec.inputBigQueryURI != "" {
+		inputConfig.BigquerySource = &v1.GoogleCloudAiplatformV1BigQuerySourceArgs{
+			InputUri: pulumi.String(spec.inputBigQueryURI),
+		}
+		inputURIs = pulumi.StringArray{pulumi.String(spec.inputBigQueryURI)}
+	} else {
+		if len(spec.inputURIs) > 0 {
+			// URIs to data produced outside of this component
+			inputURIs = pulumi.ToStringArray(spec.inputURIs)
+		} else {
+			inputURIs = pulumi.StringArray{
 				// URI to the data just uploaded by this component
-				pulumi.Sprintf("%s/%s", inputDataBucketURI, v.InputFileName),
-			},
-		},
+				pulumi.Sprintf("%s/%s", spec.inputDataBucketURI, spec.inputFileName),
+			}
+		}
+		inputConfig.GcsSource = &v1.GoogleCloudAiplatformV1GcsSourceArgs{
+			Uris: inputURIs,
+		}
 	}
 
 	// Construct the output config
 	outputConfig := &v1.GoogleCloudAiplatformV1BatchPredictionJobOutputConfigArgs{
-		PredictionsFormat: v.OutputFormat,
-		GcsDestination: &v1.GoogleCloudAiplatformV1GcsDestinationArgs{
-			OutputUriPrefix: pulumi.Sprintf("gs://%s/%s", v.artifactsBucket.Name, v.OutputDataPath),
-		},
+		PredictionsFormat: spec.outputFormat,
+	}
+	var outputURI pulumi.StringOutput
+	if v.OutputBigQuery != nil {
+		// a new predictions table is created in the dataset for every job
+		outputURI = v.outputBigQueryURI
+		outputConfig.BigqueryDestination = &v1.GoogleCloudAiplatformV1BigQueryDestinationArgs{
+			OutputUri: outputURI,
+		}
+	} else {
+		outputURI = pulumi.Sprintf("gs://%s/%s", v.artifactsBucket.Name, spec.outputDataPath)
+		outputConfig.GcsDestination = &v1.GoogleCloudAiplatformV1GcsDestinationArgs{
+			OutputUriPrefix: outputURI,
+		}
+	}
+
+	// Exact locations of this run
+	spec.runInputURIs = inputURIs.ToStringArrayOutput()
+	spec.runOutputURI = outputURI
+
+	jobName := v.batchJobName(spec)
+	// the launch ID finds the job of the run across updates
+	jobLabels := maps.Clone(spec.labels)
+	if jobLabels == nil {
+		jobLabels = map[string]string{}
+	}
+	jobLabels[launchIDLabel] = launchID(ctx, jobName)
+
+	if v.managedByProvider(spec) {
+		inputSource := pulumi.Map{"gcsSource": pulumi.Map{"uris": inputURIs}}
+		if spec.inputBigQueryURI != "" {
+			inputSource = pulumi.Map{"bigquerySource": pulumi.Map{"inputUri": pulumi.String(spec.inputBigQueryURI)}}
+		}
+		request := v.batchJobRequest(spec, serviceAccountEmail, spec.displayName, inputSource, outputURI)
+		request["labels"] = pulumi.ToStringMap(jobLabels)
+
+		return v.createManagedBatchPredictionJob(ctx, jobName, request, dependencies)
 	}
 
 	// Construct dedicated resources for the job
 	dedicatedResources := &v1.GoogleCloudAiplatformV1BatchDedicatedResourcesArgs{
 		MachineSpec: &v1.GoogleCloudAiplatformV1MachineSpecArgs{
-			MachineType:      v.MachineType,
-			AcceleratorCount: v.AcceleratorCount,
-			AcceleratorType: v.AcceleratorType.ApplyT(func(accelType string) v1.GoogleCloudAiplatformV1MachineSpecAcceleratorType {
+			MachineType:      spec.machineType,
+			AcceleratorCount: spec.acceleratorCount,
+			AcceleratorType: spec.acceleratorType.ApplyT(func(accelType string) v1.GoogleCloudAiplatformV1MachineSpecAcceleratorType {
 				return v1.GoogleCloudAiplatformV1MachineSpecAcceleratorType(accelType)
 			}).(v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeOutput),
+			TpuTopology: spec.tpuTopology.ApplyT(func(topology string) *string {
+				if topology == "" {
+					// not a TPU machine
+					return nil
+				}
+
+				return &topology
+			}).(pulumi.StringPtrOutput),
 		},
-		StartingReplicaCount: v.StartingReplicaCount,
-		MaxReplicaCount:      v.MaxReplicaCount,
+		StartingReplicaCount: spec.startingReplicaCount,
+		MaxReplicaCount:      spec.maxReplicaCount,
 	}
 
 	batchJobArgs := &v1.BatchPredictionJobArgs{
 		Project:            pulumi.String(v.Project),
 		Location:           pulumi.String(v.Region),
-		DisplayName:        v.JobDisplayName,
+		DisplayName:        spec.displayName,
 		Model:              modelName, // Use the deployed model name or the name of a model from the garden
 		InputConfig:        inputConfig,
 		OutputConfig:       outputConfig,
 		DedicatedResources: dedicatedResources,
 		ManualBatchTuningParameters: &v1.GoogleCloudAiplatformV1ManualBatchTuningParametersArgs{
-			BatchSize: v.BatchSize,
+			BatchSize: spec.batchSize,
 		},
-		Labels: pulumi.ToStringMap(v.Labels),
+		Labels: pulumi.ToStringMap(jobLabels),
 	}
 	if isCustomModel {
 		batchJobArgs.ServiceAccount = serviceAccountEmail
 	}
+	if v.ModelParameters != nil {
+		batchJobArgs.ModelParameters = pulumi.ToMap(v.ModelParameters)
+	}
+	if v.InstanceConfig != nil {
+		batchJobArgs.InstanceConfig = toInstanceConfigArgs(v.InstanceConfig)
+	}
 
-	// every pulumi up operation is a new launch
-	jobName := fmt.Sprintf("%s-%d", v.NewResourceName("batch-prediction-job", "", 63), time.Now().UnixMilli())
+	if v.customerManagedEncryption {
+		batchJobArgs.EncryptionSpec = &v1.GoogleCloudAiplatformV1EncryptionSpecArgs{
+			KmsKeyName: v.KmsKeyName,
+		}
+	}
+
+	if v.GenerateExplanation {
+		// explanation files are written next to the predictions
+		batchJobArgs.GenerateExplanation = pulumi.Bool(true)
+		batchJobArgs.ExplanationSpec = toExplanationSpecArgs(v.ExplanationSpec, v.modelArtifactsURI)
+	}
 
-	batchPredictionJob, err := v1.NewBatchPredictionJob(ctx,
-		jobName,
-		batchJobArgs,
+	batchPredictionJob, err := v1.NewBatchPredictionJob(ctx, jobName, batchJobArgs,
 		pulumi.Parent(v),
 		pulumi.DependsOn(dependencies),
 		pulumi.RetainOnDelete(v.retainJobOnDelete),
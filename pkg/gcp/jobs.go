@@ -0,0 +1,295 @@
+package gcp
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+
+	v1 "github.com/pulumi/pulumi-google-native/sdk/go/google/aiplatform/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// batchJobNamePattern keeps job names short enough to be used in resource names along with the run ID.
+var batchJobNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,19}$`)
+
+// batchJobSpec is the resolved configuration of a batch prediction job of the component.
+type batchJobSpec struct {
+	// Name from BatchJobArgs or ComparedModelArgs. Empty for the single job described by the component fields.
+	name string
+
+	// Model from the garden, or the custom model registered by the component
+	gardenModelName string
+	customModel     *customModel
+	registeredModel *registeredModel
+
+	displayName        pulumi.StringOutput
+	inputFormat        pulumi.StringOutput
+	inputFileName      pulumi.StringOutput
+	inputBigQueryURI   string
+	inputURIs          []string
+	inputDataLocalDir  string
+	inputDataTargetDir string
+	outputDataPath     pulumi.StringOutput
+	outputFormat       pulumi.StringOutput
+
+	machineType          pulumi.StringOutput
+	acceleratorType      pulumi.StringOutput
+	acceleratorCount     pulumi.IntOutput
+	tpuTopology          pulumi.StringOutput
+	startingReplicaCount pulumi.IntOutput
+	maxReplicaCount      pulumi.IntOutput
+	batchSize            pulumi.IntOutput
+	labels               map[string]string
+
+	// The accelerator type is missing from the google-native enum
+	unmodeledAcceleratorType bool
+
+	// Set once the inputs are uploaded and the job is created
+	inputDataBucketURI pulumi.StringOutput
+	job                *v1.BatchPredictionJob
+	waiter             *BatchJobWaiter
+	state              pulumi.StringOutput
+	runInputURIs       pulumi.StringArrayOutput
+	runOutputURI       pulumi.StringOutput
+}
+
+// validateBatchJobs checks the job specs and the component fields they cannot be combined with.
+// TPU machine specs of the jobs are resolved in place.
+func validateBatchJobs(args *AIBatchArgs) error {
+	switch {
+	case args.InputBigQueryURI != "":
+		return fmt.Errorf("input BigQuery URI cannot be combined with jobs")
+	case len(args.InputURIs) > 0:
+		return fmt.Errorf("input URIs cannot be combined with jobs, set them in each job")
+	case args.RetryPolicy != nil:
+		return fmt.Errorf("retry policy cannot be combined with jobs")
+	case args.VersionedRunPrefixes:
+		return fmt.Errorf("versioned run prefixes cannot be combined with jobs")
+	}
+
+	jobNames := map[string]bool{}
+	for jobIndex := range args.Jobs {
+		job := &args.Jobs[jobIndex]
+
+		if !batchJobNamePattern.MatchString(job.Name) {
+			return fmt.Errorf("job name %q must be up to 20 lowercase letters, digits or hyphens, starting with a letter", job.Name)
+		}
+		if jobNames[job.Name] {
+			return fmt.Errorf("job name %q is used more than once", job.Name)
+		}
+		jobNames[job.Name] = true
+
+		if (job.InputDataPath == "") == (len(job.InputURIs) == 0) {
+			return fmt.Errorf("job %s: exactly one of input data path or input URIs is required", job.Name)
+		}
+		if len(job.InputURIs) > 0 {
+			if _, err := inputBucketNames(job.InputURIs); err != nil {
+				return fmt.Errorf("job %s: invalid input URIs: %w", job.Name, err)
+			}
+		}
+		if job.InputFormat == bigQueryFormat {
+			return fmt.Errorf("job %s: input format %s is not supported in jobs", job.Name, bigQueryFormat)
+		}
+
+		acceleratorType, acceleratorCount, err := resolveJobMachineSpec(args.Region, job.MachineType, job.AcceleratorType, job.AcceleratorCount)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		job.AcceleratorType = acceleratorType
+		job.AcceleratorCount = acceleratorCount
+	}
+
+	return nil
+}
+
+// resolveJobMachineSpec checks the machine spec overridden by a job in the region and fills in the accelerators of
+// TPU machine types and of machine types that come with accelerators.
+func resolveJobMachineSpec(region string, machineType, acceleratorType pulumi.StringInput, acceleratorCount pulumi.IntInput) (pulumi.StringInput, pulumi.IntInput, error) {
+	if machineType == nil {
+		if acceleratorType != nil || acceleratorCount != nil {
+			return nil, nil, fmt.Errorf("machine type is required to set accelerators")
+		}
+
+		return nil, nil, nil
+	}
+
+	machineSpec := &AIBatchArgs{
+		Region:           region,
+		MachineType:      machineType,
+		AcceleratorType:  acceleratorType,
+		AcceleratorCount: acceleratorCount,
+	}
+	if err := resolveTPUMachineSpec(machineSpec); err != nil {
+		return nil, nil, fmt.Errorf("invalid TPU machine spec: %w", err)
+	}
+	if err := resolveMachineCatalogSpec(machineSpec); err != nil {
+		return nil, nil, fmt.Errorf("invalid machine spec: %w", err)
+	}
+
+	return machineSpec.AcceleratorType, machineSpec.AcceleratorCount, nil
+}
+
+// defaultBatchJobSpec returns the spec of the single job described by the component fields.
+func (v *AIBatch) defaultBatchJobSpec() *batchJobSpec {
+	return &batchJobSpec{
+		gardenModelName: v.ModelName,
+
+		displayName:        v.JobDisplayName,
+		inputFormat:        v.InputFormat,
+		inputFileName:      v.InputFileName,
+		inputBigQueryURI:   v.InputBigQueryURI,
+		inputURIs:          v.InputURIs,
+		inputDataLocalDir:  v.inputDataLocalDir,
+		inputDataTargetDir: v.inputDataTargetDir,
+		outputDataPath:     v.OutputDataPath,
+		outputFormat:       v.OutputFormat,
+
+		machineType:          v.MachineType,
+		acceleratorType:      v.AcceleratorType,
+		acceleratorCount:     v.AcceleratorCount,
+		tpuTopology:          v.TpuTopology,
+		startingReplicaCount: v.StartingReplicaCount,
+		maxReplicaCount:      v.MaxReplicaCount,
+		batchSize:            v.BatchSize,
+		labels:               v.Labels,
+
+		unmodeledAcceleratorType: v.unmodeledAcceleratorType,
+	}
+}
+
+// batchJobSpecs returns the specs of the jobs to launch, defaulting unset fields to the component fields.
+func (v *AIBatch) batchJobSpecs(jobs []BatchJobArgs, comparedModels []ComparedModelArgs) []*batchJobSpec {
+	if len(v.inputShards) > 0 {
+		return v.shardJobSpecs()
+	}
+	if len(comparedModels) > 0 {
+		return v.comparisonJobSpecs(comparedModels)
+	}
+	if len(jobs) == 0 {
+		return []*batchJobSpec{v.defaultBatchJobSpec()}
+	}
+
+	specs := make([]*batchJobSpec, len(jobs))
+	for jobIndex, job := range jobs {
+		spec := v.defaultBatchJobSpec()
+		spec.name = job.Name
+		spec.displayName = pulumi.Sprintf("%s-%s", v.JobDisplayName, job.Name)
+		if job.JobDisplayName != nil {
+			spec.displayName = job.JobDisplayName.ToStringOutput()
+		}
+
+		// Each job reads its own inputs
+		spec.inputURIs = job.InputURIs
+		spec.inputDataLocalDir = job.InputDataPath
+		spec.inputDataTargetDir = path.Join(v.inputDataTargetDir, job.Name)
+		if job.InputFileName != "" {
+			spec.inputFileName = pulumi.String(job.InputFileName).ToStringOutput()
+		}
+		if job.InputFormat != "" {
+			spec.inputFormat = pulumi.String(job.InputFormat).ToStringOutput()
+		}
+
+		// and writes its own predictions
+		spec.outputDataPath = v.OutputDataPath.ApplyT(func(prefix string) string {
+			return subPrefix(prefix, job.Name)
+		}).(pulumi.StringOutput)
+		if job.OutputDataPath != nil {
+			spec.outputDataPath = job.OutputDataPath.ToStringOutput()
+		}
+		if job.OutputFormat != nil && v.OutputBigQuery == nil {
+			spec.outputFormat = job.OutputFormat.ToStringOutput()
+		}
+
+		spec.setMachineSpec(job.MachineType, job.AcceleratorType, job.AcceleratorCount)
+		if job.StartingReplicaCount != nil {
+			spec.startingReplicaCount = job.StartingReplicaCount.ToIntOutput()
+		}
+		if job.MaxReplicaCount != nil {
+			spec.maxReplicaCount = job.MaxReplicaCount.ToIntOutput()
+		}
+		if job.BatchSize != nil {
+			spec.batchSize = job.BatchSize.ToIntOutput()
+		}
+
+		spec.labels = maps.Clone(v.Labels)
+		if spec.labels == nil {
+			spec.labels = map[string]string{}
+		}
+		maps.Copy(spec.labels, job.Labels)
+
+		specs[jobIndex] = spec
+	}
+
+	return specs
+}
+
+// setMachineSpec overrides the machine spec of the component when the machine type is set.
+// Accelerators come with the machine type.
+func (s *batchJobSpec) setMachineSpec(machineType, acceleratorType pulumi.StringInput, acceleratorCount pulumi.IntInput) {
+	if machineType == nil {
+		return
+	}
+
+	s.machineType = machineType.ToStringOutput()
+	s.acceleratorType = setDefaultString(acceleratorType, "ACCELERATOR_TYPE_UNSPECIFIED")
+	s.acceleratorCount = setDefaultInt(acceleratorCount, 1)
+	s.unmodeledAcceleratorType = !isProviderAcceleratorType(acceleratorType)
+	s.tpuTopology = s.machineType.ApplyT(func(machineType string) string {
+		return tpuMachineSpecs[machineType].Topology
+	}).(pulumi.StringOutput)
+}
+
+// batchJobInputURIs returns the existing input URIs read by the jobs.
+func batchJobInputURIs(specs []*batchJobSpec) []string {
+	var inputURIs []string
+	for _, spec := range specs {
+		inputURIs = append(inputURIs, spec.inputURIs...)
+	}
+
+	return inputURIs
+}
+
+// jobSettings collects the settings of the job specs that change the predictions.
+func jobSettings(jobs []BatchJobArgs) []map[string]interface{} {
+	settings := make([]map[string]interface{}, len(jobs))
+	for jobIndex, job := range jobs {
+		settings[jobIndex] = map[string]interface{}{
+			"name":             job.Name,
+			"inputFileName":    job.InputFileName,
+			"inputFormat":      job.InputFormat,
+			"inputURIs":        job.InputURIs,
+			"outputDataPath":   plainStringSetting(job.OutputDataPath),
+			"outputFormat":     plainStringSetting(job.OutputFormat),
+			"machineType":      plainStringSetting(job.MachineType),
+			"acceleratorType":  plainStringSetting(job.AcceleratorType),
+			"acceleratorCount": plainIntSetting(job.AcceleratorCount),
+			"batchSize":        plainIntSetting(job.BatchSize),
+		}
+	}
+
+	return settings
+}
+
+// modelName returns the model run by the job: the registered custom model, or the model from the garden.
+func (s *batchJobSpec) modelName() pulumi.StringOutput {
+	if s.registeredModel != nil {
+		return s.registeredModel.modelName()
+	}
+
+	return pulumi.String(s.gardenModelName).ToStringOutput()
+}
+
+// jobResourceName returns the name of a resource of the job, e.g., "batch-prediction-job-reviews".
+func (s *batchJobSpec) jobResourceName(name string) string {
+	return suffixedResourceName(name, s.name)
+}
+
+// suffixedResourceName appends the name of a job or compared model to a resource name, if any.
+func suffixedResourceName(name, suffix string) string {
+	if suffix == "" {
+		return name
+	}
+
+	return fmt.Sprintf("%s-%s", name, suffix)
+}
@@ -0,0 +1,93 @@
+package gcp
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	v1 "github.com/pulumi/pulumi-google-native/sdk/go/google/aiplatform/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Reservation affinity types for the batch replicas.
+const (
+	ReservationAffinityNone     = "NO_RESERVATION"
+	ReservationAffinityAny      = "ANY_RESERVATION"
+	ReservationAffinitySpecific = "SPECIFIC_RESERVATION"
+)
+
+// reservationNameKey is the label key Vertex AI uses to match a specific reservation.
+const reservationNameKey = "compute.googleapis.com/reservation-name"
+
+// reservationNamePattern matches "projects/{project}/zones/{zone}/reservations/{reservation}".
+var reservationNamePattern = regexp.MustCompile(`^projects/[^/]+/zones/[^/]+/reservations/[^/]+$`)
+
+// providerAcceleratorTypes lists the accelerator types of the google-native machine spec enum. Newer types,
+// e.g., TPU_V5_LITEPOD, TPU_V6E or NVIDIA_H100_80GB, are only accepted by the Vertex AI API.
+var providerAcceleratorTypes = []v1.GoogleCloudAiplatformV1MachineSpecAcceleratorType{
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeAcceleratorTypeUnspecified,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeNvidiaTeslaK80,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeNvidiaTeslaP100,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeNvidiaTeslaV100,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeNvidiaTeslaP4,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeNvidiaTeslaT4,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeNvidiaTeslaA100,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeNvidiaA10080gb,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeNvidiaL4,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeTpuV2,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeTpuV3,
+	v1.GoogleCloudAiplatformV1MachineSpecAcceleratorTypeTpuV4Pod,
+}
+
+// isProviderAcceleratorType returns false if the accelerator type is missing from the google-native enum.
+// Unset types default to ACCELERATOR_TYPE_UNSPECIFIED, and values only known at deployment time are assumed
+// to be in the enum.
+func isProviderAcceleratorType(acceleratorType pulumi.StringInput) bool {
+	value, known := plainString(acceleratorType)
+	if acceleratorType == nil || !known {
+		return true
+	}
+
+	return slices.Contains(providerAcceleratorTypes, v1.GoogleCloudAiplatformV1MachineSpecAcceleratorType(value))
+}
+
+// validateProvisioning checks that the Spot and reservation settings can be combined.
+func validateProvisioning(spot bool, affinity *ReservationAffinityArgs) error {
+	if affinity == nil {
+		return nil
+	}
+
+	switch affinity.Type {
+	case ReservationAffinityNone, ReservationAffinityAny:
+		if affinity.ReservationName != "" {
+			return fmt.Errorf("reservation name can only be set with reservation affinity %s", ReservationAffinitySpecific)
+		}
+	case ReservationAffinitySpecific:
+		if !reservationNamePattern.MatchString(affinity.ReservationName) {
+			return fmt.Errorf("reservation name %q must be in the form projects/PROJECT/zones/ZONE/reservations/RESERVATION", affinity.ReservationName)
+		}
+	default:
+		return fmt.Errorf("reservation affinity type must be one of %s, %s or %s, got %q",
+			ReservationAffinityNone, ReservationAffinityAny, ReservationAffinitySpecific, affinity.Type)
+	}
+
+	// Spot VMs never consume reserved capacity
+	if spot && affinity.Type != ReservationAffinityNone {
+		return fmt.Errorf("spot replicas cannot consume reservations, use reservation affinity %s", ReservationAffinityNone)
+	}
+
+	return nil
+}
+
+// reservationAffinityRequest returns the reservation affinity of the machine spec of a Vertex AI request.
+func reservationAffinityRequest(affinity *ReservationAffinityArgs) map[string]interface{} {
+	reservationAffinity := map[string]interface{}{
+		"reservationAffinityType": affinity.Type,
+	}
+	if affinity.Type == ReservationAffinitySpecific {
+		reservationAffinity["key"] = reservationNameKey
+		reservationAffinity["values"] = []string{affinity.ReservationName}
+	}
+
+	return reservationAffinity
+}
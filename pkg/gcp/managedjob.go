@@ -0,0 +1,137 @@
+package gcp
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/organizations"
+	v1 "github.com/pulumi/pulumi-google-native/sdk/go/google/aiplatform/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// jobProvider is an explicit instance of the gcp-ai-batch provider.
+type jobProvider struct {
+	pulumi.ProviderResourceState
+}
+
+// managedBatchPredictionJob is a batch prediction job created from the body of a Vertex AI request by the
+// gcp-ai-batch provider, for the settings the google-native provider does not model.
+type managedBatchPredictionJob struct {
+	pulumi.CustomResourceState
+
+	// Full resource name of the job.
+	Name pulumi.StringOutput `pulumi:"name"`
+}
+
+// managedModel is a custom model uploaded from the body of a Vertex AI request by the gcp-ai-batch provider,
+// for the container settings the model deployment resource does not support.
+type managedModel struct {
+	pulumi.CustomResourceState
+
+	// Full resource name of the model.
+	Name pulumi.StringOutput `pulumi:"name"`
+}
+
+// managedByProvider returns true when the job has settings the google-native provider does not model,
+// and is created by the gcp-ai-batch provider instead.
+func (v *AIBatch) managedByProvider(spec *batchJobSpec) bool {
+	return v.Spot || v.ReservationAffinity != nil || spec.unmodeledAcceleratorType ||
+		(v.GenerateExplanation && hasExplanationMetadataFeatures(v.ExplanationSpec))
+}
+
+// resourceProvider returns the gcp-ai-batch provider of the component, configured with the access token of
+// the gcp provider.
+func (v *AIBatch) resourceProvider(ctx *pulumi.Context) (pulumi.ProviderResource, error) {
+	if v.jobProvider != nil {
+		return v.jobProvider, nil
+	}
+
+	clientConfig, err := organizations.GetClientConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the credentials of the %s provider: %w", ResourceProviderName, err)
+	}
+
+	provider := &jobProvider{}
+	err = ctx.RegisterResource("pulumi:providers:"+ResourceProviderName,
+		v.NewResourceName(ResourceProviderName, "provider", 63),
+		pulumi.Map{
+			"accessToken": pulumi.ToSecret(pulumi.String(clientConfig.AccessToken)),
+		},
+		provider,
+		pulumi.Parent(v),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s provider: %w", ResourceProviderName, err)
+	}
+	v.jobProvider = provider
+
+	return provider, nil
+}
+
+// createManagedBatchPredictionJob creates the job from the body of a Vertex AI request with the gcp-ai-batch
+// provider, and reads it into the stack. Any change to the request replaces the job.
+func (v *AIBatch) createManagedBatchPredictionJob(ctx *pulumi.Context,
+	jobName string,
+	request pulumi.Map,
+	dependencies []pulumi.Resource) (*v1.BatchPredictionJob, error) {
+
+	provider, err := v.resourceProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &managedBatchPredictionJob{}
+	err = ctx.RegisterResource(managedBatchPredictionJobType, jobName,
+		pulumi.Map{
+			"project": pulumi.String(v.Project),
+			"region":  pulumi.String(v.Region),
+			"request": request,
+		},
+		job,
+		pulumi.Parent(v),
+		pulumi.Provider(provider),
+		pulumi.DependsOn(dependencies),
+		pulumi.RetainOnDelete(v.retainJobOnDelete),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create batch prediction job: %w", err)
+	}
+
+	// read with the google-native type, like the jobs the google-native provider creates
+	batchPredictionJob, err := v1.GetBatchPredictionJob(ctx, jobName, job.ID(), nil, pulumi.Parent(v))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch prediction job: %w", err)
+	}
+
+	return batchPredictionJob, nil
+}
+
+// createManagedModel uploads the model from the body of a Vertex AI request with the gcp-ai-batch provider.
+// Any change to the request replaces the model.
+func (v *AIBatch) createManagedModel(ctx *pulumi.Context,
+	modelName string,
+	request pulumi.Map,
+	dependencies []pulumi.Resource) (*managedModel, error) {
+
+	provider, err := v.resourceProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	model := &managedModel{}
+	err = ctx.RegisterResource(managedModelType, modelName,
+		pulumi.Map{
+			"project": pulumi.String(v.Project),
+			"region":  pulumi.String(v.Region),
+			"request": request,
+		},
+		model,
+		pulumi.Parent(v),
+		pulumi.Provider(provider),
+		pulumi.DependsOn(dependencies),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload model: %w", err)
+	}
+
+	return model, nil
+}
@@ -5,6 +5,7 @@ import (
 	"mime"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/storage"
@@ -12,7 +13,8 @@ import (
 )
 
 // uploadDirectoryToBucket traverses a directory and uploads all files to a GCS bucket.
-func (v *AIBatch) uploadDirectoryToBucket(ctx *pulumi.Context, localDir, baseObjectPath string) ([]pulumi.Resource, error) {
+// Resource names are the relative file paths, prefixed with resourceNamePrefix.
+func (v *AIBatch) uploadDirectoryToBucket(ctx *pulumi.Context, localDir, baseObjectPath, resourceNamePrefix string, opts ...pulumi.ResourceOption) ([]pulumi.Resource, error) {
 	if localDir == "" {
 		// no model artifacts to upload. skip
 		return []pulumi.Resource{}, nil
@@ -48,7 +50,7 @@ func (v *AIBatch) uploadDirectoryToBucket(ctx *pulumi.Context, localDir, baseObj
 		contentType := detectContentType(filePath)
 
 		// Create a unique resource name by replacing path separators with hyphens
-		resourceName := fmt.Sprintf("file-%s", strings.ReplaceAll(gcsObjectName, "/", "-"))
+		resourceName := fmt.Sprintf("%s-%s", resourceNamePrefix, strings.ReplaceAll(gcsObjectName, "/", "-"))
 		resourceName = strings.ReplaceAll(resourceName, ".", "-")
 
 		// Prepend the base object path if provided
@@ -63,7 +65,7 @@ func (v *AIBatch) uploadDirectoryToBucket(ctx *pulumi.Context, localDir, baseObj
 			Bucket:      v.artifactsBucket.Name,
 			Source:      pulumi.NewFileAsset(filePath),
 			ContentType: pulumi.String(contentType),
-		}, pulumi.Parent(v))
+		}, append(opts, pulumi.Parent(v))...)
 		if err != nil {
 			return fmt.Errorf("error creating bucket object for %s: %w", filePath, err)
 		}
@@ -98,11 +100,59 @@ func detectContentType(filePath string) string {
 	return contentType
 }
 
+// artifactsBucketName returns the name of the artifacts bucket, known before the bucket is created.
+func (v *AIBatch) artifactsBucketName() string {
+	return v.NewResourceName("vertex-model", "bucket", 63)
+}
+
+// readArtifactsBucketObject reads an object written to the artifacts bucket by a previous update.
+// It returns an empty content if the bucket or the object do not exist yet. Both are listed first,
+// as a failed read does not tell a missing object from any other error.
+func (v *AIBatch) readArtifactsBucketObject(ctx *pulumi.Context, objectName string) (string, error) {
+	bucketName := v.artifactsBucketName()
+	buckets, err := storage.GetBuckets(ctx, &storage.GetBucketsArgs{
+		Prefix:  pulumi.StringRef(bucketName),
+		Project: pulumi.StringRef(v.Project),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return "", fmt.Errorf("failed to list buckets: %w", err)
+	}
+	if !slices.ContainsFunc(buckets.Buckets, func(bucket storage.GetBucketsBucket) bool {
+		return bucket.Name == bucketName
+	}) {
+		// first update
+		return "", nil
+	}
+
+	objects, err := storage.GetBucketObjects(ctx, &storage.GetBucketObjectsArgs{
+		Bucket: bucketName,
+		Prefix: pulumi.StringRef(objectName),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return "", fmt.Errorf("failed to list objects of bucket %s: %w", bucketName, err)
+	}
+	if !slices.ContainsFunc(objects.BucketObjects, func(object storage.GetBucketObjectsBucketObject) bool {
+		return object.Name == objectName
+	}) {
+		return "", nil
+	}
+
+	object, err := storage.GetBucketObjectContent(ctx, &storage.GetBucketObjectContentArgs{
+		Bucket: bucketName,
+		Name:   objectName,
+	}, pulumi.Parent(v))
+	if err != nil {
+		return "", fmt.Errorf("failed to read object %s: %w", objectName, err)
+	}
+
+	return object.Content, nil
+}
+
 // setupModelBucket creates a bucket for model artifacts and uploads the model directory if any.
 // It returns the GCS URI of the uploaded model artifacts and the uploaded objects for dependency tracking.
 func (v *AIBatch) setupModelBucket(ctx *pulumi.Context, modelDir string, modelBucketBasePath string, labels map[string]string) (pulumi.StringOutput, []pulumi.Resource, error) {
 	// Create the bucket for model artifacts
-	bucketName := v.NewResourceName("vertex-model", "bucket", 63)
+	bucketName := v.artifactsBucketName()
 
 	// Merge default labels with provided labels
 	bucketLabels := pulumi.StringMap{
@@ -114,7 +164,7 @@ func (v *AIBatch) setupModelBucket(ctx *pulumi.Context, modelDir string, modelBu
 		bucketLabels[key] = pulumi.String(value)
 	}
 
-	artifactsBucket, err := storage.NewBucket(ctx, bucketName, &storage.BucketArgs{
+	bucketArgs := &storage.BucketArgs{
 		Name:         pulumi.String(bucketName),
 		Location:     pulumi.String(v.Region),
 		Project:      pulumi.String(v.Project),
@@ -126,7 +176,18 @@ func (v *AIBatch) setupModelBucket(ctx *pulumi.Context, modelDir string, modelBu
 			Enabled: pulumi.Bool(true), // Enable versioning for audit trail
 		},
 		Labels: bucketLabels,
-	}, pulumi.Parent(v))
+	}
+	if v.customerManagedEncryption {
+		// Objects are encrypted with the customer-managed key by default, including the predictions
+		bucketArgs.Encryption = &storage.BucketEncryptionArgs{
+			DefaultKmsKeyName: v.KmsKeyName,
+		}
+	}
+
+	artifactsBucket, err := storage.NewBucket(ctx, bucketName, bucketArgs,
+		pulumi.Parent(v),
+		pulumi.DependsOn(v.kmsKeyDependencies()),
+	)
 	if err != nil {
 		return pulumi.StringOutput{}, nil, fmt.Errorf("failed to create artifacts bucket: %w", err)
 	}
@@ -135,7 +196,7 @@ func (v *AIBatch) setupModelBucket(ctx *pulumi.Context, modelDir string, modelBu
 	// No luck with https://github.com/pulumi/pulumi-synced-folder /o\
 
 	// Upload the model artifacts, if any
-	uploadedObjects, err := v.uploadDirectoryToBucket(ctx, modelDir, modelBucketBasePath)
+	uploadedObjects, err := v.uploadDirectoryToBucket(ctx, modelDir, modelBucketBasePath, "file")
 	if err != nil {
 		return pulumi.StringOutput{}, nil, fmt.Errorf("failed to upload model artifacts: %w", err)
 	}
@@ -146,13 +207,16 @@ func (v *AIBatch) setupModelBucket(ctx *pulumi.Context, modelDir string, modelBu
 }
 
 // uploadInputDataToBucket uploads the input data to the bucket.
-func (v *AIBatch) uploadInputDataToBucket(ctx *pulumi.Context, inputDataDir string, inputDataBasePath string) (pulumi.StringOutput, []pulumi.Resource, error) {
-	uploadedDataObjects, err := v.uploadDirectoryToBucket(ctx, inputDataDir, inputDataBasePath)
+func (v *AIBatch) uploadInputDataToBucket(ctx *pulumi.Context, spec *batchJobSpec) (pulumi.StringOutput, []pulumi.Resource, error) {
+	// Inputs of past runs stay in their versioned prefix
+	uploadedDataObjects, err := v.uploadDirectoryToBucket(ctx, spec.inputDataLocalDir, spec.inputDataTargetDir, spec.jobResourceName("file"),
+		pulumi.RetainOnDelete(v.versionedRunPrefixes),
+	)
 	if err != nil {
 		return pulumi.StringOutput{}, nil, fmt.Errorf("failed to upload input data to bucket: %w", err)
 	}
 
-	inputDataBucketURI := pulumi.Sprintf("gs://%s/%s", v.artifactsBucket.Name, inputDataBasePath)
+	inputDataBucketURI := pulumi.Sprintf("gs://%s/%s", v.artifactsBucket.Name, spec.inputDataTargetDir)
 
 	return inputDataBucketURI, uploadedDataObjects, nil
 }
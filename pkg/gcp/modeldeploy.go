@@ -1,27 +1,322 @@
 package gcp
 
 import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
 	vertexmodeldeployment "github.com/davidmontoyago/pulumi-gcp-vertex-model-deployment/sdk/go/pulumi-gcp-vertex-model-deployment/resources"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
+// customModel is a custom model registered by the component.
+type customModel struct {
+	// Name of the compared model. Empty for the model of the component.
+	name           string
+	dir            string
+	bucketBasePath string
+	imageURL       pulumi.StringOutput
+	predictRoute   pulumi.StringOutput
+	healthRoute    pulumi.StringOutput
+	// Container settings beyond the routes, if any
+	container          *ContainerSpecArgs
+	inputSchemaPath    string
+	outputSchemaPath   string
+	behaviorSchemaPath string
+}
+
+// defaultCustomModel returns the custom model described by the component fields.
+func (v *AIBatch) defaultCustomModel() *customModel {
+	return &customModel{
+		dir:                v.ModelDir,
+		bucketBasePath:     v.ModelBucketBasePath,
+		imageURL:           v.ModelImageURL,
+		predictRoute:       v.PredictRoute,
+		healthRoute:        v.HealthRoute,
+		container:          v.ContainerSpec,
+		inputSchemaPath:    v.ModelPredictionInputSchemaPath,
+		outputSchemaPath:   v.ModelPredictionOutputSchemaPath,
+		behaviorSchemaPath: v.ModelPredictionBehaviorSchemaPath,
+	}
+}
+
+// registeredModel is a custom model registered in Vertex AI by the model deployment resource, or uploaded by
+// the gcp-ai-batch provider when its container spec goes beyond the routes.
+type registeredModel struct {
+	deployment *vertexmodeldeployment.VertexModelDeployment
+	managed    *managedModel
+}
+
+// resource returns the resource registering the model.
+func (m *registeredModel) resource() pulumi.Resource {
+	if m.managed != nil {
+		return m.managed
+	}
+
+	return m.deployment
+}
+
+// modelName returns the resource name of the registered model.
+func (m *registeredModel) modelName() pulumi.StringOutput {
+	if m.managed != nil {
+		return m.managed.Name
+	}
+
+	return m.deployment.ModelName
+}
+
+// validateContainerSpec checks the settings of the container known before deployment.
+func validateContainerSpec(args *ContainerSpecArgs) error {
+	routes := []struct {
+		name  string
+		route pulumi.StringInput
+	}{
+		{"predict route", args.PredictRoute},
+		{"health route", args.HealthRoute},
+	}
+	for _, route := range routes {
+		if value, ok := plainString(route.route); ok && !strings.HasPrefix(value, "/") {
+			return fmt.Errorf("%s %q must start with /", route.name, value)
+		}
+	}
+	for _, port := range args.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("port %d must be between 1 and 65535", port)
+		}
+	}
+	if args.SharedMemorySizeMB < 0 {
+		return fmt.Errorf("shared memory size must not be negative, got %d", args.SharedMemorySizeMB)
+	}
+	if args.StartupProbe != nil {
+		if err := validateProbe(args.StartupProbe); err != nil {
+			return fmt.Errorf("invalid startup probe: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateProbe checks that the probe runs exactly one check, with non-negative timings.
+func validateProbe(probe *ProbeArgs) error {
+	if (len(probe.ExecCommand) == 0) == (probe.HTTPGetPath == "") {
+		return fmt.Errorf("exactly one of exec command or HTTP GET path is required")
+	}
+	if probe.HTTPGetPath != "" && !strings.HasPrefix(probe.HTTPGetPath, "/") {
+		return fmt.Errorf("HTTP GET path %q must start with /", probe.HTTPGetPath)
+	}
+	if probe.HTTPGetPort < 0 || probe.HTTPGetPort > 65535 {
+		return fmt.Errorf("HTTP GET port %d must be between 1 and 65535", probe.HTTPGetPort)
+	}
+	if probe.PeriodSeconds < 0 || probe.TimeoutSeconds < 0 || probe.FailureThreshold < 0 || probe.InitialDelaySeconds < 0 {
+		return fmt.Errorf("probe timings must not be negative")
+	}
+
+	return nil
+}
+
+// hasExtendedContainerSpec returns true when the container spec sets more than the routes, which the model
+// deployment resource does not support.
+func hasExtendedContainerSpec(args *ContainerSpecArgs) bool {
+	return args != nil && (len(args.Env) > 0 || len(args.Ports) > 0 || len(args.Command) > 0 || len(args.Args) > 0 ||
+		args.SharedMemorySizeMB > 0 || args.StartupProbe != nil)
+}
+
+// mergeContainerSpec returns the container settings beyond the routes, each defaulting to the one of defaults.
+func mergeContainerSpec(args, defaults *ContainerSpecArgs) *ContainerSpecArgs {
+	if args == nil {
+		return defaults
+	}
+	if defaults == nil {
+		return args
+	}
+
+	merged := *args
+	if merged.Env == nil {
+		merged.Env = defaults.Env
+	}
+	if merged.Ports == nil {
+		merged.Ports = defaults.Ports
+	}
+	if merged.Command == nil {
+		merged.Command = defaults.Command
+	}
+	if merged.Args == nil {
+		merged.Args = defaults.Args
+	}
+	if merged.SharedMemorySizeMB == 0 {
+		merged.SharedMemorySizeMB = defaults.SharedMemorySizeMB
+	}
+	if merged.StartupProbe == nil {
+		merged.StartupProbe = defaults.StartupProbe
+	}
+
+	return &merged
+}
+
+// containerRoutes returns the predict and health routes set, defaulting to the given routes.
+func containerRoutes(args *ContainerSpecArgs, defaultPredictRoute, defaultHealthRoute pulumi.StringOutput) (pulumi.StringOutput, pulumi.StringOutput) {
+	predictRoute, healthRoute := defaultPredictRoute, defaultHealthRoute
+	if args == nil {
+		return predictRoute, healthRoute
+	}
+	if args.PredictRoute != nil {
+		predictRoute = args.PredictRoute.ToStringOutput()
+	}
+	if args.HealthRoute != nil {
+		healthRoute = args.HealthRoute.ToStringOutput()
+	}
+
+	return predictRoute, healthRoute
+}
+
+// registerModel registers the custom model in Vertex AI, with the gcp-ai-batch provider when its container
+// spec goes beyond the routes or when it is encrypted with a customer-managed key. The model deployment
+// resource supports neither.
+func (v *AIBatch) registerModel(ctx *pulumi.Context, model *customModel, modelArtifactsURI pulumi.StringOutput, serviceAccountEmail pulumi.StringOutput, uploadedObjects []pulumi.Resource) (*registeredModel, error) {
+	if !hasExtendedContainerSpec(model.container) && !v.customerManagedEncryption {
+		deployment, err := v.deployModel(ctx, model, modelArtifactsURI, serviceAccountEmail, uploadedObjects)
+		if err != nil {
+			return nil, err
+		}
+
+		return &registeredModel{deployment: deployment}, nil
+	}
+
+	dependencies := []pulumi.Resource{v.artifactsBucket}
+	dependencies = append(dependencies, uploadedObjects...)
+	// wait for IAM bindings to use the customer-managed key
+	dependencies = append(dependencies, v.kmsKeyDependencies()...)
+
+	managed, err := v.createManagedModel(ctx,
+		v.NewResourceName(suffixedResourceName("model", model.name), "", 63),
+		v.uploadModelRequest(model, modelArtifactsURI, serviceAccountEmail),
+		dependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	return &registeredModel{managed: managed}, nil
+}
+
+// uploadModelRequest returns the body of the Vertex AI request uploading the custom model.
+// See: https://cloud.google.com/vertex-ai/docs/reference/rest/v1/projects.locations.models/upload
+func (v *AIBatch) uploadModelRequest(model *customModel, modelArtifactsURI pulumi.StringOutput, serviceAccountEmail pulumi.StringOutput) pulumi.Map {
+	containerSpec := pulumi.Map{
+		"imageUri":     model.imageURL,
+		"predictRoute": model.predictRoute,
+		"healthRoute":  model.healthRoute,
+	}
+	if container := model.container; container != nil {
+		if len(container.Env) > 0 {
+			env := pulumi.Array{}
+			for _, name := range slices.Sorted(maps.Keys(container.Env)) {
+				env = append(env, pulumi.Map{"name": pulumi.String(name), "value": pulumi.String(container.Env[name])})
+			}
+			containerSpec["env"] = env
+		}
+		if len(container.Ports) > 0 {
+			ports := pulumi.Array{}
+			for _, port := range container.Ports {
+				ports = append(ports, pulumi.Map{"containerPort": pulumi.Int(port)})
+			}
+			containerSpec["ports"] = ports
+		}
+		if len(container.Command) > 0 {
+			containerSpec["command"] = pulumi.ToStringArray(container.Command)
+		}
+		if len(container.Args) > 0 {
+			containerSpec["args"] = pulumi.ToStringArray(container.Args)
+		}
+		if container.SharedMemorySizeMB > 0 {
+			containerSpec["sharedMemorySizeMb"] = pulumi.Int(container.SharedMemorySizeMB)
+		}
+		if container.StartupProbe != nil {
+			containerSpec["startupProbe"] = probeRequest(container.StartupProbe)
+		}
+	}
+
+	predictSchemata := pulumi.Map{
+		"instanceSchemaUri":   pulumi.Sprintf("%s/%s", modelArtifactsURI, model.inputSchemaPath),
+		"predictionSchemaUri": pulumi.Sprintf("%s/%s", modelArtifactsURI, model.outputSchemaPath),
+	}
+	if model.behaviorSchemaPath != "" {
+		predictSchemata["parametersSchemaUri"] = pulumi.Sprintf("%s/%s", modelArtifactsURI, model.behaviorSchemaPath)
+	}
+
+	displayName := v.ModelDisplayName
+	if model.name != "" {
+		displayName = pulumi.Sprintf("%s-%s", v.ModelDisplayName, model.name)
+	}
+	modelRequest := pulumi.Map{
+		"displayName":     displayName,
+		"artifactUri":     modelArtifactsURI,
+		"containerSpec":   containerSpec,
+		"predictSchemata": predictSchemata,
+	}
+	if len(v.Labels) > 0 {
+		modelRequest["labels"] = pulumi.ToStringMap(v.Labels)
+	}
+	if v.customerManagedEncryption {
+		modelRequest["encryptionSpec"] = pulumi.Map{"kmsKeyName": v.KmsKeyName}
+	}
+
+	return pulumi.Map{
+		"model":          modelRequest,
+		"serviceAccount": serviceAccountEmail,
+	}
+}
+
+// probeRequest returns the probe in the format of the Vertex AI API.
+func probeRequest(probe *ProbeArgs) pulumi.Map {
+	request := pulumi.Map{}
+	if len(probe.ExecCommand) > 0 {
+		request["exec"] = pulumi.Map{"command": pulumi.ToStringArray(probe.ExecCommand)}
+	} else {
+		httpGet := pulumi.Map{"path": pulumi.String(probe.HTTPGetPath)}
+		if probe.HTTPGetPort > 0 {
+			httpGet["port"] = pulumi.Int(probe.HTTPGetPort)
+		}
+		request["httpGet"] = httpGet
+	}
+	timings := []struct {
+		field string
+		value int
+	}{
+		{"periodSeconds", probe.PeriodSeconds},
+		{"timeoutSeconds", probe.TimeoutSeconds},
+		{"failureThreshold", probe.FailureThreshold},
+		{"initialDelaySeconds", probe.InitialDelaySeconds},
+	}
+	for _, timing := range timings {
+		if timing.value > 0 {
+			request[timing.field] = pulumi.Int(timing.value)
+		}
+	}
+
+	return request
+}
+
 // deployModel deploys the model to Vertex AI
 // for batch prediction jobs, we only need the model, not an endpoint
-func (v *AIBatch) deployModel(ctx *pulumi.Context, modelArtifactsURI pulumi.StringOutput, serviceAccountEmail pulumi.StringOutput, uploadedObjects []pulumi.Resource) (*vertexmodeldeployment.VertexModelDeployment, error) {
+func (v *AIBatch) deployModel(ctx *pulumi.Context, model *customModel, modelArtifactsURI pulumi.StringOutput, serviceAccountEmail pulumi.StringOutput, uploadedObjects []pulumi.Resource) (*vertexmodeldeployment.VertexModelDeployment, error) {
 	modelDeploymentArgs := &vertexmodeldeployment.VertexModelDeploymentArgs{
 		ProjectId:                      pulumi.String(v.Project),
 		Region:                         pulumi.String(v.Region),
 		ModelArtifactsBucketUri:        modelArtifactsURI,
-		ModelImageUrl:                  v.ModelImageURL,
-		ModelPredictionInputSchemaUri:  pulumi.Sprintf("%s/%s", modelArtifactsURI, v.ModelPredictionInputSchemaPath),
-		ModelPredictionOutputSchemaUri: pulumi.Sprintf("%s/%s", modelArtifactsURI, v.ModelPredictionOutputSchemaPath),
+		ModelImageUrl:                  model.imageURL,
+		ModelPredictionInputSchemaUri:  pulumi.Sprintf("%s/%s", modelArtifactsURI, model.inputSchemaPath),
+		ModelPredictionOutputSchemaUri: pulumi.Sprintf("%s/%s", modelArtifactsURI, model.outputSchemaPath),
 		ServiceAccount:                 serviceAccountEmail,
-		// TODO make me configurable
-		PredictRoute: pulumi.String("/predict"),
-		HealthRoute:  pulumi.String("/health"),
+		PredictRoute:                   model.predictRoute,
+		HealthRoute:                    model.healthRoute,
 	}
-	if v.ModelPredictionBehaviorSchemaPath != "" {
-		modelDeploymentArgs.ModelPredictionBehaviorSchemaUri = pulumi.Sprintf("%s/%s", modelArtifactsURI, v.ModelPredictionBehaviorSchemaPath)
+	// The model is registered without an explanation spec. The deployment resource does not support it
+	// yet, so it is set on the batch prediction job instead, overriding the spec of the model.
+	// See createBatchPredictionJob. Models encrypted with a customer-managed key are uploaded by the
+	// gcp-ai-batch provider instead. See registerModel.
+	if model.behaviorSchemaPath != "" {
+		modelDeploymentArgs.ModelPredictionBehaviorSchemaUri = pulumi.Sprintf("%s/%s", modelArtifactsURI, model.behaviorSchemaPath)
 	}
 
 	// Include dependencies on both the artifacts bucket and uploaded model artifacts
@@ -29,7 +324,7 @@ func (v *AIBatch) deployModel(ctx *pulumi.Context, modelArtifactsURI pulumi.Stri
 	dependencies = append(dependencies, uploadedObjects...)
 
 	return vertexmodeldeployment.NewVertexModelDeployment(ctx,
-		v.NewResourceName("vertex-model-deployment", "", 63),
+		v.NewResourceName(suffixedResourceName("vertex-model-deployment", model.name), "", 63),
 		modelDeploymentArgs,
 		pulumi.Parent(v),
 		pulumi.DependsOn(dependencies),
@@ -37,6 +37,25 @@ func (v *AIBatch) grantModelIAMRoles(ctx *pulumi.Context, serviceAccountEmail pu
 	return iamMembers, nil
 }
 
+// vertexServiceAgent returns the Vertex AI service agent of the project, created once per component.
+// Models from the garden run with it, and it encrypts on behalf of the jobs.
+func (v *AIBatch) vertexServiceAgent(ctx *pulumi.Context) (*projects.ServiceIdentity, error) {
+	if v.vertexAgent != nil {
+		return v.vertexAgent, nil
+	}
+
+	vertexAgent, err := projects.NewServiceIdentity(ctx, v.NewResourceName("vertex-ai-agent", "identity", 63), &projects.ServiceIdentityArgs{
+		Project: pulumi.String(v.Project),
+		Service: pulumi.String("aiplatform.googleapis.com"),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Vertex AI service agent: %w", err)
+	}
+	v.vertexAgent = vertexAgent
+
+	return vertexAgent, nil
+}
+
 // createModelServiceAccount creates a service account for Vertex AI operations.
 func (v *AIBatch) createModelServiceAccount(ctx *pulumi.Context) (pulumi.StringOutput, error) {
 	accountID := v.NewResourceName("model-account", "", 30)
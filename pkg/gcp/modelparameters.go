@@ -0,0 +1,92 @@
+package gcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// toModelParameters merges the typed and custom model parameters into the map sent to the model.
+func toModelParameters(params *ModelParametersArgs) (map[string]interface{}, error) {
+	parameters := map[string]interface{}{}
+
+	if params.Temperature != nil {
+		parameters["temperature"] = *params.Temperature
+	}
+	if params.MaxOutputTokens != nil {
+		parameters["maxOutputTokens"] = *params.MaxOutputTokens
+	}
+	if params.TopP != nil {
+		parameters["topP"] = *params.TopP
+	}
+	if params.TopK != nil {
+		parameters["topK"] = *params.TopK
+	}
+	if len(params.StopSequences) > 0 {
+		parameters["stopSequences"] = params.StopSequences
+	}
+
+	for key, value := range params.Custom {
+		if _, exists := parameters[key]; exists {
+			return nil, fmt.Errorf("custom model parameter %q is already set by a typed parameter", key)
+		}
+		parameters[key] = value
+	}
+
+	return parameters, nil
+}
+
+// validateModelParameters checks the model parameters against the YAML behavior schema of the model.
+func validateModelParameters(schemaFilePath string, parameters map[string]interface{}) error {
+	schemaYAML, err := os.ReadFile(filepath.Clean(schemaFilePath))
+	if err != nil {
+		return fmt.Errorf("failed to read model behavior schema %s: %w", schemaFilePath, err)
+	}
+
+	var schemaDoc interface{}
+	err = yaml.Unmarshal(schemaYAML, &schemaDoc)
+	if err != nil {
+		return fmt.Errorf("failed to parse model behavior schema %s: %w", schemaFilePath, err)
+	}
+
+	// The schema compiler only reads JSON
+	schemaJSON, err := json.Marshal(schemaDoc)
+	if err != nil {
+		return fmt.Errorf("failed to convert model behavior schema %s to JSON: %w", schemaFilePath, err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	err = compiler.AddResource("behavior-schema.json", bytes.NewReader(schemaJSON))
+	if err != nil {
+		return fmt.Errorf("failed to load model behavior schema %s: %w", schemaFilePath, err)
+	}
+	schema, err := compiler.Compile("behavior-schema.json")
+	if err != nil {
+		return fmt.Errorf("failed to compile model behavior schema %s: %w", schemaFilePath, err)
+	}
+
+	// Round trip the parameters through JSON so the validator sees plain JSON values
+	parametersJSON, err := json.Marshal(parameters)
+	if err != nil {
+		return fmt.Errorf("failed to convert model parameters to JSON: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(parametersJSON))
+	decoder.UseNumber()
+	var parametersDoc interface{}
+	err = decoder.Decode(&parametersDoc)
+	if err != nil {
+		return fmt.Errorf("failed to read model parameters: %w", err)
+	}
+
+	err = schema.Validate(parametersDoc)
+	if err != nil {
+		return fmt.Errorf("model parameters do not match behavior schema %s: %w", schemaFilePath, err)
+	}
+
+	return nil
+}
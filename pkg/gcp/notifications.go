@@ -0,0 +1,124 @@
+package gcp
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/logging"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/pubsub"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// validateNotifications checks the webhooks the notifications are pushed to.
+func validateNotifications(notifications *NotificationsArgs) error {
+	for _, webhookURL := range notifications.WebhookURLs {
+		endpoint, err := url.Parse(webhookURL)
+		if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+			return fmt.Errorf("webhook URL %q must be an absolute https URL", webhookURL)
+		}
+	}
+
+	return nil
+}
+
+// setupNotifications creates the topic the job state changes and prediction writes are published to,
+// the log sink publishing them, and a push subscription per webhook.
+func (v *AIBatch) setupNotifications(ctx *pulumi.Context, notifications *NotificationsArgs) error {
+	topicName := v.NewResourceName("batch-notifications", "topic", 63)
+	topic, err := pubsub.NewTopic(ctx, topicName, &pubsub.TopicArgs{
+		Project: pulumi.String(v.Project),
+		Name:    pulumi.String(topicName),
+		Labels:  pulumi.ToStringMap(v.Labels),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return fmt.Errorf("failed to create notifications topic: %w", err)
+	}
+	v.notificationsTopic = topic
+
+	sinkName := v.NewResourceName("batch-notifications", "sink", 63)
+	sink, err := logging.NewProjectSink(ctx, sinkName, &logging.ProjectSinkArgs{
+		Project:              pulumi.String(v.Project),
+		Name:                 pulumi.String(sinkName),
+		Description:          pulumi.Sprintf("Publishes the job state changes and prediction writes of %s", v.JobDisplayName),
+		Destination:          pulumi.Sprintf("pubsub.googleapis.com/projects/%s/topics/%s", v.Project, topic.Name),
+		Filter:               v.notificationsFilter(),
+		UniqueWriterIdentity: pulumi.Bool(true),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return fmt.Errorf("failed to create notifications log sink: %w", err)
+	}
+	v.notificationsSink = sink
+
+	// The sink writes as its own identity
+	_, err = pubsub.NewTopicIAMMember(ctx, v.NewResourceName("notifications-sink-publisher", "iam-member", 63), &pubsub.TopicIAMMemberArgs{
+		Project: pulumi.String(v.Project),
+		Topic:   topic.Name,
+		Role:    pulumi.String("roles/pubsub.publisher"),
+		Member:  sink.WriterIdentity,
+	}, pulumi.Parent(v))
+	if err != nil {
+		return fmt.Errorf("failed to grant the log sink access to the notifications topic: %w", err)
+	}
+
+	for webhookIndex, webhookURL := range notifications.WebhookURLs {
+		pushConfig := &pubsub.SubscriptionPushConfigArgs{
+			PushEndpoint: pulumi.String(webhookURL),
+		}
+		if notifications.RawPayload {
+			// the log entry is the request body, without the Pub/Sub envelope
+			pushConfig.NoWrapper = &pubsub.SubscriptionPushConfigNoWrapperArgs{
+				WriteMetadata: pulumi.Bool(false),
+			}
+		}
+
+		subscriptionName := v.NewResourceName(fmt.Sprintf("batch-notifications-webhook-%d", webhookIndex), "subscription", 63)
+		subscription, err := pubsub.NewSubscription(ctx, subscriptionName, &pubsub.SubscriptionArgs{
+			Project:    pulumi.String(v.Project),
+			Name:       pulumi.String(subscriptionName),
+			Topic:      topic.Name,
+			PushConfig: pushConfig,
+			Labels:     pulumi.ToStringMap(v.Labels),
+		}, pulumi.Parent(v))
+		if err != nil {
+			return fmt.Errorf("failed to create webhook subscription %d: %w", webhookIndex, err)
+		}
+		v.webhookSubscriptions = append(v.webhookSubscriptions, subscription)
+	}
+
+	return nil
+}
+
+// notificationsFilter returns the log filter matching the state changes of the jobs of the component, and
+// the writes of prediction files under the output prefix unless the predictions go to BigQuery.
+// Jobs are matched by ID, so jobs launched by workflows are only notified through their prediction writes.
+func (v *AIBatch) notificationsFilter() pulumi.StringOutput {
+	var jobNames []interface{}
+	for _, spec := range v.jobSpecs {
+		jobNames = append(jobNames, spec.job.Name)
+	}
+
+	return pulumi.All(v.artifactsBucket.Name, v.OutputDataPath, pulumi.All(jobNames...)).ApplyT(func(values []interface{}) string {
+		bucketName := values[0].(string)
+		outputDataPath := strings.Trim(values[1].(string), "/")
+
+		jobIDs := make([]string, 0, len(jobNames))
+		for _, jobName := range values[2].([]interface{}) {
+			// job names end with the numeric job ID
+			jobIDs = append(jobIDs, fmt.Sprintf("%q", path.Base(jobName.(string))))
+		}
+
+		jobStateChanges := fmt.Sprintf(`resource.type="aiplatform.googleapis.com/BatchPredictionJob" AND resource.labels.job_id=(%s) AND jsonPayload.state:"JOB_STATE_"`,
+			strings.Join(jobIDs, " OR "))
+		if v.OutputBigQuery != nil {
+			return jobStateChanges
+		}
+
+		// Requires the data write audit logs of Cloud Storage
+		predictionWrites := fmt.Sprintf(`resource.type="gcs_bucket" AND resource.labels.bucket_name=%q AND protoPayload.methodName="storage.objects.create" AND protoPayload.resourceName:%q`,
+			bucketName, fmt.Sprintf("projects/_/buckets/%s/objects/%s/", bucketName, outputDataPath))
+
+		return fmt.Sprintf("(%s) OR (%s)", jobStateChanges, predictionWrites)
+	}).(pulumi.StringOutput)
+}
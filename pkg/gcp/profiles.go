@@ -0,0 +1,101 @@
+package gcp
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// GardenModelProfile is a deployment known to run a Model Garden model.
+type GardenModelProfile struct {
+	// Recommended machine type of the replicas (e.g., "g2-standard-12").
+	MachineType string
+	// Recommended accelerator type and count of the machine type.
+	AcceleratorType  string
+	AcceleratorCount int
+	// Recommended replica counts.
+	StartingReplicaCount int
+	MaxReplicaCount      int
+	// Regions the model is offered in with the recommended machine spec. Empty for any region.
+	Regions []string
+	// Accelerator types known not to run the model, e.g., without enough memory or compute capability.
+	UnsupportedAcceleratorTypes []string
+}
+
+// GardenModelProfiles maps the Model Garden models to the deployments known to run them.
+// When ModelName is one of them, the profile fills in the machine spec and replica counts not set.
+// Add profiles for other models before creating the component.
+var GardenModelProfiles = map[string]GardenModelProfile{
+	"publishers/meta/models/llama3-2@llama-3.2-3b-instruct": {
+		MachineType:                 "g2-standard-12",
+		AcceleratorType:             "NVIDIA_L4",
+		AcceleratorCount:            1,
+		StartingReplicaCount:        1,
+		MaxReplicaCount:             1,
+		Regions:                     []string{"us-central1", "us-east1", "us-east4", "us-west1", "us-west4", "europe-west4", "asia-southeast1"},
+		UnsupportedAcceleratorTypes: []string{"NVIDIA_TESLA_P4", "NVIDIA_TESLA_P100"},
+	},
+	"publishers/mistral-ai/models/mistral@mistral-7b-instruct-v0.2": {
+		MachineType:          "g2-standard-12",
+		AcceleratorType:      "NVIDIA_L4",
+		AcceleratorCount:     1,
+		StartingReplicaCount: 1,
+		MaxReplicaCount:      1,
+		Regions:              []string{"us-central1", "us-east1", "us-east4", "us-west1", "us-west4", "europe-west4", "asia-southeast1"},
+		// 16GB are not enough for the 7B weights in half precision
+		UnsupportedAcceleratorTypes: []string{"NVIDIA_TESLA_T4", "NVIDIA_TESLA_P4", "NVIDIA_TESLA_P100"},
+	},
+	"publishers/google/models/gemma2@gemma-2-2b-it": {
+		MachineType:                 "g2-standard-12",
+		AcceleratorType:             "NVIDIA_L4",
+		AcceleratorCount:            1,
+		StartingReplicaCount:        1,
+		MaxReplicaCount:             1,
+		Regions:                     []string{"us-central1", "us-east1", "us-east4", "us-west1", "us-west4", "europe-west4", "asia-southeast1"},
+		UnsupportedAcceleratorTypes: []string{"NVIDIA_TESLA_P4", "NVIDIA_TESLA_P100"},
+	},
+}
+
+// applyGardenModelProfile fills in the machine spec and replica counts not set from the profile of the garden
+// model, if any, and returns warnings for the settings known not to run the model.
+// Accelerators are only filled in along with the machine type.
+func applyGardenModelProfile(args *AIBatchArgs) []string {
+	profile, ok := GardenModelProfiles[args.ModelName]
+	if !ok {
+		return nil
+	}
+
+	if args.MachineType == nil {
+		args.MachineType = pulumi.String(profile.MachineType)
+		if args.AcceleratorType == nil && profile.AcceleratorType != "" {
+			args.AcceleratorType = pulumi.String(profile.AcceleratorType)
+		}
+		if args.AcceleratorCount == nil && profile.AcceleratorCount > 0 {
+			args.AcceleratorCount = pulumi.Int(profile.AcceleratorCount)
+		}
+	}
+	if args.StartingReplicaCount == nil && profile.StartingReplicaCount > 0 {
+		args.StartingReplicaCount = pulumi.Int(profile.StartingReplicaCount)
+	}
+	if args.MaxReplicaCount == nil && profile.MaxReplicaCount > 0 {
+		args.MaxReplicaCount = pulumi.Int(profile.MaxReplicaCount)
+	}
+
+	var warnings []string
+	acceleratorType, known := plainString(args.AcceleratorType)
+	if known && slices.Contains(profile.UnsupportedAcceleratorTypes, acceleratorType) {
+		warnings = append(warnings, fmt.Sprintf("accelerator type %s is known not to run %s, %d %s on %s is recommended",
+			acceleratorType, args.ModelName, profile.AcceleratorCount, profile.AcceleratorType, profile.MachineType))
+	}
+	if known && acceleratorType == defaultAcceleratorType && profile.AcceleratorType != "" {
+		warnings = append(warnings, fmt.Sprintf("%s is known to need an accelerator, %d %s on %s is recommended",
+			args.ModelName, profile.AcceleratorCount, profile.AcceleratorType, profile.MachineType))
+	}
+	if len(profile.Regions) > 0 && !slices.Contains(profile.Regions, args.Region) {
+		warnings = append(warnings, fmt.Sprintf("%s is not known to be offered in region %s with %s, it is in %v",
+			args.ModelName, args.Region, profile.MachineType, profile.Regions))
+	}
+
+	return warnings
+}
@@ -0,0 +1,350 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/rpcutil"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// ResourceProviderName is the package of the resources the gcp-ai-batch provider manages: the Vertex AI
+	// resources with settings the google-native provider does not model. Its plugin, pulumi-resource-gcp-ai-batch,
+	// must be on the PATH of the Pulumi CLI. See cmd/pulumi-resource-gcp-ai-batch.
+	ResourceProviderName = "gcp-ai-batch"
+
+	// managedBatchPredictionJobType is the type of the batch prediction jobs managed by the gcp-ai-batch provider.
+	managedBatchPredictionJobType = ResourceProviderName + ":resources:BatchPredictionJob"
+	// managedModelType is the type of the models uploaded by the gcp-ai-batch provider.
+	managedModelType = ResourceProviderName + ":resources:Model"
+
+	// cancelledJobPollInterval is how often a cancelled job is checked until it can be deleted.
+	cancelledJobPollInterval = 10 * time.Second
+	// modelUploadPollInterval is how often the operation uploading a model is checked until it is done.
+	modelUploadPollInterval = 5 * time.Second
+)
+
+// resourceProvider creates, reads and deletes the resources of the gcp-ai-batch package with the Vertex AI REST API.
+// Vertex AI jobs and uploaded models are immutable, so any change replaces them.
+type resourceProvider struct {
+	pulumirpc.UnimplementedResourceProviderServer
+
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+}
+
+// NewResourceProvider returns the gcp-ai-batch resource provider. httpClient sends the Vertex AI requests,
+// defaults to http.DefaultClient.
+func NewResourceProvider(httpClient *http.Client) pulumirpc.ResourceProviderServer {
+	return &resourceProvider{httpClient: httpClient}
+}
+
+// ServeResourceProvider serves the gcp-ai-batch resource provider to the Pulumi engine until it is stopped.
+// The engine reads the port of the provider from the standard output.
+func ServeResourceProvider() error {
+	handle, err := rpcutil.ServeWithOptions(rpcutil.ServeOptions{
+		Init: func(server *grpc.Server) error {
+			pulumirpc.RegisterResourceProviderServer(server, NewResourceProvider(nil))
+
+			return nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serve the %s resource provider: %w", ResourceProviderName, err)
+	}
+	fmt.Printf("%d\n", handle.Port)
+
+	return <-handle.Done
+}
+
+// GetPluginInfo returns the version of the module the plugin is built from.
+func (p *resourceProvider) GetPluginInfo(context.Context, *emptypb.Empty) (*pulumirpc.PluginInfo, error) {
+	version := "0.0.0"
+	if buildInfo, ok := debug.ReadBuildInfo(); ok && strings.HasPrefix(buildInfo.Main.Version, "v") {
+		version = strings.TrimPrefix(buildInfo.Main.Version, "v")
+	}
+
+	return &pulumirpc.PluginInfo{Version: version}, nil
+}
+
+// Cancel is a no-op, the requests in flight are cancelled with their context.
+func (p *resourceProvider) Cancel(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+// CheckConfig accepts the configuration as is.
+func (p *resourceProvider) CheckConfig(_ context.Context, req *pulumirpc.CheckRequest) (*pulumirpc.CheckResponse, error) {
+	return &pulumirpc.CheckResponse{Inputs: req.GetNews()}, nil
+}
+
+// DiffConfig never reports a change. The access token changes on every update, and does not change the resources.
+func (p *resourceProvider) DiffConfig(context.Context, *pulumirpc.DiffRequest) (*pulumirpc.DiffResponse, error) {
+	return &pulumirpc.DiffResponse{Changes: pulumirpc.DiffResponse_DIFF_NONE}, nil
+}
+
+// Configure reads the OAuth2 access token of the caller. GOOGLE_OAUTH_ACCESS_TOKEN takes precedence over the
+// accessToken setting, e.g., to destroy a stack once the token saved with the provider expired.
+func (p *resourceProvider) Configure(_ context.Context, req *pulumirpc.ConfigureRequest) (*pulumirpc.ConfigureResponse, error) {
+	accessToken := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if accessToken == "" {
+		config, err := unmarshalProviderProperties(req.GetArgs())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the provider configuration: %w", err)
+		}
+		if token := config["accessToken"]; token.IsString() {
+			accessToken = token.StringValue()
+		} else {
+			accessToken = req.GetVariables()[ResourceProviderName+":config:accessToken"]
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.accessToken = accessToken
+
+	return &pulumirpc.ConfigureResponse{AcceptSecrets: true}, nil
+}
+
+// Check fails when a required property of the resource is missing.
+func (p *resourceProvider) Check(_ context.Context, req *pulumirpc.CheckRequest) (*pulumirpc.CheckResponse, error) {
+	requiredProperties, err := resourceTypeProperties(req.GetUrn())
+	if err != nil {
+		return nil, err
+	}
+	news, err := unmarshalProviderProperties(req.GetNews())
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []*pulumirpc.CheckFailure
+	for _, property := range requiredProperties {
+		if !news.HasValue(resource.PropertyKey(property)) {
+			failures = append(failures, &pulumirpc.CheckFailure{Property: property, Reason: "missing required property"})
+		}
+	}
+
+	return &pulumirpc.CheckResponse{Inputs: req.GetNews(), Failures: failures}, nil
+}
+
+// Diff replaces the resource when any of its properties changes.
+func (p *resourceProvider) Diff(_ context.Context, req *pulumirpc.DiffRequest) (*pulumirpc.DiffResponse, error) {
+	properties, err := resourceTypeProperties(req.GetUrn())
+	if err != nil {
+		return nil, err
+	}
+	oldInputs := req.GetOldInputs()
+	if oldInputs == nil {
+		// engines before old inputs were sent
+		oldInputs = req.GetOlds()
+	}
+	olds, err := unmarshalProviderProperties(oldInputs)
+	if err != nil {
+		return nil, err
+	}
+	news, err := unmarshalProviderProperties(req.GetNews())
+	if err != nil {
+		return nil, err
+	}
+
+	var replaces []string
+	for _, property := range properties {
+		key := resource.PropertyKey(property)
+		if !olds[key].DeepEquals(news[key]) {
+			replaces = append(replaces, property)
+		}
+	}
+	if len(replaces) == 0 {
+		return &pulumirpc.DiffResponse{Changes: pulumirpc.DiffResponse_DIFF_NONE}, nil
+	}
+
+	return &pulumirpc.DiffResponse{
+		Changes:  pulumirpc.DiffResponse_DIFF_SOME,
+		Replaces: replaces,
+		Diffs:    replaces,
+	}, nil
+}
+
+// Create creates the resource, with its full resource name as ID.
+func (p *resourceProvider) Create(ctx context.Context, req *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
+	if _, err := resourceTypeProperties(req.GetUrn()); err != nil {
+		return nil, err
+	}
+	inputs, err := unmarshalProviderProperties(req.GetProperties())
+	if err != nil {
+		return nil, err
+	}
+
+	project := inputs["project"].StringValue()
+	region := inputs["region"].StringValue()
+	request := inputs["request"].ObjectValue().Mappable()
+
+	var resourceName string
+	if resource.URN(req.GetUrn()).Type() == managedModelType {
+		resourceName, err = p.client().UploadModel(ctx, project, region, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload model: %w", err)
+		}
+	} else {
+		resourceName, err = p.client().CreateBatchPredictionJob(ctx, project, region, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create batch prediction job: %w", err)
+		}
+	}
+
+	outputs := inputs.Copy()
+	outputs["name"] = resource.NewStringProperty(resourceName)
+	properties, err := plugin.MarshalProperties(outputs, plugin.MarshalOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pulumirpc.CreateResponse{Id: resourceName, Properties: properties}, nil
+}
+
+// Read returns the resource as is while it exists, and an empty ID once it is deleted.
+func (p *resourceProvider) Read(ctx context.Context, req *pulumirpc.ReadRequest) (*pulumirpc.ReadResponse, error) {
+	if _, err := resourceTypeProperties(req.GetUrn()); err != nil {
+		return nil, err
+	}
+
+	if resource.URN(req.GetUrn()).Type() == managedModelType {
+		exists, err := p.client().ModelExists(ctx, resourceNameRegion(req.GetId()), req.GetId())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get model %s: %w", req.GetId(), err)
+		}
+		if !exists {
+			return &pulumirpc.ReadResponse{}, nil
+		}
+
+		return &pulumirpc.ReadResponse{Id: req.GetId(), Properties: req.GetProperties(), Inputs: req.GetInputs()}, nil
+	}
+
+	state, err := p.client().GetBatchPredictionJobState(ctx, resourceNameRegion(req.GetId()), req.GetId())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch prediction job %s: %w", req.GetId(), err)
+	}
+	if state == "" {
+		// deleted outside of the stack
+		return &pulumirpc.ReadResponse{}, nil
+	}
+
+	return &pulumirpc.ReadResponse{Id: req.GetId(), Properties: req.GetProperties(), Inputs: req.GetInputs()}, nil
+}
+
+// Update is never called, as every change replaces the resource.
+func (p *resourceProvider) Update(context.Context, *pulumirpc.UpdateRequest) (*pulumirpc.UpdateResponse, error) {
+	return nil, fmt.Errorf("%s resources are replaced on every change", ResourceProviderName)
+}
+
+// Delete deletes the model, or cancels the job if it is still running, waits for the cancellation, and deletes it.
+func (p *resourceProvider) Delete(ctx context.Context, req *pulumirpc.DeleteRequest) (*emptypb.Empty, error) {
+	if _, err := resourceTypeProperties(req.GetUrn()); err != nil {
+		return nil, err
+	}
+	if req.GetTimeout() > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.GetTimeout()*float64(time.Second)))
+		defer cancel()
+	}
+
+	client := p.client()
+	if resource.URN(req.GetUrn()).Type() == managedModelType {
+		// the jobs running the model are deleted first
+		if err := client.DeleteModel(ctx, resourceNameRegion(req.GetId()), req.GetId()); err != nil {
+			return nil, fmt.Errorf("failed to delete model %s: %w", req.GetId(), err)
+		}
+
+		return &emptypb.Empty{}, nil
+	}
+
+	jobName := req.GetId()
+	region := resourceNameRegion(jobName)
+	state, err := client.GetBatchPredictionJobState(ctx, region, jobName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch prediction job %s: %w", jobName, err)
+	}
+	if state == "" {
+		// already deleted
+		return &emptypb.Empty{}, nil
+	}
+
+	if !slices.Contains(terminalJobStates, state) {
+		// running jobs cannot be deleted
+		if err := client.CancelBatchPredictionJob(ctx, region, jobName); err != nil {
+			return nil, fmt.Errorf("failed to cancel batch prediction job %s: %w", jobName, err)
+		}
+		for {
+			state, err = client.GetBatchPredictionJobState(ctx, region, jobName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get batch prediction job %s: %w", jobName, err)
+			}
+			if state == "" || slices.Contains(terminalJobStates, state) {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("timed out waiting for batch prediction job %s to be cancelled, last state %s", jobName, state)
+			case <-time.After(cancelledJobPollInterval):
+			}
+		}
+	}
+
+	if err := client.DeleteBatchPredictionJob(ctx, region, jobName); err != nil {
+		return nil, fmt.Errorf("failed to delete batch prediction job %s: %w", jobName, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// client returns a Vertex AI client with the access token of the configuration.
+func (p *resourceProvider) client() *GoogleBatchJobClient {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return &GoogleBatchJobClient{AccessToken: p.accessToken, HTTPClient: p.httpClient}
+}
+
+// resourceTypeProperties returns the input properties of the type of the resource, all of them required.
+func resourceTypeProperties(urn string) ([]string, error) {
+	resourceType := resource.URN(urn).Type()
+	switch resourceType {
+	case managedBatchPredictionJobType, managedModelType:
+		return []string{"project", "region", "request"}, nil
+	default:
+		return nil, fmt.Errorf("unknown resource type %s", resourceType)
+	}
+}
+
+// resourceNameRegion returns the location of a full resource name,
+// e.g., "us-central1" for "projects/my-project/locations/us-central1/batchPredictionJobs/123".
+func resourceNameRegion(resourceName string) string {
+	segments := strings.Split(resourceName, "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] == "locations" {
+			return segments[i+1]
+		}
+	}
+
+	return ""
+}
+
+// unmarshalProviderProperties reads the properties sent by the engine, keeping unknown values on preview.
+func unmarshalProviderProperties(properties *structpb.Struct) (resource.PropertyMap, error) {
+	return plugin.UnmarshalProperties(properties, plugin.MarshalOptions{KeepUnknowns: true, SkipNulls: true})
+}
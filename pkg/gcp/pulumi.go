@@ -20,3 +20,17 @@ func setDefaultInt(input pulumi.IntInput, defaultValue int) pulumi.IntOutput {
 
 	return input.ToIntOutput()
 }
+
+// Helper functions for reading values known before deployment.
+// Outputs of other resources are only known at deployment time, so validations skip them.
+func plainString(input pulumi.StringInput) (string, bool) {
+	value, ok := input.(pulumi.String)
+
+	return string(value), ok
+}
+
+func plainInt(input pulumi.IntInput) (int, bool) {
+	value, ok := input.(pulumi.Int)
+
+	return int(value), ok
+}
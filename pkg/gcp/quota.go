@@ -0,0 +1,358 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/organizations"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// UnlimitedQuota is the limit of a quota without a ceiling.
+const UnlimitedQuota = -1
+
+// Quota is the limit and current usage of a quota metric in a region.
+type Quota struct {
+	// Quota metric (e.g., "NVIDIA_L4_GPUS").
+	Metric string
+	// Maximum usage allowed. UnlimitedQuota when there is no ceiling.
+	Limit int64
+	// Current usage. 0 when the API does not report it.
+	Usage int64
+}
+
+// available returns the quota left, or math.MaxInt64 when unlimited.
+func (q Quota) available() int64 {
+	if q.Limit == UnlimitedQuota {
+		return math.MaxInt64
+	}
+
+	return q.Limit - q.Usage
+}
+
+// QuotaClient reads the quotas limiting batch prediction jobs.
+type QuotaClient interface {
+	// AcceleratorQuotas returns the quotas limiting the accelerators of the type in the region, e.g.,
+	// the Vertex AI serving quota and the Compute Engine regional quota. Empty when none is known.
+	AcceleratorQuotas(ctx context.Context, project, region, acceleratorType string) ([]Quota, error)
+	// ConcurrentJobsQuota returns the quota of batch prediction jobs running at once in the region.
+	ConcurrentJobsQuota(ctx context.Context, project, region string) (Quota, error)
+}
+
+// computeAcceleratorMetrics maps the accelerator types to the regional Compute Engine quota metrics.
+var computeAcceleratorMetrics = map[string]string{
+	"NVIDIA_TESLA_T4":       "NVIDIA_T4_GPUS",
+	"NVIDIA_TESLA_P4":       "NVIDIA_P4_GPUS",
+	"NVIDIA_TESLA_P100":     "NVIDIA_P100_GPUS",
+	"NVIDIA_TESLA_V100":     "NVIDIA_V100_GPUS",
+	"NVIDIA_L4":             "NVIDIA_L4_GPUS",
+	"NVIDIA_TESLA_A100":     "NVIDIA_A100_GPUS",
+	"NVIDIA_A100_80GB":      "NVIDIA_A100_80GB_GPUS",
+	"NVIDIA_H100_80GB":      "NVIDIA_H100_GPUS",
+	"NVIDIA_H100_MEGA_80GB": "NVIDIA_H100_MEGA_GPUS",
+}
+
+// vertexAcceleratorMetric returns the Vertex AI serving quota metric of the accelerator type,
+// e.g., "aiplatform.googleapis.com/custom_model_serving_nvidia_l4_gpus" for "NVIDIA_L4".
+func vertexAcceleratorMetric(acceleratorType string) string {
+	gpu := strings.ToLower(strings.Replace(acceleratorType, "TESLA_", "", 1))
+
+	return fmt.Sprintf("aiplatform.googleapis.com/custom_model_serving_%s_gpus", gpu)
+}
+
+// concurrentBatchJobsMetric is the Vertex AI quota metric of the batch prediction jobs running at once.
+const concurrentBatchJobsMetric = "aiplatform.googleapis.com/batch_prediction_jobs"
+
+// GoogleQuotaClient reads the Compute Engine quotas from the regions API, and the Vertex AI quotas from the
+// Service Usage API. Vertex AI quotas only report their limit: the usage of concurrent jobs is counted from
+// the unfinished jobs of the region, and the usage of accelerators is not known.
+type GoogleQuotaClient struct {
+	// OAuth2 access token of the caller.
+	AccessToken string
+	// Client sending the requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// AcceleratorQuotas returns the Vertex AI and Compute Engine quotas of the accelerator type in the region.
+func (c *GoogleQuotaClient) AcceleratorQuotas(ctx context.Context, project, region, acceleratorType string) ([]Quota, error) {
+	var quotas []Quota
+
+	vertexQuota, found, err := c.vertexQuota(ctx, project, region, vertexAcceleratorMetric(acceleratorType))
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		quotas = append(quotas, vertexQuota)
+	}
+
+	computeMetric, ok := computeAcceleratorMetrics[acceleratorType]
+	if !ok {
+		return quotas, nil
+	}
+	var computeRegion struct {
+		Quotas []struct {
+			Metric string  `json:"metric"`
+			Limit  float64 `json:"limit"`
+			Usage  float64 `json:"usage"`
+		} `json:"quotas"`
+	}
+	regionURL := fmt.Sprintf("https://compute.googleapis.com/compute/v1/projects/%s/regions/%s", url.PathEscape(project), url.PathEscape(region))
+	if err := c.get(ctx, regionURL, &computeRegion); err != nil {
+		return nil, fmt.Errorf("failed to read the Compute Engine quotas of region %s: %w", region, err)
+	}
+	for _, quota := range computeRegion.Quotas {
+		if quota.Metric == computeMetric {
+			quotas = append(quotas, Quota{Metric: quota.Metric, Limit: int64(quota.Limit), Usage: int64(quota.Usage)})
+		}
+	}
+
+	return quotas, nil
+}
+
+// ConcurrentJobsQuota returns the Vertex AI quota of concurrent batch prediction jobs, with the unfinished
+// jobs of the region as usage.
+func (c *GoogleQuotaClient) ConcurrentJobsQuota(ctx context.Context, project, region string) (Quota, error) {
+	quota, found, err := c.vertexQuota(ctx, project, region, concurrentBatchJobsMetric)
+	if err != nil {
+		return Quota{}, err
+	}
+	if !found {
+		quota = Quota{Metric: concurrentBatchJobsMetric, Limit: UnlimitedQuota}
+	}
+
+	var unfinishedStates []string
+	for _, state := range []string{"JOB_STATE_QUEUED", "JOB_STATE_PENDING", "JOB_STATE_RUNNING"} {
+		unfinishedStates = append(unfinishedStates, fmt.Sprintf("state=%q", state))
+	}
+	query := url.Values{
+		"filter":   {strings.Join(unfinishedStates, " OR ")},
+		"pageSize": {"100"},
+	}
+	for {
+		var page struct {
+			BatchPredictionJobs []json.RawMessage `json:"batchPredictionJobs"`
+			NextPageToken       string            `json:"nextPageToken"`
+		}
+		jobsURL := fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/batchPredictionJobs?%s",
+			region, url.PathEscape(project), url.PathEscape(region), query.Encode())
+		if err := c.get(ctx, jobsURL, &page); err != nil {
+			return Quota{}, fmt.Errorf("failed to list the batch prediction jobs of region %s: %w", region, err)
+		}
+		quota.Usage += int64(len(page.BatchPredictionJobs))
+		if page.NextPageToken == "" {
+			return quota, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// vertexQuota returns the limit of the Vertex AI quota metric in the region. Limits without a region
+// dimension apply to every region. It returns false when the project has no such metric.
+func (c *GoogleQuotaClient) vertexQuota(ctx context.Context, project, region, metric string) (Quota, bool, error) {
+	var quotaMetric struct {
+		ConsumerQuotaLimits []struct {
+			QuotaBuckets []struct {
+				EffectiveLimit string            `json:"effectiveLimit"`
+				Dimensions     map[string]string `json:"dimensions"`
+			} `json:"quotaBuckets"`
+		} `json:"consumerQuotaLimits"`
+	}
+	metricURL := fmt.Sprintf("https://serviceusage.googleapis.com/v1beta1/projects/%s/services/aiplatform.googleapis.com/consumerQuotaMetrics/%s",
+		url.PathEscape(project), url.PathEscape(metric))
+	err := c.get(ctx, metricURL, &quotaMetric)
+	if err != nil {
+		if isGoogleAPINotFound(err) {
+			return Quota{}, false, nil
+		}
+
+		return Quota{}, false, fmt.Errorf("failed to read Vertex AI quota %s: %w", metric, err)
+	}
+
+	// The bucket of the region overrides the default bucket
+	limit, found := "", false
+	for _, quotaLimit := range quotaMetric.ConsumerQuotaLimits {
+		for _, bucket := range quotaLimit.QuotaBuckets {
+			switch bucket.Dimensions["region"] {
+			case region:
+				limit, found = bucket.EffectiveLimit, true
+			case "":
+				if !found {
+					limit = bucket.EffectiveLimit
+				}
+			}
+		}
+	}
+	if limit == "" {
+		return Quota{}, false, nil
+	}
+	limitValue, err := strconv.ParseInt(limit, 10, 64)
+	if err != nil {
+		return Quota{}, false, fmt.Errorf("invalid limit %q of Vertex AI quota %s: %w", limit, metric, err)
+	}
+
+	return Quota{Metric: metric, Limit: limitValue}, true, nil
+}
+
+// get sends an authenticated GET request and decodes the JSON response into result.
+func (c *GoogleQuotaClient) get(ctx context.Context, requestURL string, result any) error {
+	return sendGoogleAPIRequest(ctx, c.HTTPClient, c.AccessToken, http.MethodGet, requestURL, nil, result)
+}
+
+// quotaDemand is what the jobs launched by a deployment consume at most.
+type quotaDemand struct {
+	// Accelerators used by the max replicas of the jobs, by accelerator type.
+	accelerators map[string]int64
+	// Jobs launched at once.
+	jobs int64
+}
+
+// newQuotaDemand adds up the accelerators of the max replicas of the jobs of the component, one job per shard,
+// compared model or job spec. The jobs launched by a previous update of the run, in the order of the job specs,
+// already count in the usage and are not launched again. Jobs launched later by schedules or event triggers are
+// not counted, and neither are the machine specs only known at deployment time.
+func newQuotaDemand(args *AIBatchArgs, shards int, launched []bool) quotaDemand {
+	demand := quotaDemand{accelerators: map[string]int64{}}
+	jobIndex := -1
+	addJob := func(machineType, acceleratorType pulumi.StringInput, acceleratorCount, maxReplicaCount pulumi.IntInput) {
+		jobIndex++
+		if jobIndex < len(launched) && launched[jobIndex] {
+			return
+		}
+		demand.jobs++
+		if machineType == nil {
+			acceleratorType, acceleratorCount = args.AcceleratorType, args.AcceleratorCount
+		}
+		if maxReplicaCount == nil {
+			maxReplicaCount = args.MaxReplicaCount
+		}
+
+		accelerator, acceleratorKnown := plainString(acceleratorType)
+		count, countKnown := plainInt(acceleratorCount)
+		if acceleratorCount == nil {
+			count, countKnown = 1, true
+		}
+		replicas, replicasKnown := plainInt(maxReplicaCount)
+		if maxReplicaCount == nil {
+			replicas, replicasKnown = defaultMaxReplicaCount, true
+		}
+		if acceleratorType == nil || !acceleratorKnown || accelerator == defaultAcceleratorType || !countKnown || !replicasKnown {
+			return
+		}
+		demand.accelerators[accelerator] += int64(count) * int64(replicas)
+	}
+
+	switch {
+	case shards > 0:
+		for range shards {
+			addJob(nil, nil, nil, nil)
+		}
+	case len(args.CompareModels) > 0:
+		for _, model := range args.CompareModels {
+			addJob(model.MachineType, model.AcceleratorType, model.AcceleratorCount, nil)
+		}
+	case len(args.Jobs) > 0:
+		for _, job := range args.Jobs {
+			addJob(job.MachineType, job.AcceleratorType, job.AcceleratorCount, job.MaxReplicaCount)
+		}
+	default:
+		addJob(nil, nil, nil, nil)
+	}
+
+	return demand
+}
+
+// checkQuotas compares the quota left for the accelerators and the concurrent jobs with the demand.
+// It returns a message per quota the demand exceeds.
+func checkQuotas(ctx context.Context, client QuotaClient, project, region string, demand quotaDemand) ([]string, error) {
+	var shortfalls []string
+	for _, acceleratorType := range slices.Sorted(maps.Keys(demand.accelerators)) {
+		needed := demand.accelerators[acceleratorType]
+		quotas, err := client.AcceleratorQuotas(ctx, project, region, acceleratorType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the quotas of accelerator type %s: %w", acceleratorType, err)
+		}
+		for _, quota := range quotas {
+			if available := quota.available(); needed > available {
+				shortfalls = append(shortfalls, fmt.Sprintf("%d %s accelerators are needed at max replicas, but quota %s has %d of %d left in region %s",
+					needed, acceleratorType, quota.Metric, max(available, 0), quota.Limit, region))
+			}
+		}
+	}
+
+	jobsQuota, err := client.ConcurrentJobsQuota(ctx, project, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the quota of concurrent batch prediction jobs: %w", err)
+	}
+	if available := jobsQuota.available(); demand.jobs > available {
+		shortfalls = append(shortfalls, fmt.Sprintf("%d batch prediction jobs are launched, but quota %s has %d of %d left in region %s",
+			demand.jobs, jobsQuota.Metric, max(available, 0), jobsQuota.Limit, region))
+	}
+
+	return shortfalls, nil
+}
+
+// launchedBatchJobs returns, for each job spec, true when its job was launched by a previous update of the run,
+// i.e., a job of the region has its launch ID label.
+func (v *AIBatch) launchedBatchJobs(ctx *pulumi.Context) ([]bool, error) {
+	client, err := v.jobClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	launched := make([]bool, len(v.jobSpecs))
+	for jobIndex, spec := range v.jobSpecs {
+		jobName := v.batchJobName(spec)
+		existingJob, err := client.FindBatchPredictionJob(ctx.Context(), v.Project, v.Region, launchIDLabel, launchID(ctx, jobName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to find batch prediction job %s: %w", jobName, err)
+		}
+		launched[jobIndex] = existingJob != ""
+	}
+
+	return launched, nil
+}
+
+// runQuotaPreflight checks the quotas before the jobs and their dependencies are created. Shortfalls fail the
+// deployment, or are logged as warnings with WarnOnly. The client defaults to a GoogleQuotaClient with the
+// credentials of the gcp provider.
+func (v *AIBatch) runQuotaPreflight(ctx *pulumi.Context, args *AIBatchArgs) error {
+	client := args.QuotaPreflight.Client
+	if client == nil {
+		clientConfig, err := organizations.GetClientConfig(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get the credentials to read quotas: %w", err)
+		}
+		client = &GoogleQuotaClient{AccessToken: clientConfig.AccessToken}
+	}
+
+	// a re-run with the same job names finds its own jobs
+	launched, err := v.launchedBatchJobs(ctx)
+	if err != nil {
+		return err
+	}
+
+	shortfalls, err := checkQuotas(ctx.Context(), client, args.Project, args.Region, newQuotaDemand(args, len(v.inputShards), launched))
+	if err != nil {
+		return err
+	}
+	if len(shortfalls) == 0 {
+		return nil
+	}
+	if args.QuotaPreflight.WarnOnly {
+		for _, shortfall := range shortfalls {
+			_ = ctx.Log.Warn(shortfall, nil)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("insufficient quota: %s", strings.Join(shortfalls, "; "))
+}
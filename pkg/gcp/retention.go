@@ -0,0 +1,287 @@
+package gcp
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/storage"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// componentLabelKey labels the jobs of a component with a retention policy, which only cleans up jobs carrying it.
+const componentLabelKey = "ai-batch-component"
+
+// validateRetentionPolicy checks the retention policy and sets its defaults.
+func validateRetentionPolicy(policy *RetentionPolicyArgs) error {
+	switch {
+	case policy.KeepLast < 0:
+		return fmt.Errorf("keep last must not be negative, got %d", policy.KeepLast)
+	case policy.MaxAgeDays < 0:
+		return fmt.Errorf("max age days must not be negative, got %d", policy.MaxAgeDays)
+	case policy.KeepLast == 0 && policy.MaxAgeDays == 0:
+		return fmt.Errorf("keep last or max age days is required")
+	}
+
+	if policy.Cron == "" {
+		policy.Cron = "0 4 * * *"
+	}
+	if policy.TimeZone == "" {
+		policy.TimeZone = "Etc/UTC"
+	}
+
+	return nil
+}
+
+// componentLabelValue returns the value of the component label on the jobs of the component.
+func (v *AIBatch) componentLabelValue() string {
+	return v.NewResourceName("batch-jobs", "", 63)
+}
+
+// labelComponentJobs adds the component label to the jobs of the specs, including the jobs launched by workflows.
+func (v *AIBatch) labelComponentJobs(specs []*batchJobSpec) {
+	for _, spec := range specs {
+		labels := maps.Clone(spec.labels)
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[componentLabelKey] = v.componentLabelValue()
+		spec.labels = labels
+	}
+}
+
+// createRetentionPolicy deploys a workflow deleting the finished jobs of the component that fall outside of the
+// retention policy, along with their predictions in the artifacts bucket, and the Cloud Scheduler job starting it.
+// Jobs are the jobs carrying the component label. The jobs of the current deployment are never deleted.
+func (v *AIBatch) createRetentionPolicy(ctx *pulumi.Context, policy *RetentionPolicyArgs) error {
+	serviceAccountEmail, err := v.setupWorkflowServiceAccount(ctx)
+	if err != nil {
+		return err
+	}
+	// The cleanup lists and deletes the predictions
+	predictionsAdmin, err := storage.NewBucketIAMMember(ctx, v.NewResourceName("workflow-sa-predictions", "iam-member", 63), &storage.BucketIAMMemberArgs{
+		Bucket: v.artifactsBucket.Name,
+		Role:   pulumi.String("roles/storage.objectAdmin"),
+		Member: pulumi.Sprintf("serviceAccount:%s", serviceAccountEmail),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return fmt.Errorf("failed to grant workflows access to the predictions: %w", err)
+	}
+	v.workflowDependencies = append(v.workflowDependencies, predictionsAdmin)
+
+	deployedJobs := pulumi.StringArray{}
+	for _, spec := range v.jobSpecs {
+		deployedJobs = append(deployedJobs, spec.job.Name)
+	}
+	bucketURIPattern := v.artifactsBucket.Name.ApplyT(func(bucketName string) string {
+		return strconv.Quote("^" + regexp.QuoteMeta("gs://"+bucketName+"/"))
+	}).(pulumi.StringOutput)
+
+	// A job is retained when it is one of the last KeepLast jobs, or newer than MaxAgeDays
+	var retainedConditions []string
+	if policy.KeepLast > 0 {
+		retainedConditions = append(retainedConditions, fmt.Sprintf("newerJobs < %d", policy.KeepLast))
+	}
+	if policy.MaxAgeDays > 0 {
+		retainedConditions = append(retainedConditions, fmt.Sprintf("createdAt >= sys.now() - %d", policy.MaxAgeDays*24*60*60))
+	}
+	retained := "${" + strings.Join(retainedConditions, " or ") + "}"
+
+	definition := pulumi.Map{
+		"main": pulumi.Map{
+			"params": pulumi.ToStringArray([]string{"args"}),
+			"steps": pulumi.Array{
+				pulumi.Map{"init": pulumi.Map{
+					"assign": pulumi.Array{
+						pulumi.Map{"deployedJobs": deployedJobs},
+						pulumi.Map{"terminalStates": pulumi.ToStringArray(terminalJobStates)},
+						pulumi.Map{"jobs": pulumi.Array{}},
+						pulumi.Map{"pageToken": pulumi.String("")},
+						pulumi.Map{"deletedJobs": pulumi.Array{}},
+					},
+				}},
+				pulumi.Map{"listJobs": pulumi.Map{
+					"call": pulumi.String("http.get"),
+					"args": pulumi.Map{
+						"url":  pulumi.String(v.batchJobsURL()),
+						"auth": pulumi.Map{"type": pulumi.String("OAuth2")},
+						"query": pulumi.Map{
+							"filter":    pulumi.String(fmt.Sprintf("labels.%s=%q", componentLabelKey, v.componentLabelValue())),
+							"pageToken": pulumi.String("${pageToken}"),
+						},
+					},
+					"result": pulumi.String("page"),
+				}},
+				// list.concat appends a single value, the jobs of the page are appended one by one
+				pulumi.Map{"collectJobs": pulumi.Map{
+					"for": pulumi.Map{
+						"value": pulumi.String("pageJob"),
+						"in":    pulumi.String(`${default(map.get(page.body, "batchPredictionJobs"), [])}`),
+						"steps": pulumi.Array{
+							pulumi.Map{"collectJob": pulumi.Map{
+								"assign": pulumi.Array{
+									pulumi.Map{"jobs": pulumi.String("${list.concat(jobs, pageJob)}")},
+								},
+							}},
+						},
+					},
+				}},
+				pulumi.Map{"nextPageToken": pulumi.Map{
+					"assign": pulumi.Array{
+						pulumi.Map{"pageToken": pulumi.String(`${default(map.get(page.body, "nextPageToken"), "")}`)},
+					},
+				}},
+				pulumi.Map{"nextPage": pulumi.Map{
+					"switch": pulumi.Array{
+						pulumi.Map{
+							"condition": pulumi.String(`${pageToken != ""}`),
+							"next":      pulumi.String("listJobs"),
+						},
+					},
+				}},
+				// Jobs are ranked by creation time, the list is not ordered
+				pulumi.Map{"expireJobs": pulumi.Map{
+					"for": pulumi.Map{
+						"value": pulumi.String("job"),
+						"in":    pulumi.String("${jobs}"),
+						"steps": pulumi.Array{
+							pulumi.Map{"rank": pulumi.Map{
+								"assign": pulumi.Array{
+									pulumi.Map{"createdAt": pulumi.String("${time.parse(job.createTime)}")},
+									pulumi.Map{"newerJobs": pulumi.Int(0)},
+								},
+							}},
+							pulumi.Map{"countNewerJobs": pulumi.Map{
+								"for": pulumi.Map{
+									"value": pulumi.String("otherJob"),
+									"in":    pulumi.String("${jobs}"),
+									"steps": pulumi.Array{
+										pulumi.Map{"countNewerJob": pulumi.Map{
+											"switch": pulumi.Array{
+												pulumi.Map{
+													"condition": pulumi.String("${time.parse(otherJob.createTime) > createdAt}"),
+													"assign": pulumi.Array{
+														pulumi.Map{"newerJobs": pulumi.String("${newerJobs + 1}")},
+													},
+												},
+											},
+										}},
+									},
+								},
+							}},
+							pulumi.Map{"retain": pulumi.Map{
+								"switch": pulumi.Array{
+									pulumi.Map{
+										"condition": pulumi.String(retained),
+										"next":      pulumi.String("continue"),
+									},
+									pulumi.Map{
+										"condition": pulumi.String("${job.name in deployedJobs or not(job.state in terminalStates)}"),
+										"next":      pulumi.String("continue"),
+									},
+								},
+							}},
+							// only the predictions written to the artifacts bucket
+							pulumi.Map{"deletePredictions": pulumi.Map{
+								"switch": pulumi.Array{
+									pulumi.Map{
+										"condition": pulumi.Sprintf(`${text.match_regex(default(map.get(job, ["outputInfo", "gcsOutputDirectory"]), ""), %s)}`, bucketURIPattern),
+										"steps": pulumi.Array{
+											pulumi.Map{"deletePrefix": pulumi.Map{
+												"call": pulumi.String("deletePrefix"),
+												"args": pulumi.Map{
+													"bucket": v.artifactsBucket.Name,
+													"prefix": pulumi.Sprintf(`${text.substring(job.outputInfo.gcsOutputDirectory, %d, len(job.outputInfo.gcsOutputDirectory)) + "/"}`,
+														v.artifactsBucket.Name.ApplyT(func(bucketName string) int {
+															return len("gs://" + bucketName + "/")
+														}).(pulumi.IntOutput)),
+												},
+											}},
+										},
+									},
+								},
+							}},
+							pulumi.Map{"deleteJob": pulumi.Map{
+								"call": pulumi.String("http.delete"),
+								"args": pulumi.Map{
+									"url":  pulumi.String(fmt.Sprintf(`${"https://%s-aiplatform.googleapis.com/v1/" + job.name}`, v.Region)),
+									"auth": pulumi.Map{"type": pulumi.String("OAuth2")},
+								},
+							}},
+							pulumi.Map{"recordDeletedJob": pulumi.Map{
+								"assign": pulumi.Array{
+									pulumi.Map{"deletedJobs": pulumi.String("${list.concat(deletedJobs, job.name)}")},
+								},
+							}},
+						},
+					},
+				}},
+				pulumi.Map{"done": pulumi.Map{
+					"return": pulumi.String("${deletedJobs}"),
+				}},
+			},
+		},
+		"deletePrefix": pulumi.Map{
+			"params": pulumi.ToStringArray([]string{"bucket", "prefix"}),
+			"steps": pulumi.Array{
+				pulumi.Map{"init": pulumi.Map{
+					"assign": pulumi.Array{
+						pulumi.Map{"pageToken": pulumi.String("")},
+					},
+				}},
+				pulumi.Map{"listObjects": pulumi.Map{
+					"call": pulumi.String("googleapis.storage.v1.objects.list"),
+					"args": pulumi.Map{
+						"bucket":    pulumi.String("${bucket}"),
+						"prefix":    pulumi.String("${prefix}"),
+						"pageToken": pulumi.String("${pageToken}"),
+					},
+					"result": pulumi.String("objects"),
+				}},
+				pulumi.Map{"deleteObjects": pulumi.Map{
+					"for": pulumi.Map{
+						"value": pulumi.String("object"),
+						"in":    pulumi.String(`${default(map.get(objects, "items"), [])}`),
+						"steps": pulumi.Array{
+							pulumi.Map{"deleteObject": pulumi.Map{
+								"call": pulumi.String("googleapis.storage.v1.objects.delete"),
+								"args": pulumi.Map{
+									"bucket": pulumi.String("${bucket}"),
+									"object": pulumi.String("${text.url_encode(object.name)}"),
+								},
+							}},
+						},
+					},
+				}},
+				pulumi.Map{"nextPage": pulumi.Map{
+					"switch": pulumi.Array{
+						pulumi.Map{
+							"condition": pulumi.String(`${default(map.get(objects, "nextPageToken"), "") != ""}`),
+							"assign": pulumi.Array{
+								pulumi.Map{"pageToken": pulumi.String("${objects.nextPageToken}")},
+							},
+							"next": pulumi.String("listObjects"),
+						},
+					},
+				}},
+			},
+		},
+	}
+
+	workflow, err := v.createWorkflow(ctx, "batch-retention", "Deletes the batch prediction jobs and predictions outside of the retention policy", definition)
+	if err != nil {
+		return err
+	}
+	v.retentionWorkflow = workflow
+
+	scheduler, err := v.scheduleWorkflowExecutions(ctx, "batch-retention", workflow, policy.Cron, policy.TimeZone,
+		pulumi.Sprintf("Cleans up the batch prediction jobs of %s", v.JobDisplayName))
+	if err != nil {
+		return err
+	}
+	v.retentionScheduler = scheduler
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/storage"
+	v1 "github.com/pulumi/pulumi-google-native/sdk/go/google/aiplatform/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// jobAttemptsObjectName is the bucket object keeping the attempt history between updates.
+const jobAttemptsObjectName = "history/job-attempts.json"
+
+// retryableJobStates are the states a job can be resubmitted from.
+var retryableJobStates = []string{JobStateFailed, JobStateCancelled, JobStateExpired}
+
+// JobAttempt is a batch prediction job launched by the component.
+type JobAttempt struct {
+	// Run the attempt belongs to. Empty when no run ID strategy is set.
+	RunID string `json:"runId,omitempty"`
+	// Attempt number within the run, starting at 1.
+	Attempt int `json:"attempt"`
+	// Full resource name of the job.
+	JobName string `json:"jobName"`
+	// Last known state of the job.
+	State string `json:"state"`
+}
+
+// validateRetryPolicy checks the retry policy and fills in the defaults.
+func validateRetryPolicy(policy *RetryPolicyArgs) error {
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1, got %d", policy.MaxAttempts)
+	}
+
+	if len(policy.RetryableStates) == 0 {
+		policy.RetryableStates = []string{JobStateFailed, JobStateCancelled}
+	}
+	for _, state := range policy.RetryableStates {
+		if !slices.Contains(retryableJobStates, state) {
+			return fmt.Errorf("retryable state must be one of %s, got %q", strings.Join(retryableJobStates, ", "), state)
+		}
+	}
+
+	return nil
+}
+
+// loadJobAttempts reads the attempt history left by previous updates, and refreshes the
+// state of the last attempt. It returns no attempts on the first update.
+func (v *AIBatch) loadJobAttempts(ctx *pulumi.Context) ([]JobAttempt, error) {
+	content, err := v.readArtifactsBucketObject(ctx, jobAttemptsObjectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job attempt history: %w", err)
+	}
+	if content == "" {
+		// no job launched yet
+		return nil, nil
+	}
+
+	var attempts []JobAttempt
+	err = json.Unmarshal([]byte(content), &attempts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse job attempt history %s: %w", jobAttemptsObjectName, err)
+	}
+	if len(attempts) == 0 {
+		return nil, nil
+	}
+
+	// The history is written at submission time, the job may have finished since
+	lastAttempt := &attempts[len(attempts)-1]
+	client, err := v.jobClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state, err := client.GetBatchPredictionJobState(ctx.Context(), v.Region, lastAttempt.JobName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch prediction job %s: %w", lastAttempt.JobName, err)
+	}
+	if state == "" {
+		// deleted outside of the component, nothing to retry from
+		return attempts, nil
+	}
+	lastAttempt.State = state
+
+	return attempts, nil
+}
+
+// nextJobAttempt returns the attempt number to run on this update. The last attempt is kept
+// unless it ended in a retryable state and attempts are left. A new run starts over at 1.
+func nextJobAttempt(ctx *pulumi.Context, attempts []JobAttempt, runID string, policy *RetryPolicyArgs) int {
+	if len(attempts) == 0 || attempts[len(attempts)-1].RunID != runID {
+		// first attempt of a new run
+		return 1
+	}
+
+	lastAttempt := attempts[len(attempts)-1]
+	if !slices.Contains(policy.RetryableStates, lastAttempt.State) {
+		// succeeded, still running or not retryable
+		return lastAttempt.Attempt
+	}
+
+	if lastAttempt.Attempt >= policy.MaxAttempts {
+		_ = ctx.Log.Warn(fmt.Sprintf("batch prediction job %s ended in state %s, and all %d attempts are used",
+			lastAttempt.JobName, lastAttempt.State, policy.MaxAttempts), nil)
+
+		return lastAttempt.Attempt
+	}
+
+	return lastAttempt.Attempt + 1
+}
+
+// saveJobAttempts writes the attempt history, including the current attempt, for the next update.
+func (v *AIBatch) saveJobAttempts(ctx *pulumi.Context, job *v1.BatchPredictionJob, jobState pulumi.StringOutput) (*storage.BucketObject, error) {
+	previousAttempts := v.previousJobAttempts()
+
+	attemptsJSON := pulumi.All(job.Name, jobState).ApplyT(func(values []interface{}) (string, error) {
+		attempts := append(slices.Clone(previousAttempts), JobAttempt{
+			RunID:   v.RunID,
+			Attempt: v.jobAttempt,
+			JobName: values[0].(string),
+			State:   values[1].(string),
+		})
+
+		content, err := json.Marshal(attempts)
+		if err != nil {
+			return "", fmt.Errorf("failed to serialize job attempt history: %w", err)
+		}
+
+		return string(content), nil
+	}).(pulumi.StringOutput)
+
+	historyObject, err := storage.NewBucketObject(ctx, v.NewResourceName("job-attempts", "history", 63), &storage.BucketObjectArgs{
+		Name:        pulumi.String(jobAttemptsObjectName),
+		Bucket:      v.artifactsBucket.Name,
+		Content:     attemptsJSON,
+		ContentType: pulumi.String("application/json"),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save job attempt history: %w", err)
+	}
+
+	return historyObject, nil
+}
+
+// previousJobAttempts returns the attempts before the current one, which are final.
+func (v *AIBatch) previousJobAttempts() []JobAttempt {
+	var previousAttempts []JobAttempt
+	for _, attempt := range v.jobAttempts {
+		if attempt.RunID != v.RunID || attempt.Attempt < v.jobAttempt {
+			previousAttempts = append(previousAttempts, attempt)
+		}
+	}
+
+	return previousAttempts
+}
+
+// jobAttemptsOutput returns the attempt history with the state of the current attempt.
+func (v *AIBatch) jobAttemptsOutput() pulumi.ArrayOutput {
+	attempts := pulumi.Array{}
+	for _, attempt := range v.previousJobAttempts() {
+		attempts = append(attempts, pulumi.Map{
+			"runId":   pulumi.String(attempt.RunID),
+			"attempt": pulumi.Int(attempt.Attempt),
+			"jobName": pulumi.String(attempt.JobName),
+			"state":   pulumi.String(attempt.State),
+		})
+	}
+
+	attempts = append(attempts, pulumi.Map{
+		"runId":   pulumi.String(v.RunID),
+		"attempt": pulumi.Int(v.jobAttempt),
+		"jobName": v.batchPredictionJob.Name,
+		"state":   v.jobState,
+	})
+
+	return attempts.ToArrayOutput()
+}
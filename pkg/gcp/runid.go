@@ -0,0 +1,224 @@
+package gcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// runIDPattern restricts run IDs to values usable in resource names, labels and object prefixes.
+var runIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,39}$`)
+
+// RunIDStrategy generates the ID of a batch prediction run. The job is relaunched whenever the ID changes.
+type RunIDStrategy interface {
+	// RunID returns the ID of the run for the given component arguments.
+	RunID(args *AIBatchArgs) (string, error)
+}
+
+// TimestampRunID generates a new run ID on every update, so every pulumi up launches a new job.
+// This is the default strategy.
+type TimestampRunID struct{}
+
+// RunID returns the current time in milliseconds.
+func (TimestampRunID) RunID(_ *AIBatchArgs) (string, error) {
+	return strconv.FormatInt(time.Now().UnixMilli(), 10), nil
+}
+
+// ExplicitRunID uses a run ID supplied by the caller, e.g., a CI pipeline run number or a release tag.
+// The job is only relaunched when the ID changes.
+type ExplicitRunID string
+
+// RunID returns the caller supplied ID.
+func (id ExplicitRunID) RunID(_ *AIBatchArgs) (string, error) {
+	return string(id), nil
+}
+
+// ContentHashRunID derives the run ID from everything that changes the predictions: the local input
+// files, the local model artifacts, the model image and the job settings.
+// The job is only relaunched when any of them changes. Settings only known at deployment
+// time, such as outputs of other resources, are not part of the hash.
+type ContentHashRunID struct {
+	// Length of the hex encoded hash. Defaults to 12.
+	Length int
+}
+
+// RunID returns the truncated hash of the run content.
+func (s ContentHashRunID) RunID(args *AIBatchArgs) (string, error) {
+	length := s.Length
+	if length == 0 {
+		length = 12
+	}
+	if length < 8 || length > 40 {
+		return "", fmt.Errorf("content hash length must be between 8 and 40, got %d", length)
+	}
+
+	hasher := sha256.New()
+
+	settings, err := json.Marshal(runSettings(args))
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize job settings: %w", err)
+	}
+	_, _ = hasher.Write(settings)
+
+	if args.ModelDir != "" {
+		err = hashDirectory(hasher, args.ModelDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash model artifacts: %w", err)
+		}
+	}
+
+	for _, model := range args.CompareModels {
+		if model.ModelDir != "" {
+			err = hashDirectory(hasher, model.ModelDir)
+			if err != nil {
+				return "", fmt.Errorf("failed to hash artifacts of model %s: %w", model.Name, err)
+			}
+		}
+	}
+
+	// Inputs read from BigQuery or existing objects are referenced by URI only
+	if args.InputBigQueryURI == "" && len(args.InputURIs) == 0 && len(args.Jobs) == 0 && args.InputDataPath != "" {
+		err = hashDirectory(hasher, args.InputDataPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash input data: %w", err)
+		}
+	}
+	for _, job := range args.Jobs {
+		if job.InputDataPath != "" {
+			err = hashDirectory(hasher, job.InputDataPath)
+			if err != nil {
+				return "", fmt.Errorf("failed to hash input data of job %s: %w", job.Name, err)
+			}
+		}
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))[:length], nil
+}
+
+// runSettings collects the job settings that change the predictions.
+func runSettings(args *AIBatchArgs) map[string]interface{} {
+	settings := map[string]interface{}{
+		"modelName":           args.ModelName,
+		"modelImageURL":       plainStringSetting(args.ModelImageURL),
+		"machineType":         plainStringSetting(args.MachineType),
+		"acceleratorType":     plainStringSetting(args.AcceleratorType),
+		"acceleratorCount":    plainIntSetting(args.AcceleratorCount),
+		"tpuTopology":         plainStringSetting(args.TpuTopology),
+		"batchSize":           plainIntSetting(args.BatchSize),
+		"inputFormat":         args.InputFormat,
+		"inputFileName":       args.InputFileName,
+		"inputBigQueryURI":    args.InputBigQueryURI,
+		"inputURIs":           args.InputURIs,
+		"instanceConfig":      args.InstanceConfig,
+		"modelParameters":     args.ModelParameters,
+		"explanationSpec":     args.ExplanationSpec,
+		"outputFormat":        plainStringSetting(args.OutputFormat),
+		"outputDataPath":      plainStringSetting(args.OutputDataPath),
+		"outputBigQuery":      args.OutputBigQuery,
+		"generateExplanation": args.GenerateExplanation,
+	}
+	// only when set, so the run IDs of single job components stay the same
+	if len(args.Jobs) > 0 {
+		settings["jobs"] = jobSettings(args.Jobs)
+	}
+	if len(args.CompareModels) > 0 {
+		settings["compareModels"] = comparisonSettings(args.CompareModels)
+	}
+	if args.Sharding != nil {
+		settings["sharding"] = args.Sharding
+	}
+	if args.ContainerSpec != nil {
+		settings["containerSpec"] = containerSpecSetting(args.ContainerSpec)
+	}
+
+	return settings
+}
+
+// containerSpecSetting returns the container settings of a custom model, with the routes known before
+// deployment.
+func containerSpecSetting(args *ContainerSpecArgs) map[string]interface{} {
+	return map[string]interface{}{
+		"predictRoute":       plainStringSetting(args.PredictRoute),
+		"healthRoute":        plainStringSetting(args.HealthRoute),
+		"env":                args.Env,
+		"ports":              args.Ports,
+		"command":            args.Command,
+		"args":               args.Args,
+		"sharedMemorySizeMB": args.SharedMemorySizeMB,
+		"startupProbe":       args.StartupProbe,
+	}
+}
+
+// plainStringSetting returns the value of the input, or nil when only known at deployment time.
+func plainStringSetting(input pulumi.StringInput) interface{} {
+	if value, ok := plainString(input); ok {
+		return value
+	}
+
+	return nil
+}
+
+// plainIntSetting returns the value of the input, or nil when only known at deployment time.
+func plainIntSetting(input pulumi.IntInput) interface{} {
+	if value, ok := plainInt(input); ok {
+		return value
+	}
+
+	return nil
+}
+
+// hashDirectory adds the relative path and content of every file in the directory to the hash,
+// skipping hidden files like uploadDirectoryToBucket does.
+func hashDirectory(hasher hash.Hash, dir string) error {
+	return filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error walking path %s: %w", filePath, err)
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return fmt.Errorf("error calculating relative path: %w", err)
+		}
+		_, _ = hasher.Write([]byte(filepath.ToSlash(relPath)))
+
+		file, err := os.Open(filepath.Clean(filePath))
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", filePath, err)
+		}
+		defer file.Close()
+
+		_, err = io.Copy(hasher, file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filePath, err)
+		}
+
+		return nil
+	})
+}
+
+// resolveRunID returns the run ID from the strategy and checks it can be used in resource names.
+func resolveRunID(strategy RunIDStrategy, args *AIBatchArgs) (string, error) {
+	runID, err := strategy.RunID(args)
+	if err != nil {
+		return "", err
+	}
+	if !runIDPattern.MatchString(runID) {
+		return "", fmt.Errorf("run ID %q must be up to 40 lowercase letters, digits or hyphens, starting with a letter or digit", runID)
+	}
+
+	return runID, nil
+}
@@ -0,0 +1,92 @@
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/storage"
+	v1 "github.com/pulumi/pulumi-google-native/sdk/go/google/aiplatform/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// runIndexObjectName is the bucket object listing the runs with versioned prefixes.
+const runIndexObjectName = "runs/index.json"
+
+// RunIndexEntry is a run listed in the run index of the artifacts bucket.
+type RunIndexEntry struct {
+	// ID of the run.
+	RunID string `json:"runId"`
+	// URIs the instances of the run are read from.
+	InputURIs []string `json:"inputUris"`
+	// URI the predictions of the run are written to.
+	OutputURI string `json:"outputUri"`
+	// Full resource name of the job of the run.
+	JobName string `json:"jobName"`
+	// Time the run was first deployed, in RFC 3339 format.
+	CreatedAt string `json:"createdAt"`
+}
+
+// subPrefix appends a directory to an object prefix, as "<prefix>/<name>/".
+func subPrefix(prefix, name string) string {
+	return path.Join(strings.Trim(prefix, "/"), name) + "/"
+}
+
+// saveRunIndex adds the current run to the run index, keeping the runs listed by previous updates.
+func (v *AIBatch) saveRunIndex(ctx *pulumi.Context, job *v1.BatchPredictionJob) (*storage.BucketObject, error) {
+	content, err := v.readArtifactsBucketObject(ctx, runIndexObjectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run index: %w", err)
+	}
+
+	var runs []RunIndexEntry
+	if content != "" {
+		err = json.Unmarshal([]byte(content), &runs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse run index %s: %w", runIndexObjectName, err)
+		}
+	}
+
+	// Keep the creation time of a run deployed again, e.g., with an unchanged content hash
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	for _, run := range runs {
+		if run.RunID == v.RunID {
+			createdAt = run.CreatedAt
+		}
+	}
+	previousRuns := slices.DeleteFunc(runs, func(run RunIndexEntry) bool {
+		return run.RunID == v.RunID
+	})
+
+	indexJSON := pulumi.All(v.runInputURIs, v.runOutputURI, job.Name).ApplyT(func(values []interface{}) (string, error) {
+		runs := append(slices.Clone(previousRuns), RunIndexEntry{
+			RunID:     v.RunID,
+			InputURIs: values[0].([]string),
+			OutputURI: values[1].(string),
+			JobName:   values[2].(string),
+			CreatedAt: createdAt,
+		})
+
+		indexContent, err := json.MarshalIndent(runs, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to serialize run index: %w", err)
+		}
+
+		return string(indexContent), nil
+	}).(pulumi.StringOutput)
+
+	indexObject, err := storage.NewBucketObject(ctx, v.NewResourceName("runs", "index", 63), &storage.BucketObjectArgs{
+		Name:        pulumi.String(runIndexObjectName),
+		Bucket:      v.artifactsBucket.Name,
+		Content:     indexJSON,
+		ContentType: pulumi.String("application/json"),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save run index: %w", err)
+	}
+
+	return indexObject, nil
+}
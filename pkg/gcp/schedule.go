@@ -0,0 +1,119 @@
+package gcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// scheduleDateVariable is replaced with the execution date in the URIs of scheduled runs.
+const scheduleDateVariable = "date"
+
+// validateSchedule checks the schedule and the component fields it cannot be combined with.
+func validateSchedule(args *AIBatchArgs) error {
+	switch {
+	case args.Schedule.Cron == "":
+		return fmt.Errorf("cron expression is required")
+	case len(args.Jobs) > 0:
+		return fmt.Errorf("jobs cannot be combined with schedules")
+	case len(args.CompareModels) > 0:
+		return fmt.Errorf("model comparisons cannot be combined with schedules")
+	case args.Sharding != nil:
+		return fmt.Errorf("sharding cannot be combined with schedules")
+	case args.VersionedRunPrefixes:
+		return fmt.Errorf("versioned run prefixes cannot be combined with schedules, scheduled runs are versioned by date")
+	case args.GenerateExplanation:
+		return fmt.Errorf("explanations cannot be combined with schedules")
+	case args.InputBigQueryURI != "":
+		return fmt.Errorf("BigQuery inputs cannot be scheduled, only dated GCS prefixes")
+	}
+
+	bucketNames, err := inputBucketNames(args.Schedule.InputURIs)
+	if err != nil {
+		return fmt.Errorf("invalid input URIs: %w", err)
+	}
+	for _, bucketName := range bucketNames {
+		if strings.Contains(bucketName, "{") {
+			return fmt.Errorf("input bucket %q cannot be templated, only the object path", bucketName)
+		}
+	}
+	if args.Schedule.OutputURIPrefix != "" && !strings.HasPrefix(args.Schedule.OutputURIPrefix, gcsURIPrefix) {
+		return fmt.Errorf("output URI prefix %q must start with %q", args.Schedule.OutputURIPrefix, gcsURIPrefix)
+	}
+
+	if args.Schedule.TimeZone == "" {
+		args.Schedule.TimeZone = "Etc/UTC"
+	}
+
+	return nil
+}
+
+// createSchedule deploys a workflow submitting a batch prediction job like the job of the spec, and a Cloud
+// Scheduler job starting the workflow on the cron schedule. Runs read and write prefixes templated with the date.
+func (v *AIBatch) createSchedule(ctx *pulumi.Context, spec *batchJobSpec, schedule *ScheduleArgs) error {
+	inputURIs := pulumi.StringArray{}
+	for _, inputURI := range schedule.InputURIs {
+		inputURIs = append(inputURIs, pulumi.String(workflowExpression(inputURI, scheduleDateVariable)))
+	}
+	if len(inputURIs) == 0 {
+		// new files are dropped under a dated prefix of the inputs directory
+		inputURIs = append(inputURIs, pulumi.All(v.artifactsBucket.Name, spec.inputFileName).ApplyT(func(values []interface{}) string {
+			return workflowExpression(fmt.Sprintf("gs://%s/%s/{%s}/%s", values[0], v.inputDataTargetDir, scheduleDateVariable, values[1]), scheduleDateVariable)
+		}).(pulumi.StringOutput))
+	}
+
+	outputURIPrefix := pulumi.String(workflowExpression(schedule.OutputURIPrefix, scheduleDateVariable)).ToStringOutput()
+	if schedule.OutputURIPrefix == "" {
+		outputURIPrefix = pulumi.All(v.artifactsBucket.Name, spec.outputDataPath).ApplyT(func(values []interface{}) string {
+			return workflowExpression(fmt.Sprintf("gs://%s/%s", values[0], subPrefix(values[1].(string), "{"+scheduleDateVariable+"}")), scheduleDateVariable)
+		}).(pulumi.StringOutput)
+	}
+
+	displayName := spec.displayName.ApplyT(func(displayName string) string {
+		return workflowExpression(displayName+"-{"+scheduleDateVariable+"}", scheduleDateVariable)
+	}).(pulumi.StringOutput)
+
+	// The date defaults to the day of the execution in the time zone of the schedule.
+	// Executions started by hand can pass another date to backfill, e.g., {"date": "2025-01-31"}.
+	definition := pulumi.Map{
+		"main": pulumi.Map{
+			"params": pulumi.ToStringArray([]string{"args"}),
+			"steps": pulumi.Array{
+				pulumi.Map{"init": pulumi.Map{
+					"assign": pulumi.Array{
+						pulumi.Map{scheduleDateVariable: pulumi.String(fmt.Sprintf(`${default(map.get(args, "%s"), text.substring(time.format(sys.now(), %q), 0, 10))}`,
+							scheduleDateVariable, schedule.TimeZone))},
+					},
+				}},
+				pulumi.Map{"submit": pulumi.Map{
+					"call": pulumi.String("http.post"),
+					"args": pulumi.Map{
+						"url":  pulumi.String(v.batchJobsURL()),
+						"auth": pulumi.Map{"type": pulumi.String("OAuth2")},
+						"body": v.batchJobRequest(spec, v.modelServiceAccountEmail, displayName, pulumi.Map{"gcsSource": pulumi.Map{"uris": inputURIs}}, outputURIPrefix),
+					},
+					"result": pulumi.String("job"),
+				}},
+				pulumi.Map{"done": pulumi.Map{
+					"return": pulumi.String("${job.body.name}"),
+				}},
+			},
+		},
+	}
+
+	workflow, err := v.createWorkflow(ctx, "batch-schedule", "Submits scheduled batch prediction jobs", definition)
+	if err != nil {
+		return err
+	}
+	v.scheduleWorkflow = workflow
+
+	scheduler, err := v.scheduleWorkflowExecutions(ctx, "batch-schedule", workflow, schedule.Cron, schedule.TimeZone,
+		pulumi.Sprintf("Starts the scheduled batch prediction jobs of %s", v.JobDisplayName))
+	if err != nil {
+		return err
+	}
+	v.scheduler = scheduler
+
+	return nil
+}
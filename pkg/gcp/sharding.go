@@ -0,0 +1,427 @@
+package gcp
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Ways to balance the input shards.
+const (
+	ShardByLines = "lines"
+	ShardByBytes = "bytes"
+)
+
+// shardsDirName is the directory of the system temporary directory the input shards are written to.
+const shardsDirName = "ai-batch-shards"
+
+// shardableFormats are the input formats made of one record per line.
+var shardableFormats = map[string]string{
+	"jsonl": ".jsonl",
+	"csv":   ".csv",
+}
+
+// inputShard is a part of the input data, uploaded and scored on its own.
+type inputShard struct {
+	// Local directory holding the shard file
+	dir string
+	// Name of the shard file, e.g., "shard-00000-of-00004.jsonl"
+	fileName string
+}
+
+// validateSharding checks the sharding settings and the component fields they cannot be combined with.
+func validateSharding(args *AIBatchArgs) error {
+	switch {
+	case len(args.Jobs) > 0:
+		return fmt.Errorf("jobs cannot be combined with sharding")
+	case len(args.CompareModels) > 0:
+		return fmt.Errorf("model comparisons cannot be combined with sharding")
+	case args.RetryPolicy != nil:
+		return fmt.Errorf("retry policy cannot be combined with sharding")
+	case args.VersionedRunPrefixes:
+		return fmt.Errorf("versioned run prefixes cannot be combined with sharding")
+	case args.InputBigQueryURI != "" || len(args.InputURIs) > 0:
+		return fmt.Errorf("only input data uploaded from the input data path can be sharded")
+	}
+
+	if args.Sharding.Count < 2 {
+		return fmt.Errorf("shard count must be at least 2, got %d", args.Sharding.Count)
+	}
+	if args.Sharding.By == "" {
+		args.Sharding.By = ShardByLines
+	}
+	if args.Sharding.By != ShardByLines && args.Sharding.By != ShardByBytes {
+		return fmt.Errorf("shards are balanced by %q or %q, got %q", ShardByLines, ShardByBytes, args.Sharding.By)
+	}
+	if _, ok := shardableFormats[args.InputFormat]; !ok {
+		return fmt.Errorf("input format %q cannot be sharded, only jsonl and csv", args.InputFormat)
+	}
+
+	return nil
+}
+
+// splitInputShards splits the records of the input files matching the file name pattern into shards,
+// each written to a directory of its own. The shards of an input data path are kept under a temporary
+// directory keyed by their content, so the shard files keep their paths across updates until the inputs
+// change. The shards of previous inputs are left to the cleanup of the system temporary directory, as
+// another update may still upload them.
+// The shard files must outlive the program, the engine reads them when uploading.
+func splitInputShards(inputDir, fileNamePattern, format string, sharding *ShardingArgs) ([]inputShard, error) {
+	inputFiles, err := shardInputFiles(inputDir, fileNamePattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(inputFiles) == 0 {
+		return nil, fmt.Errorf("no input files matching %s in %s", fileNamePattern, inputDir)
+	}
+
+	// First pass to size the records, so the shards can be balanced
+	var header string
+	var recordSizes []int
+	contentHash := sha256.New()
+	_, _ = fmt.Fprintf(contentHash, "%s:%d:%s\n", format, sharding.Count, sharding.By)
+	err = readRecords(inputFiles, format, func(fileHeader string, record string) error {
+		if header == "" {
+			header = fileHeader
+			_, _ = contentHash.Write([]byte(header))
+		}
+		recordSizes = append(recordSizes, len(record))
+		_, _ = contentHash.Write([]byte(record))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(recordSizes) == 0 {
+		return nil, fmt.Errorf("no records in input files matching %s in %s", fileNamePattern, inputDir)
+	}
+
+	recordShards := assignRecordShards(recordSizes, sharding)
+	shardCount := recordShards[len(recordShards)-1] + 1
+
+	shardsDir, err := inputShardsDir(inputDir, fileNamePattern, hex.EncodeToString(contentHash.Sum(nil))[:16])
+	if err != nil {
+		return nil, err
+	}
+	shards := make([]inputShard, shardCount)
+	for shardIndex := range shards {
+		shards[shardIndex] = inputShard{
+			dir:      filepath.Join(shardsDir, fmt.Sprintf("shard-%d", shardIndex)),
+			fileName: fmt.Sprintf("shard-%05d-of-%05d%s", shardIndex, shardCount, shardableFormats[format]),
+		}
+	}
+
+	// the shards directory only ever holds complete shards, e.g., from a previous update
+	if _, err := os.Stat(shardsDir); err == nil {
+		return shards, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read shards directory: %w", err)
+	}
+
+	// Written to a directory of this process, then moved into place at once
+	if err := os.MkdirAll(filepath.Dir(shardsDir), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create shards directory: %w", err)
+	}
+	tempShardsDir, err := os.MkdirTemp(filepath.Dir(shardsDir), filepath.Base(shardsDir)+"-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shards directory: %w", err)
+	}
+	defer func() {
+		// nothing left once moved into place
+		_ = os.RemoveAll(tempShardsDir)
+	}()
+
+	err = writeInputShards(tempShardsDir, shards, inputFiles, format, header, recordShards)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tempShardsDir, shardsDir); err != nil {
+		if _, statErr := os.Stat(shardsDir); statErr == nil {
+			// moved into place by a concurrent update of the same inputs
+			return shards, nil
+		}
+
+		return nil, fmt.Errorf("failed to move shards into place: %w", err)
+	}
+
+	return shards, nil
+}
+
+// writeInputShards writes the records of the input files to the files of their shard, under the directory
+// instead of the shards directory.
+func writeInputShards(dir string, shards []inputShard, inputFiles []string, format, header string, recordShards []int) error {
+	writers := make([]*bufio.Writer, len(shards))
+	files := make([]*os.File, len(shards))
+	defer func() {
+		for _, file := range files {
+			if file != nil {
+				_ = file.Close()
+			}
+		}
+	}()
+	for shardIndex, shard := range shards {
+		shardDir := filepath.Join(dir, filepath.Base(shard.dir))
+		if err := os.MkdirAll(shardDir, 0o750); err != nil {
+			return fmt.Errorf("failed to create shard directory: %w", err)
+		}
+		file, err := os.Create(filepath.Join(shardDir, shard.fileName))
+		if err != nil {
+			return fmt.Errorf("failed to create shard file: %w", err)
+		}
+		files[shardIndex] = file
+		writers[shardIndex] = bufio.NewWriter(file)
+		if header != "" {
+			// every shard is a standalone CSV file
+			_, _ = writers[shardIndex].WriteString(header)
+		}
+	}
+
+	// Second pass to write the records to their shard, in order
+	recordIndex := 0
+	err := readRecords(inputFiles, format, func(_ string, record string) error {
+		_, err := writers[recordShards[recordIndex]].WriteString(record)
+		recordIndex++
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write shards: %w", err)
+	}
+	for shardIndex, writer := range writers {
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("failed to write shard %s: %w", shards[shardIndex].fileName, err)
+		}
+		err := files[shardIndex].Close()
+		files[shardIndex] = nil
+		if err != nil {
+			return fmt.Errorf("failed to write shard %s: %w", shards[shardIndex].fileName, err)
+		}
+	}
+
+	return nil
+}
+
+// inputShardsDir returns the directory of the shards with the content key, under the shards directory
+// of the input data path.
+func inputShardsDir(inputDir, fileNamePattern, contentKey string) (string, error) {
+	absInputDir, err := filepath.Abs(inputDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve input data path %s: %w", inputDir, err)
+	}
+	inputHash := sha256.Sum256([]byte(absInputDir + "/" + fileNamePattern))
+
+	return filepath.Join(os.TempDir(), shardsDirName, hex.EncodeToString(inputHash[:8]), contentKey), nil
+}
+
+// shardInputFiles returns the input files matching the file name pattern, in lexical order,
+// skipping hidden files like uploadDirectoryToBucket does.
+func shardInputFiles(inputDir, fileNamePattern string) ([]string, error) {
+	var inputFiles []string
+	err := filepath.WalkDir(inputDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("error walking path %s: %w", filePath, err)
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+
+		matched, err := path.Match(fileNamePattern, entry.Name())
+		if err != nil {
+			return fmt.Errorf("invalid input file name pattern %s: %w", fileNamePattern, err)
+		}
+		if matched {
+			inputFiles = append(inputFiles, filePath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inputFiles, nil
+}
+
+// readRecords calls onRecord with every non-empty line of the input files, newline terminated.
+// The first line of CSV files is the header, passed along instead of being a record.
+func readRecords(inputFiles []string, format string, onRecord func(header string, record string) error) error {
+	for _, inputFile := range inputFiles {
+		err := func() error {
+			file, err := os.Open(filepath.Clean(inputFile))
+			if err != nil {
+				return fmt.Errorf("failed to open input file %s: %w", inputFile, err)
+			}
+			defer func() { _ = file.Close() }()
+
+			reader := bufio.NewReader(file)
+			header := ""
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil && !errors.Is(err, io.EOF) {
+					return fmt.Errorf("failed to read input file %s: %w", inputFile, err)
+				}
+				if strings.TrimSpace(line) != "" {
+					if !strings.HasSuffix(line, "\n") {
+						line += "\n"
+					}
+					if format == "csv" && header == "" {
+						header = line
+					} else if recordErr := onRecord(header, line); recordErr != nil {
+						return recordErr
+					}
+				}
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+			}
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// assignRecordShards returns the shard of every record, balancing the shards by record count or size.
+// Shards are numbered in record order, without gaps.
+func assignRecordShards(recordSizes []int, sharding *ShardingArgs) []int {
+	shardCount := min(sharding.Count, len(recordSizes))
+
+	totalSize := 0
+	for _, size := range recordSizes {
+		totalSize += size
+	}
+
+	recordShards := make([]int, len(recordSizes))
+	offset := 0
+	for recordIndex, size := range recordSizes {
+		if sharding.By == ShardByBytes {
+			recordShards[recordIndex] = offset * shardCount / totalSize
+		} else {
+			recordShards[recordIndex] = recordIndex * shardCount / len(recordSizes)
+		}
+		offset += size
+	}
+
+	// Large records can leave shards balanced by bytes empty
+	shardNumbers := map[int]int{}
+	for recordIndex, shard := range recordShards {
+		if _, ok := shardNumbers[shard]; !ok {
+			shardNumbers[shard] = len(shardNumbers)
+		}
+		recordShards[recordIndex] = shardNumbers[shard]
+	}
+
+	return recordShards
+}
+
+// shardJobSpecs returns one job per input shard. Predictions of each shard are written under a
+// sibling prefix named after the shard.
+func (v *AIBatch) shardJobSpecs() []*batchJobSpec {
+	specs := make([]*batchJobSpec, len(v.inputShards))
+	for shardIndex, shard := range v.inputShards {
+		shardName := fmt.Sprintf("shard-%d", shardIndex)
+
+		spec := v.defaultBatchJobSpec()
+		spec.name = shardName
+		spec.displayName = pulumi.Sprintf("%s-%s", v.JobDisplayName, shardName)
+		spec.inputDataLocalDir = shard.dir
+		spec.inputDataTargetDir = path.Join(v.inputDataTargetDir, shardName)
+		spec.inputFileName = pulumi.String(shard.fileName).ToStringOutput()
+		spec.outputDataPath = v.OutputDataPath.ApplyT(func(prefix string) string {
+			return subPrefix(prefix, shardName)
+		}).(pulumi.StringOutput)
+
+		specs[shardIndex] = spec
+	}
+
+	return specs
+}
+
+// GetShardJobNames returns the names of the jobs scoring the input shards, in shard order, when Sharding is set.
+func (v *AIBatch) GetShardJobNames() pulumi.StringArrayOutput {
+	jobNames := pulumi.StringArray{}
+	if len(v.inputShards) > 0 {
+		for _, spec := range v.jobSpecs {
+			jobNames = append(jobNames, spec.job.Name)
+		}
+	}
+
+	return jobNames.ToStringArrayOutput()
+}
+
+// GetShardOutputURIs returns the prefixes the predictions of the input shards are written to,
+// in shard order, when Sharding is set. See MergeShardPredictions.
+func (v *AIBatch) GetShardOutputURIs() pulumi.StringArrayOutput {
+	outputURIs := pulumi.StringArray{}
+	if len(v.inputShards) > 0 {
+		for _, spec := range v.jobSpecs {
+			outputURIs = append(outputURIs, spec.runOutputURI)
+		}
+	}
+
+	return outputURIs.ToStringArrayOutput()
+}
+
+// MergeShardPredictions writes the JSONL predictions of every shard to w, in shard order, as one result set.
+// shardDirs are the directories of fsys holding a copy of the shard output prefixes, in the order of
+// GetShardOutputURIs (e.g., synced with "gcloud storage rsync"). Prediction files of a shard are read
+// in lexical order, and error stats files are skipped.
+func MergeShardPredictions(fsys fs.FS, shardDirs []string, w io.Writer) error {
+	for _, shardDir := range shardDirs {
+		var predictionFiles []string
+		err := fs.WalkDir(fsys, shardDir, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && strings.HasPrefix(entry.Name(), "prediction.results-") {
+				predictionFiles = append(predictionFiles, filePath)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list predictions of shard %s: %w", shardDir, err)
+		}
+		slices.Sort(predictionFiles)
+
+		for _, predictionFile := range predictionFiles {
+			err := copyPredictions(fsys, predictionFile, w)
+			if err != nil {
+				return fmt.Errorf("failed to merge predictions %s: %w", predictionFile, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyPredictions copies a predictions file to w, making sure it ends with a newline.
+func copyPredictions(fsys fs.FS, predictionFile string, w io.Writer) error {
+	content, err := fs.ReadFile(fsys, predictionFile)
+	if err != nil {
+		return err
+	}
+	if len(content) == 0 {
+		return nil
+	}
+	if content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	_, err = w.Write(content)
+
+	return err
+}
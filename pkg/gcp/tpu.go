@@ -0,0 +1,82 @@
+package gcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// tpuMachineSpec describes a Cloud TPU machine type supported for predictions.
+type tpuMachineSpec struct {
+	AcceleratorType string
+	Chips           int
+	Topology        string
+}
+
+// tpuMachineSpecs lists the Cloud TPU machine types supported for predictions.
+// See: https://cloud.google.com/vertex-ai/docs/predictions/use-tpu
+var tpuMachineSpecs = map[string]tpuMachineSpec{
+	// TPU v5e
+	"ct5lp-hightpu-1t": {AcceleratorType: "TPU_V5_LITEPOD", Chips: 1, Topology: "1x1"},
+	"ct5lp-hightpu-4t": {AcceleratorType: "TPU_V5_LITEPOD", Chips: 4, Topology: "2x2"},
+	"ct5lp-hightpu-8t": {AcceleratorType: "TPU_V5_LITEPOD", Chips: 8, Topology: "2x4"},
+	// TPU v6e
+	"ct6e-standard-1t": {AcceleratorType: "TPU_V6E", Chips: 1, Topology: "1x1"},
+	"ct6e-standard-4t": {AcceleratorType: "TPU_V6E", Chips: 4, Topology: "2x2"},
+	"ct6e-standard-8t": {AcceleratorType: "TPU_V6E", Chips: 8, Topology: "2x4"},
+}
+
+// IsTPUMachineType returns true for the machine type families backed by Cloud TPUs.
+func IsTPUMachineType(machineType string) bool {
+	return strings.HasPrefix(machineType, "ct5lp-") || strings.HasPrefix(machineType, "ct6e-")
+}
+
+// resolveTPUMachineSpec checks that the TPU machine type, accelerator type, accelerator count and
+// topology agree, and fills in the ones not set from the machine type.
+// Values only known at deployment time are not checked.
+func resolveTPUMachineSpec(args *AIBatchArgs) error {
+	machineType, machineTypeKnown := plainString(args.MachineType)
+	if args.MachineType == nil {
+		// the default machine type has no TPUs
+		machineTypeKnown = true
+	}
+	acceleratorType, acceleratorTypeKnown := plainString(args.AcceleratorType)
+	isTPUAccelerator := acceleratorTypeKnown && strings.HasPrefix(acceleratorType, "TPU_")
+
+	if !machineTypeKnown || !IsTPUMachineType(machineType) {
+		if isTPUAccelerator && machineTypeKnown {
+			return fmt.Errorf("accelerator type %s requires a TPU machine type", acceleratorType)
+		}
+		if args.TpuTopology != nil && machineTypeKnown {
+			return fmt.Errorf("TPU topology requires a TPU machine type")
+		}
+
+		return nil
+	}
+
+	spec, ok := tpuMachineSpecs[machineType]
+	if !ok {
+		return fmt.Errorf("unsupported TPU machine type %q", machineType)
+	}
+
+	if args.AcceleratorType == nil || acceleratorType == "ACCELERATOR_TYPE_UNSPECIFIED" {
+		args.AcceleratorType = pulumi.String(spec.AcceleratorType)
+	} else if acceleratorTypeKnown && acceleratorType != spec.AcceleratorType {
+		return fmt.Errorf("machine type %s requires accelerator type %s, got %s", machineType, spec.AcceleratorType, acceleratorType)
+	}
+
+	if args.AcceleratorCount == nil {
+		args.AcceleratorCount = pulumi.Int(spec.Chips)
+	} else if acceleratorCount, known := plainInt(args.AcceleratorCount); known && acceleratorCount != spec.Chips {
+		return fmt.Errorf("machine type %s has %d TPU chips, got accelerator count %d", machineType, spec.Chips, acceleratorCount)
+	}
+
+	if args.TpuTopology == nil {
+		args.TpuTopology = pulumi.String(spec.Topology)
+	} else if topology, known := plainString(args.TpuTopology); known && topology != spec.Topology {
+		return fmt.Errorf("machine type %s requires TPU topology %s, got %s", machineType, spec.Topology, topology)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	v1 "github.com/pulumi/pulumi-google-native/sdk/go/google/aiplatform/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Batch prediction job states.
+const (
+	JobStateSucceeded          = "JOB_STATE_SUCCEEDED"
+	JobStatePartiallySucceeded = "JOB_STATE_PARTIALLY_SUCCEEDED"
+	JobStateFailed             = "JOB_STATE_FAILED"
+	JobStateCancelled          = "JOB_STATE_CANCELLED"
+	JobStateExpired            = "JOB_STATE_EXPIRED"
+)
+
+// terminalJobStates are the states a job never leaves.
+var terminalJobStates = []string{
+	JobStateSucceeded,
+	JobStatePartiallySucceeded,
+	JobStateFailed,
+	JobStateCancelled,
+	JobStateExpired,
+}
+
+// BatchJobWaiter polls a batch prediction job until it reaches a terminal state.
+// Its outputs resolve once the job is done, which blocks the deployment until then.
+type BatchJobWaiter struct {
+	pulumi.ResourceState
+
+	// Final state of the job (e.g., "JOB_STATE_SUCCEEDED").
+	State pulumi.StringOutput
+	// Error message of the job, if it failed.
+	ErrorMessage pulumi.StringOutput
+	// Number of instances processed successfully.
+	SuccessfulCount pulumi.StringOutput
+	// Number of instances that failed.
+	FailedCount pulumi.StringOutput
+	// Number of instances not processed. -1 if unknown.
+	IncompleteCount pulumi.StringOutput
+	// Fully qualified predictions table ("project.dataset.table"), if the predictions are written to BigQuery.
+	OutputBigQueryTable pulumi.StringOutput
+}
+
+// waitForJobCompletion creates a waiter for the batch prediction job.
+func (v *AIBatch) waitForJobCompletion(ctx *pulumi.Context, spec *batchJobSpec, args *WaitForCompletionArgs) (*BatchJobWaiter, error) {
+	job := spec.job
+	waiter := &BatchJobWaiter{}
+
+	waiterOpts := []pulumi.ResourceOption{pulumi.Parent(v)}
+	if !ctx.DryRun() || !v.managedByProvider(spec) {
+		// jobs created from a Vertex AI request are only read into the stack once created
+		waiterOpts = append(waiterOpts, pulumi.DependsOn([]pulumi.Resource{job}))
+	}
+	err := ctx.RegisterComponentResource("pulumi-ai-batch:gcp:BatchJobWaiter",
+		v.NewResourceName(spec.jobResourceName("batch-job"), "waiter", 63),
+		waiter,
+		waiterOpts...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register batch job waiter: %w", err)
+	}
+
+	var result v1.LookupBatchPredictionJobResultOutput
+	if ctx.DryRun() {
+		// The job name can be known on preview, e.g., of an existing job, so only poll on updates
+		result = pulumi.UnsafeUnknownOutput([]pulumi.Resource{job}).ApplyT(func(interface{}) v1.LookupBatchPredictionJobResult {
+			return v1.LookupBatchPredictionJobResult{}
+		}).(v1.LookupBatchPredictionJobResultOutput)
+	} else {
+		result = job.Name.ApplyTWithContext(ctx.Context(), func(applyCtx context.Context, jobName string) (v1.LookupBatchPredictionJobResult, error) {
+			job, err := pollJobUntilDone(applyCtx, func() (*v1.LookupBatchPredictionJobResult, error) {
+				return v1.LookupBatchPredictionJob(ctx, &v1.LookupBatchPredictionJobArgs{
+					// The job name is the full resource name, ending with the job ID
+					BatchPredictionJobId: path.Base(jobName),
+					Location:             v.Region,
+					Project:              pulumi.StringRef(v.Project),
+				}, pulumi.Parent(waiter))
+			}, jobName, args)
+			if err != nil {
+				return v1.LookupBatchPredictionJobResult{}, err
+			}
+
+			return *job, nil
+		}).(v1.LookupBatchPredictionJobResultOutput)
+	}
+
+	waiter.State = result.State()
+	waiter.ErrorMessage = result.Error().Message()
+	waiter.SuccessfulCount = result.CompletionStats().SuccessfulCount()
+	waiter.FailedCount = result.CompletionStats().FailedCount()
+	waiter.IncompleteCount = result.CompletionStats().IncompleteCount()
+	// the table is named once the job starts writing, so it is read from the finished job
+	waiter.OutputBigQueryTable = bigQueryOutputTable(result.OutputInfo().BigqueryOutputDataset(), result.OutputInfo().BigqueryOutputTable())
+
+	err = ctx.RegisterResourceOutputs(waiter, pulumi.Map{
+		"state":               waiter.State,
+		"errorMessage":        waiter.ErrorMessage,
+		"successfulCount":     waiter.SuccessfulCount,
+		"failedCount":         waiter.FailedCount,
+		"incompleteCount":     waiter.IncompleteCount,
+		"outputBigQueryTable": waiter.OutputBigQueryTable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register batch job waiter outputs: %w", err)
+	}
+
+	return waiter, nil
+}
+
+// pollJobUntilDone looks up the job every poll interval until it reaches a terminal state or the timeout expires.
+func pollJobUntilDone(ctx context.Context,
+	lookupJob func() (*v1.LookupBatchPredictionJobResult, error),
+	jobName string,
+	args *WaitForCompletionArgs) (*v1.LookupBatchPredictionJobResult, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, args.Timeout)
+	defer cancel()
+
+	for {
+		job, err := lookupJob()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get batch prediction job %s: %w", jobName, err)
+		}
+
+		if slices.Contains(terminalJobStates, job.State) {
+			if args.FailOnUnsuccessfulJob && job.State != JobStateSucceeded {
+				return nil, fmt.Errorf("batch prediction job %s ended in state %s: %s", jobName, job.State, job.Error.Message)
+			}
+
+			return job, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out after %s waiting for batch prediction job %s, last state %s", args.Timeout, jobName, job.State)
+		case <-time.After(args.PollInterval):
+		}
+	}
+}
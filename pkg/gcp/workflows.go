@@ -0,0 +1,280 @@
+package gcp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/cloudscheduler"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/projects"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/serviceaccount"
+	"github.com/pulumi/pulumi-gcp/sdk/v8/go/gcp/workflows"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"gopkg.in/yaml.v3"
+)
+
+// workflowRoles are granted to the workflows Service Account, whatever starts the workflows.
+var workflowRoles = []string{
+	"roles/aiplatform.user",   // For submitting batch prediction jobs
+	"roles/workflows.invoker", // For starting workflow executions from schedulers and triggers
+	"roles/logging.logWriter", // For writing execution logs
+}
+
+// setupWorkflowServiceAccount creates the Service Account running the workflows of the component, once.
+// When the jobs run custom models, it is allowed to launch jobs as the model Service Account.
+func (v *AIBatch) setupWorkflowServiceAccount(ctx *pulumi.Context) (pulumi.StringOutput, error) {
+	if v.workflowServiceAccount != nil {
+		return v.workflowServiceAccount.Email, nil
+	}
+
+	accountID := v.NewResourceName("workflow-account", "", 30)
+	workflowServiceAccount, err := serviceaccount.NewAccount(ctx, v.NewResourceName("workflow-account", "", 63), &serviceaccount.AccountArgs{
+		Project:     pulumi.String(v.Project),
+		AccountId:   pulumi.String(accountID),
+		DisplayName: pulumi.Sprintf("%s Workflows Service Account", v.JobDisplayName),
+		Description: pulumi.String("Service account for workflows launching batch prediction jobs"),
+	}, pulumi.Parent(v))
+	if err != nil {
+		return pulumi.StringOutput{}, fmt.Errorf("failed to create workflow service account: %w", err)
+	}
+	v.workflowServiceAccount = workflowServiceAccount
+	v.workflowRoles = map[string]bool{}
+
+	err = v.grantWorkflowRoles(ctx, workflowRoles...)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	if v.modelServiceAccountEmail != (pulumi.StringOutput{}) {
+		// jobs of custom models run as the model Service Account
+		actAsMember, err := serviceaccount.NewIAMMember(ctx, v.NewResourceName("workflow-sa-act-as-model-sa", "iam-member", 63), &serviceaccount.IAMMemberArgs{
+			ServiceAccountId: pulumi.Sprintf("projects/%s/serviceAccounts/%s", v.Project, v.modelServiceAccountEmail),
+			Role:             pulumi.String("roles/iam.serviceAccountUser"),
+			Member:           pulumi.Sprintf("serviceAccount:%s", workflowServiceAccount.Email),
+		}, pulumi.Parent(v))
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("failed to allow workflows to act as the model service account: %w", err)
+		}
+		v.workflowDependencies = append(v.workflowDependencies, actAsMember)
+	}
+
+	return workflowServiceAccount.Email, nil
+}
+
+// grantWorkflowRoles grants project roles to the workflows Service Account, skipping the roles already granted.
+func (v *AIBatch) grantWorkflowRoles(ctx *pulumi.Context, roles ...string) error {
+	for _, role := range roles {
+		if v.workflowRoles[role] {
+			continue
+		}
+
+		bindingName := v.NewResourceName(fmt.Sprintf("workflow-sa-iam-%s", role), "", 63)
+		member, err := projects.NewIAMMember(ctx, bindingName, &projects.IAMMemberArgs{
+			Project: pulumi.String(v.Project),
+			Role:    pulumi.String(role),
+			Member:  pulumi.Sprintf("serviceAccount:%s", v.workflowServiceAccount.Email),
+		}, pulumi.Parent(v))
+		if err != nil {
+			return fmt.Errorf("failed to create workflow IAM member for role %s: %w", role, err)
+		}
+		v.workflowRoles[role] = true
+		v.workflowDependencies = append(v.workflowDependencies, member)
+	}
+
+	return nil
+}
+
+// createWorkflow deploys a Cloud Workflows workflow run by the workflows Service Account.
+// The definition is serialized to YAML once all of its values are known.
+func (v *AIBatch) createWorkflow(ctx *pulumi.Context, name string, description string, definition pulumi.Map) (*workflows.Workflow, error) {
+	serviceAccountEmail, err := v.setupWorkflowServiceAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceContents := definition.ToMapOutput().ApplyT(func(definition map[string]interface{}) (string, error) {
+		source, err := yaml.Marshal(definition)
+		if err != nil {
+			return "", fmt.Errorf("failed to serialize workflow definition: %w", err)
+		}
+
+		return string(source), nil
+	}).(pulumi.StringOutput)
+
+	workflowName := v.NewResourceName(name, "workflow", 63)
+	workflow, err := workflows.NewWorkflow(ctx, workflowName, &workflows.WorkflowArgs{
+		Project:            pulumi.String(v.Project),
+		Region:             pulumi.String(v.Region),
+		Name:               pulumi.String(workflowName),
+		Description:        pulumi.String(description),
+		ServiceAccount:     serviceAccountEmail,
+		SourceContents:     sourceContents,
+		Labels:             pulumi.ToStringMap(v.Labels),
+		DeletionProtection: pulumi.Bool(false),
+	},
+		pulumi.Parent(v),
+		pulumi.DependsOn(v.workflowDependencies),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workflow %s: %w", name, err)
+	}
+
+	return workflow, nil
+}
+
+// scheduleWorkflowExecutions creates a Cloud Scheduler job starting executions of the workflow on the cron schedule,
+// authenticated as the workflows Service Account.
+func (v *AIBatch) scheduleWorkflowExecutions(ctx *pulumi.Context, name string, workflow *workflows.Workflow,
+	cron string, timeZone string, description pulumi.StringInput) (*cloudscheduler.Job, error) {
+
+	// The execution argument is a JSON string, an empty object lets the workflow use its defaults
+	executionBody := base64.StdEncoding.EncodeToString([]byte(`{"argument": "{}"}`))
+
+	scheduler, err := cloudscheduler.NewJob(ctx, v.NewResourceName(name, "scheduler-job", 63), &cloudscheduler.JobArgs{
+		Project:     pulumi.String(v.Project),
+		Region:      pulumi.String(v.Region),
+		Name:        pulumi.String(v.NewResourceName(name, "scheduler-job", 63)),
+		Description: description,
+		Schedule:    pulumi.String(cron),
+		TimeZone:    pulumi.String(timeZone),
+		HttpTarget: &cloudscheduler.JobHttpTargetArgs{
+			Uri:        v.workflowExecutionsURL(workflow),
+			HttpMethod: pulumi.String("POST"),
+			Body:       pulumi.String(executionBody),
+			Headers: pulumi.StringMap{
+				"Content-Type": pulumi.String("application/json"),
+			},
+			OauthToken: &cloudscheduler.JobHttpTargetOauthTokenArgs{
+				ServiceAccountEmail: v.workflowServiceAccount.Email,
+			},
+		},
+	},
+		pulumi.Parent(v),
+		pulumi.DependsOn([]pulumi.Resource{workflow}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheduler job %s: %w", name, err)
+	}
+
+	return scheduler, nil
+}
+
+// workflowResourceName returns the full resource name of the workflow, e.g., "projects/my-project/locations/us-central1/workflows/my-workflow".
+func (v *AIBatch) workflowResourceName(workflow *workflows.Workflow) pulumi.StringOutput {
+	return pulumi.Sprintf("projects/%s/locations/%s/workflows/%s", v.Project, v.Region, workflow.Name)
+}
+
+// workflowExecutionsURL returns the Workflow Executions API endpoint starting a new execution of the workflow.
+func (v *AIBatch) workflowExecutionsURL(workflow *workflows.Workflow) pulumi.StringOutput {
+	return pulumi.Sprintf("https://workflowexecutions.googleapis.com/v1/%s/executions", v.workflowResourceName(workflow))
+}
+
+// batchJobsURL returns the Vertex AI API endpoint creating batch prediction jobs in the component region.
+func (v *AIBatch) batchJobsURL() string {
+	return batchPredictionJobsURL(v.Project, v.Region)
+}
+
+// batchJobRequest returns the body of a Vertex AI request creating a batch prediction job like the job of
+// the spec: same model, machine spec, provisioning, parameters and encryption, reading the instances from
+// the input source (e.g., {"gcsSource": {"uris": [...]}}) and writing the predictions to outputURIPrefix.
+// The display name and the URIs can be workflow expressions.
+func (v *AIBatch) batchJobRequest(spec *batchJobSpec, serviceAccountEmail pulumi.StringOutput,
+	displayName pulumi.StringInput, inputSource pulumi.Map, outputURIPrefix pulumi.StringInput) pulumi.Map {
+
+	machineSpec := pulumi.All(spec.machineType, spec.acceleratorType, spec.acceleratorCount, spec.tpuTopology).
+		ApplyT(func(values []interface{}) map[string]interface{} {
+			machineSpec := map[string]interface{}{
+				"machineType":      values[0],
+				"acceleratorType":  values[1],
+				"acceleratorCount": values[2],
+			}
+			if topology := values[3].(string); topology != "" {
+				machineSpec["tpuTopology"] = topology
+			}
+			if v.ReservationAffinity != nil {
+				machineSpec["reservationAffinity"] = reservationAffinityRequest(v.ReservationAffinity)
+			}
+
+			return machineSpec
+		}).(pulumi.MapOutput)
+
+	outputConfig := pulumi.Map{
+		"predictionsFormat": spec.outputFormat,
+	}
+	if v.OutputBigQuery != nil {
+		// a new predictions table is created in the dataset for every job
+		outputConfig["bigqueryDestination"] = pulumi.Map{"outputUri": v.outputBigQueryURI}
+	} else {
+		outputConfig["gcsDestination"] = pulumi.Map{"outputUriPrefix": outputURIPrefix}
+	}
+
+	inputConfig := pulumi.Map{
+		"instancesFormat": spec.inputFormat,
+	}
+	for source, config := range inputSource {
+		inputConfig[source] = config
+	}
+
+	dedicatedResources := pulumi.Map{
+		"machineSpec":          machineSpec,
+		"startingReplicaCount": spec.startingReplicaCount,
+		"maxReplicaCount":      spec.maxReplicaCount,
+	}
+	if v.Spot {
+		dedicatedResources["spot"] = pulumi.Bool(true)
+	}
+
+	request := pulumi.Map{
+		"displayName":        displayName,
+		"model":              spec.modelName(),
+		"inputConfig":        inputConfig,
+		"outputConfig":       outputConfig,
+		"dedicatedResources": dedicatedResources,
+		"manualBatchTuningParameters": pulumi.Map{
+			"batchSize": spec.batchSize,
+		},
+		"labels": pulumi.ToStringMap(spec.labels),
+	}
+	if spec.registeredModel != nil {
+		request["serviceAccount"] = serviceAccountEmail
+	}
+	if v.ModelParameters != nil {
+		request["modelParameters"] = pulumi.ToMap(v.ModelParameters)
+	}
+	if v.InstanceConfig != nil {
+		request["instanceConfig"] = toInstanceConfigRequest(v.InstanceConfig)
+	}
+	if v.customerManagedEncryption {
+		request["encryptionSpec"] = pulumi.Map{"kmsKeyName": v.KmsKeyName}
+	}
+	if v.GenerateExplanation {
+		// explanation files are written next to the predictions
+		request["generateExplanation"] = pulumi.Bool(true)
+		request["explanationSpec"] = explanationSpecRequest(v.ExplanationSpec, v.modelArtifactsURI)
+	}
+
+	return request
+}
+
+// workflowExpression returns a workflow expression concatenating the quoted literals with the given variable,
+// e.g., ${"gs://my-data/" + date + "/*.jsonl"} for "gs://my-data/{date}/*.jsonl".
+// The template is returned as is when it does not reference the variable.
+func workflowExpression(template, variable string) string {
+	literals := strings.Split(template, "{"+variable+"}")
+	if len(literals) == 1 {
+		return template
+	}
+
+	terms := make([]string, 0, 2*len(literals)-1)
+	for literalIndex, literal := range literals {
+		if literalIndex > 0 {
+			terms = append(terms, variable)
+		}
+		if literal != "" {
+			terms = append(terms, strconv.Quote(literal))
+		}
+	}
+
+	return "${" + strings.Join(terms, " + ") + "}"
+}